@@ -0,0 +1,30 @@
+package main
+
+// Matrix ホームサーバーに登録する Application Service の登録ファイルを出力するコマンドです。
+//
+//	go run ./cmd/matrix-registration -url http://linkgate:8080 > linkgate-registration.yaml
+//
+// 出力したファイルを Synapse の app_service_config_files に追加してください。
+// トークンは環境変数 MATRIX_AS_TOKEN / MATRIX_HS_TOKEN の値が使用されます。
+
+import (
+	"flag"
+	"fmt"
+	"fuagfuga-2025-LinkGate/src/usecase/matrix"
+	"log"
+	"os"
+)
+
+func main() {
+	id := flag.String("id", "linkgate", "Application Service のID")
+	url := flag.String("url", "http://localhost:8080", "ホームサーバーから LinkGate へ接続するURL")
+	flag.Parse()
+
+	for _, key := range []string{"MATRIX_SERVER_NAME", "MATRIX_AS_TOKEN", "MATRIX_HS_TOKEN"} {
+		if os.Getenv(key) == "" {
+			log.Fatalf("%s を設定してください", key)
+		}
+	}
+
+	fmt.Print(matrix.Registration(*id, *url))
+}
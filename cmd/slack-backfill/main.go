@@ -0,0 +1,83 @@
+package main
+
+// Slack チャンネルの過去ログを LinkGate の DB に取り込むコマンドです。
+//
+//	go run ./cmd/slack-backfill -channel C0123456789 -oldest 2025-01-01T00:00:00Z -threads
+//
+// 取り込んだメッセージは他プラットフォームへは転送されません。
+
+import (
+	"context"
+	"flag"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/slack"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func main() {
+	channels := flag.String("channel", "", "取り込むチャンネルID（カンマ区切りで複数指定可）")
+	team := flag.String("team", "", "ワークスペースID（OAuth でインストールしたワークスペースの場合）")
+	oldest := flag.String("oldest", "", "取り込み開始日時（RFC3339）")
+	latest := flag.String("latest", "", "取り込み終了日時（RFC3339）")
+	threads := flag.Bool("threads", true, "スレッドの返信も取り込む")
+	flag.Parse()
+
+	if *channels == "" {
+		log.Fatal("-channel を指定してください")
+	}
+
+	opts := slack.BackfillOptions{TeamID: *team, IncludeThreads: *threads}
+	var err error
+	if opts.Oldest, err = parseTime(*oldest); err != nil {
+		log.Fatalf("-oldest の形式が不正です: %v", err)
+	}
+	if opts.Latest, err = parseTime(*latest); err != nil {
+		log.Fatalf("-latest の形式が不正です: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// MongoDB クライアントの作成
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := client.Disconnect(ctx); err != nil {
+			log.Printf("MongoDBの切断に失敗🥺: %v", err)
+		}
+	}()
+
+	db := client.Database("linkgate")
+	collection := db.Collection("posts")
+	if err := bridge.Init(db.Collection("bridges")); err != nil {
+		log.Printf("ブリッジの読み込みに失敗しました: %v", err)
+	}
+	handler := slack.NewSlackHandler(collection, ctx)
+
+	for _, channelID := range strings.Split(*channels, ",") {
+		opts.ChannelID = strings.TrimSpace(channelID)
+		n, err := handler.Backfill(ctx, opts)
+		if err != nil {
+			log.Printf("チャンネル %s の取り込みに失敗しました（%d件取り込み済み）: %v", opts.ChannelID, n, err)
+			continue
+		}
+		log.Printf("チャンネル %s: %d件取り込みました", opts.ChannelID, n)
+	}
+}
+
+// RFC3339 形式の日時を解析する（空の場合はゼロ値）
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -2,11 +2,26 @@ package main
 
 import (
 	"context"
+	"fuagfuga-2025-LinkGate/src/middleware"
 	"fuagfuga-2025-LinkGate/src/router"
 	"fuagfuga-2025-LinkGate/src/usecase"
+	"fuagfuga-2025-LinkGate/src/usecase/apikey"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/delivery"
 	"fuagfuga-2025-LinkGate/src/usecase/discord"
+	"fuagfuga-2025-LinkGate/src/usecase/email"
+	"fuagfuga-2025-LinkGate/src/usecase/irc"
+	"fuagfuga-2025-LinkGate/src/usecase/matrix"
+	"fuagfuga-2025-LinkGate/src/usecase/mattermost"
+	"fuagfuga-2025-LinkGate/src/usecase/scheduler"
+	"fuagfuga-2025-LinkGate/src/usecase/search"
+	"fuagfuga-2025-LinkGate/src/usecase/telegram"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"fuagfuga-2025-LinkGate/src/usecase/web"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -38,8 +53,59 @@ func main() {
 	db := client.Database("linkgate")
 	collection := db.Collection("posts")
 
-	// Gin エンジンを初期化
-	r := gin.Default()
+	// メッセージの一覧取得用のインデックス
+	if err := usecase.EnsureMessageIndexes(collection); err != nil {
+		log.Printf("メッセージのインデックス作成に失敗しました: %v", err)
+	}
+
+	// 全文検索用のインデックス
+	if err := search.Init(collection); err != nil {
+		log.Printf("全文検索の初期化に失敗しました: %v", err)
+	}
+
+	// 実行時に作成されたブリッジを読み込む
+	if err := bridge.Init(db.Collection("bridges")); err != nil {
+		log.Printf("ブリッジの読み込みに失敗しました: %v", err)
+	}
+	// プラットフォーム間のスレッドの対応関係
+	if err := thread.Init(db.Collection("threads")); err != nil {
+		log.Printf("スレッドの初期化に失敗しました: %v", err)
+	}
+
+	// 転送したメッセージの記録（編集・削除の反映に使用）
+	if err := delivery.Init(db.Collection("deliveries")); err != nil {
+		log.Printf("転送記録の初期化に失敗しました: %v", err)
+	}
+
+	// 外部サービス連携
+	webhook.Init(db.Collection("integrations"), collection)
+	// Web API の認証
+	if err := apikey.Init(db.Collection("api_keys")); err != nil {
+		log.Printf("APIキーの初期化に失敗しました: %v", err)
+	}
+	// Web チャットのゲストのセッション
+	if err := web.Init(db.Collection("webchat_sessions"), collection); err != nil {
+		log.Printf("Webチャットの初期化に失敗しました: %v", err)
+	}
+	// 予約投稿（リーダー選出のロックも MongoDB に保存する）
+	if err := scheduler.Init(db.Collection("schedules"), db.Collection("locks"), collection); err != nil {
+		log.Printf("予約投稿の初期化に失敗しました: %v", err)
+	}
+
+	// Gin エンジンを初期化（アクセスログのトークンは伏せ字にする）
+	r := gin.New()
+	r.Use(middleware.Logger(), gin.Recovery())
+	// X-Forwarded-For を信頼するリバースプロキシ（カンマ区切りのIPまたはCIDR）
+	// 未設定の場合はどのプロキシも信頼せず、接続元のアドレスをクライアントIPとして使用する
+	var trustedProxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatal("TRUSTED_PROXIES の値が正しくありません🥺:", err)
+	}
 
 	// ルーティングのセットアップ
 	router.SetupRoutes(r, collection, ctx, client)
@@ -48,6 +114,18 @@ func main() {
 
 	go discord.InitializeDiscordBot(collection)
 
+	go telegram.InitializeTelegramBot(collection)
+
+	go matrix.InitializeMatrixBridge(collection)
+
+	go irc.InitializeIRCBot(collection)
+
+	go mattermost.InitializeMattermostBot(collection)
+
+	go email.InitializeEmailGateway(collection)
+
+	go scheduler.Run()
+
 	// サーバーを起動
 	if err := r.Run(":8080"); err != nil {
 		log.Fatal("サーバーの起動に失敗🥺:", err)
@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"fuagfuga-2025-LinkGate/src/usecase/telegram"
+)
+
+func TelegramController(c *gin.Context) {
+	// setWebhook で指定したシークレットを検証する
+	if !telegram.VerifyWebhookSecret(c.GetHeader("X-Telegram-Bot-Api-Secret-Token")) {
+		c.Status(http.StatusUnauthorized)
+		return
+	}
+
+	var update telegram.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		log.Println("ParseError:Telegram の Update の解析に失敗しました:", err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	telegram.HandleUpdate(update)
+
+	// 200 OK を返す（それ以外を返すと Telegram が再送する）
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
+)
+
+// 受信するリクエストボディの最大サイズ
+const maxWebhookBodySize = 1 << 20
+
+func WebhookController(c *gin.Context) {
+	integration, err := webhook.Get(c.Param("id"))
+	if err != nil || integration.Disabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "連携が見つかりません"})
+		return
+	}
+
+	// リクエストボディを読み取る（署名の検証にはボディをそのまま使う）
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		log.Println("読み取りエラー:", err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := webhook.Verify(integration.Secret, c.GetHeader(webhook.HeaderTimestamp), c.GetHeader(webhook.HeaderSignature), body); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "認証に失敗しました", "details": err.Error()})
+		return
+	}
+
+	var in webhook.InboundMessage
+	if err := json.Unmarshal(body, &in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	if in.Text == "" && len(in.Attachments) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text または attachments を指定してください"})
+		return
+	}
+
+	message, err := webhook.Receive(integration, in)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, bridge.ErrNotJoined) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": "メッセージの登録に失敗しました", "details": err.Error()})
+		return
+	}
+
+	// 登録したドキュメントを返却
+	c.JSON(http.StatusCreated, message)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/apikey"
+)
+
+// 認証済みのAPIキーを gin.Context に保存するキー
+const apiKeyContextKey = "linkgate.apiKey"
+
+// RequireScope は Authorization: Bearer ヘッダーのAPIキーを検証し、スコープを持つ場合のみリクエストを許可します。
+// 環境変数 LINKGATE_ADMIN_TOKEN と一致するトークンは admin スコープのキーとして扱います（最初のキーの発行用）。
+func RequireScope(scope string) gin.HandlerFunc {
+	adminToken := os.Getenv("LINKGATE_ADMIN_TOKEN")
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="LinkGate"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "APIキーを Authorization: Bearer ヘッダーに指定してください"})
+			return
+		}
+
+		var key model.APIKey
+		if adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+			key = model.APIKey{Name: "LINKGATE_ADMIN_TOKEN", Scopes: []string{model.ScopeAdmin}}
+		} else {
+			authenticated, err := apikey.Authenticate(token)
+			if err == apikey.ErrInvalidKey {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "認証に失敗しました"})
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "認証に失敗しました", "details": err.Error()})
+				return
+			}
+			key = authenticated
+		}
+
+		if !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "APIキーに " + scope + " スコープがありません"})
+			return
+		}
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
+
+// AllowQueryToken は Authorization ヘッダーがない場合に、access_token クエリパラメータをAPIキーとして使用します。
+// ヘッダーを指定できないブラウザの EventSource や WebSocket 用のエンドポイントにのみ使用してください。
+func AllowQueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
+
+// APIKey は RequireScope で認証されたAPIキーを返します。
+func APIKey(c *gin.Context) (model.APIKey, bool) {
+	value, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return model.APIKey{}, false
+	}
+	key, ok := value.(model.APIKey)
+	return key, ok
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "クエリなし", path: "/messages", want: "/messages"},
+		{name: "伏せる対象がない", path: "/messages?limit=10&order=asc", want: "/messages?limit=10&order=asc"},
+		{name: "access_token", path: "/stream?bridgeId=general&access_token=lg_secret", want: "/stream?access_token=REDACTED&bridgeId=general"},
+		{name: "token", path: "/email/inbound?token=abc", want: "/email/inbound?token=REDACTED"},
+		{name: "署名付きURL", path: "/telegram/files/x?exp=1&sig=deadbeef", want: "/telegram/files/x?exp=1&sig=REDACTED"},
+		{name: "同じキーが複数", path: "/stream?access_token=a&access_token=b", want: "/stream?access_token=REDACTED"},
+		{name: "解析できないクエリ", path: "/stream?access_token=%zz", want: "/stream"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactQuery(tt.path); got != tt.want {
+				t.Errorf("redactQuery(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogFormatter(t *testing.T) {
+	line := logFormatter(gin.LogFormatterParams{
+		TimeStamp:  time.Date(2025, 4, 1, 9, 0, 0, 0, time.UTC),
+		StatusCode: http.StatusOK,
+		Latency:    time.Millisecond,
+		ClientIP:   "203.0.113.1",
+		Method:     http.MethodGet,
+		Path:       "/stream?access_token=lg_secret",
+	})
+
+	if strings.Contains(line, "lg_secret") {
+		t.Errorf("ログにトークンが含まれています: %s", line)
+	}
+	for _, want := range []string{"[GIN] 2025/04/01 - 09:00:00", "200", "203.0.113.1", "GET", `"/stream?access_token=REDACTED"`} {
+		if !strings.Contains(line, want) {
+			t.Errorf("log line %q does not contain %q", line, want)
+		}
+	}
+}
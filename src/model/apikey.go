@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+)
+
+// APIキーのスコープ
+const (
+	// メッセージの取得
+	ScopeMessagesRead = "messages:read"
+	// メッセージの投稿
+	ScopeMessagesWrite = "messages:write"
+	// 管理API（全てのスコープを含む）
+	ScopeAdmin = "admin"
+)
+
+var allowedScopes = map[string]bool{
+	ScopeMessagesRead:  true,
+	ScopeMessagesWrite: true,
+	ScopeAdmin:         true,
+}
+
+// IsValidScope はスコープが定義済みかどうかを返します
+func IsValidScope(scope string) bool {
+	return allowedScopes[scope]
+}
+
+type APIKey struct {
+	// キーID
+	ID string `bson:"_id" json:"id"`
+	// キーの名前（用途の説明）
+	Name string `bson:"name" json:"name"`
+	// キーの先頭部分（一覧でキーを見分けるために使用）
+	Prefix string `bson:"prefix" json:"prefix"`
+	// キーの SHA-256 ハッシュ（キーそのものは保存しない）
+	Hash string `bson:"hash" json:"-"`
+	// 許可されたスコープ
+	Scopes []string `bson:"scopes" json:"scopes"`
+	// 紐付いている連携ID（メッセージの投稿者はこの連携になります）
+	IntegrationID string `bson:"integrationId,omitempty" json:"integrationId,omitempty"`
+	// 最後に使用された日時
+	LastUsedAt *time.Time `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	// 失効した日時
+	RevokedAt *time.Time `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+	// 作成日時
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+// HasScope はキーがスコープを持っているかを返します（admin は全てのスコープを含みます）
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package model
+
+type Bridge struct {
+	// ブリッジID
+	ID string `bson:"_id" json:"id"`
+	// ブリッジ名
+	Name string `bson:"name" json:"name"`
+	// ブリッジに参加しているチャンネル
+	Channels []Channel `bson:"channels" json:"channels"`
+}
+
+type Channel struct {
+	// プラットフォーム
+	Platform Platform `bson:"platform" json:"platform"`
+	// チャンネルID（LINEの場合はグループID）
+	ChannelID string `bson:"channelId" json:"channelId"`
+	// ワークスペースID（SlackのチームIDなど。未設定の場合は既定のワークスペース）
+	WorkspaceID string `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Delivery は他のプラットフォームへ転送したメッセージの記録です（編集・削除の反映に使用）
+type Delivery struct {
+	// ドキュメントID
+	ID primitive.ObjectID `bson:"_id" json:"id"`
+	// 転送したメッセージのID
+	MessageID primitive.ObjectID `bson:"messageId" json:"messageId"`
+	// 転送先プラットフォーム
+	Platform Platform `bson:"platform" json:"platform"`
+	// 転送先チャンネルID
+	ChannelID string `bson:"channelId" json:"channelId"`
+	// 転送先ワークスペースID（SlackのチームIDなど）
+	WorkspaceID string `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
+	// 転送先スレッドID（スレッド内に転送した場合のみ）
+	ThreadID string `bson:"threadId,omitempty" json:"threadId,omitempty"`
+	// 転送先プラットフォームでのメッセージID
+	ExternalID string `bson:"externalId" json:"externalId"`
+	// 添付ファイルのみを送信したメッセージ（本文の編集は反映しません）
+	Attachment bool `bson:"attachment,omitempty" json:"attachment,omitempty"`
+	// 転送日時
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
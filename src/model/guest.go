@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// GuestSession は Web チャットのゲストのセッションです
+type GuestSession struct {
+	// セッションID（ゲストのユーザーIDとして使用）
+	ID string `bson:"_id" json:"id"`
+	// セッショントークンの SHA-256 ハッシュ
+	TokenHash string `bson:"tokenHash" json:"-"`
+	// ニックネーム
+	Name string `bson:"name" json:"name"`
+	// 参加している Web チャットのチャンネルID
+	ChannelID string `bson:"channelId" json:"channelId"`
+	// 接続元IPアドレス
+	RemoteAddr string `bson:"remoteAddr" json:"-"`
+	// 作成日時
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	// 有効期限（期限を過ぎたセッションは MongoDB の TTL インデックスで削除されます）
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
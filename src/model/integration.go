@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+type Integration struct {
+	// 連携ID（ブリッジのチャンネルIDとして使用）
+	ID string `bson:"_id" json:"id"`
+	// 連携名（メッセージの投稿者名の既定値）
+	Name string `bson:"name" json:"name"`
+	// 転送先のURL（空の場合は受信のみ）
+	URL string `bson:"url" json:"url"`
+	// 署名に使用する共有シークレット
+	Secret string `bson:"secret" json:"-"`
+	// 無効化されているか
+	Disabled bool `bson:"disabled" json:"disabled"`
+	// 最後に転送した日時
+	LastDeliveryAt *time.Time `bson:"lastDeliveryAt,omitempty" json:"lastDeliveryAt,omitempty"`
+	// 最後の転送のエラー（成功した場合は空）
+	LastError string `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	// 作成日時
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
@@ -11,8 +11,37 @@ type Message struct {
 	ID primitive.ObjectID `bson:"_id" json:"id"`
 	// 投稿者情報
 	User User `bson:"user" json:"user"`
+	// 所属するブリッジID（未設定の場合はデフォルトブリッジ）
+	BridgeID string `bson:"bridgeId,omitempty" json:"bridgeId,omitempty"`
+	// 投稿元チャンネルID
+	ChannelID string `bson:"channelId,omitempty" json:"channelId,omitempty"`
+	// 投稿元ワークスペースID（SlackのチームIDなど）
+	WorkspaceID string `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
+	// 投稿元プラットフォームでのメッセージID（Slackの場合はts）
+	ExternalID string `bson:"externalId,omitempty" json:"externalId,omitempty"`
+	// 投稿元プラットフォームでのスレッドID（スレッド返信の場合のみ）
+	ThreadID string `bson:"threadId,omitempty" json:"threadId,omitempty"`
 	// 投稿内容
 	Content Content `bson:"content" json:"content"`
+	// 転送先の指定（未指定の場合はブリッジ内の全てのチャンネルへ転送します）
+	Targets []Target `bson:"targets,omitempty" json:"targets,omitempty"`
+	// 履歴の取り込みで登録されたメッセージ（他プラットフォームへは転送しません）
+	Imported bool `bson:"imported,omitempty" json:"imported,omitempty"`
 	// 作成日時
 	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	// 編集日時（Web API で編集された場合のみ）
+	EditedAt *time.Time `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+	// 削除日時（Web API で削除された場合のみ。削除されたメッセージは取得できません）
+	DeletedAt *time.Time `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+}
+
+// Target はメッセージの転送先の指定です。
+// 指定した項目がすべて一致するチャンネルへ転送し、複数指定した場合はいずれかに一致するチャンネルへ転送します。
+type Target struct {
+	// プラットフォーム
+	Platform Platform `bson:"platform,omitempty" json:"platform,omitempty"`
+	// ブリッジID（未設定の場合は投稿先のブリッジ）
+	BridgeID string `bson:"bridgeId,omitempty" json:"bridgeId,omitempty"`
+	// チャンネルID
+	ChannelID string `bson:"channelId,omitempty" json:"channelId,omitempty"`
 }
@@ -1,9 +1,17 @@
 package model
 
 const (
-	PlatformLINE    Platform = "LINE"
-	PlatformDiscord Platform = "Discord"
-	PlatformSlack   Platform = "Slack"
+	PlatformLINE       Platform = "LINE"
+	PlatformDiscord    Platform = "Discord"
+	PlatformSlack      Platform = "Slack"
+	PlatformTelegram   Platform = "Telegram"
+	PlatformMatrix     Platform = "Matrix"
+	PlatformIRC        Platform = "IRC"
+	PlatformMattermost Platform = "Mattermost"
+	PlatformEmail      Platform = "Email"
+	PlatformWebhook    Platform = "Webhook"
+	PlatformAPI        Platform = "API"
+	PlatformWeb        Platform = "Web"
 )
 
 type Platform string
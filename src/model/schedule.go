@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+)
+
+// 予約投稿の状態
+const (
+	// 実行待ち
+	ScheduleActive = "active"
+	// 一時停止中
+	SchedulePaused = "paused"
+	// 実行済み（1回のみの予約投稿）
+	ScheduleCompleted = "completed"
+	// 取り消し済み
+	ScheduleCanceled = "canceled"
+)
+
+type Schedule struct {
+	// 予約投稿ID
+	ID string `bson:"_id" json:"id"`
+	// 予約投稿の名前（一覧で見分けるために使用）
+	Name string `bson:"name" json:"name"`
+	// 投稿する連携ID（メッセージの投稿者はこの連携になります）
+	IntegrationID string `bson:"integrationId" json:"integrationId"`
+	// 投稿内容
+	Content Content `bson:"content" json:"content"`
+	// 転送先のブリッジ（未設定の場合は連携が参加しているブリッジ）
+	BridgeIDs []string `bson:"bridgeIds,omitempty" json:"bridgeIds,omitempty"`
+	// 登録したAPIキーのID（投稿先以外のブリッジへの転送の権限を実行時に確認するために使用）
+	APIKeyID string `bson:"apiKeyId,omitempty" json:"apiKeyId,omitempty"`
+	// 投稿日時（1回のみの場合）
+	At *time.Time `bson:"at,omitempty" json:"at,omitempty"`
+	// cron 式（繰り返しの場合。「分 時 日 月 曜日」の5項目）
+	Cron string `bson:"cron,omitempty" json:"cron,omitempty"`
+	// cron 式を解釈するタイムゾーン
+	Timezone string `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	// 状態（active / paused / completed / canceled）
+	Status string `bson:"status" json:"status"`
+	// 次回の投稿日時
+	NextRunAt *time.Time `bson:"nextRunAt,omitempty" json:"nextRunAt,omitempty"`
+	// 最後に投稿した日時
+	LastRunAt *time.Time `bson:"lastRunAt,omitempty" json:"lastRunAt,omitempty"`
+	// 投稿した回数
+	RunCount int `bson:"runCount" json:"runCount"`
+	// 最後の投稿のエラー（成功した場合は空）
+	LastError string `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	// 続けて投稿に失敗した回数（1回のみの予約投稿の再試行に使用）
+	Failures int `bson:"failures,omitempty" json:"failures,omitempty"`
+	// 作成日時
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ThreadLink struct {
+	// ドキュメントID
+	ID primitive.ObjectID `bson:"_id" json:"id"`
+	// 所属するブリッジID
+	BridgeID string `bson:"bridgeId" json:"bridgeId"`
+	// スレッドのタイトル
+	Title string `bson:"title" json:"title"`
+	// 各プラットフォームで対応するスレッド
+	Threads []ThreadRef `bson:"threads" json:"threads"`
+	// 作成日時
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+type ThreadRef struct {
+	// プラットフォーム
+	Platform Platform `bson:"platform" json:"platform"`
+	// スレッドが属するチャンネルID
+	ChannelID string `bson:"channelId" json:"channelId"`
+	// スレッドID（Discordの場合はスレッドのチャンネルID、Slackの場合は親メッセージのts）
+	ThreadID string `bson:"threadId" json:"threadId"`
+}
@@ -21,9 +21,23 @@ type User struct {
 }
 
 var allowedPlatforms = map[Platform]struct{}{
-	PlatformLINE:    {},
-	PlatformDiscord: {},
-	PlatformSlack:   {},
+	PlatformLINE:       {},
+	PlatformDiscord:    {},
+	PlatformSlack:      {},
+	PlatformTelegram:   {},
+	PlatformMatrix:     {},
+	PlatformIRC:        {},
+	PlatformMattermost: {},
+	PlatformEmail:      {},
+	PlatformWebhook:    {},
+	PlatformAPI:        {},
+	PlatformWeb:        {},
+}
+
+// IsValid は定義済みのプラットフォームかどうかを返します
+func (p Platform) IsValid() bool {
+	_, ok := allowedPlatforms[p]
+	return ok
 }
 
 // プラットフォームのバリデーション
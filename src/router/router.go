@@ -3,9 +3,19 @@ package router
 import (
 	"context"
 	"fuagfuga-2025-LinkGate/src/controller"
+	"fuagfuga-2025-LinkGate/src/middleware"
+	"fuagfuga-2025-LinkGate/src/model"
 	"fuagfuga-2025-LinkGate/src/service"
+	"fuagfuga-2025-LinkGate/src/usecase/discord"
+	"fuagfuga-2025-LinkGate/src/usecase/email"
+	"fuagfuga-2025-LinkGate/src/usecase/matrix"
+	"fuagfuga-2025-LinkGate/src/usecase/mattermost"
+	"fuagfuga-2025-LinkGate/src/usecase/realtime"
 	"fuagfuga-2025-LinkGate/src/usecase/slack"
+	"fuagfuga-2025-LinkGate/src/usecase/telegram"
+	"fuagfuga-2025-LinkGate/src/usecase/web"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -19,50 +29,157 @@ func SetupRoutes(r *gin.Engine, collection *mongo.Collection, ctx context.Contex
 		})
 	})
 
-	// GET /messages: 登録されている全ての投稿を取得します。
-	r.GET("/messages", func(c *gin.Context) {
+	// GET /messages: 登録されている投稿を新しい順に取得します。
+	// ?limit=50&cursor=...&platform=Discord&bridgeId=...&since=...&hasAttachments=true で絞り込みできます
+	r.GET("/messages", middleware.RequireScope(model.ScopeMessagesRead), func(c *gin.Context) {
 		service.GetMessages(c, collection)
 	})
 
-	// POST /post: 新規投稿を作成します。
-	r.POST("/post", func(c *gin.Context) {
+	// POST /post: 新規投稿を作成します。投稿者はAPIキーに紐付いた連携になります。
+	r.POST("/post", middleware.RequireScope(model.ScopeMessagesWrite), func(c *gin.Context) {
 		service.CreateMessage(c, collection)
 	})
 
+	// GET /messages/search: 投稿を全文検索します。
+	// ?q=会場 住所&platform=LINE&since=... のように GET /messages と同じ条件で絞り込みできます
+	r.GET("/messages/search", middleware.RequireScope(model.ScopeMessagesRead), func(c *gin.Context) {
+		service.SearchMessages(c, collection)
+	})
+
+	// GET /messages/:id: 投稿を1件取得します。
+	r.GET("/messages/:id", middleware.RequireScope(model.ScopeMessagesRead), func(c *gin.Context) {
+		service.GetMessage(c, collection)
+	})
+
+	// PATCH /messages/:id: APIキーの連携が投稿したメッセージの本文を編集し、転送先にも反映します。
+	r.PATCH("/messages/:id", middleware.RequireScope(model.ScopeMessagesWrite), func(c *gin.Context) {
+		service.UpdateMessage(c, collection)
+	})
+
+	// DELETE /messages/:id: APIキーの連携が投稿したメッセージを削除し、転送先からも削除します。
+	r.DELETE("/messages/:id", middleware.RequireScope(model.ScopeMessagesWrite), func(c *gin.Context) {
+		service.DeleteMessage(c, collection)
+	})
+
 	// DELETE /messages: 登録されている全ての投稿を削除します。
-	r.DELETE("/messages", func(c *gin.Context) {
+	r.DELETE("/messages", middleware.RequireScope(model.ScopeAdmin), func(c *gin.Context) {
 		service.DeleteAllMessage(c, collection)
 	})
 
+	// === 予約投稿 ===
+	// 1回のみ（at）または cron 式による繰り返しの投稿を登録します。投稿者はAPIキーに紐付いた連携になります
+	schedules := r.Group("/schedules", middleware.RequireScope(model.ScopeMessagesWrite))
+	schedules.GET("", service.GetSchedules)
+	schedules.POST("", service.CreateSchedule)
+	schedules.GET("/:id", service.GetSchedule)
+	schedules.POST("/:id/pause", service.PauseSchedule)
+	schedules.POST("/:id/resume", service.ResumeSchedule)
+	schedules.DELETE("/:id", service.CancelSchedule)
+
+	// === リアルタイム配信 ===
+	// メッセージの追加・編集・削除を配信します。?bridgeId= で絞り込み、Last-Event-ID で続きから受信できます
+	r.GET("/stream", middleware.AllowQueryToken(), middleware.RequireScope(model.ScopeMessagesRead), realtime.HandleSSE)
+	r.GET("/stream/ws", middleware.AllowQueryToken(), middleware.RequireScope(model.ScopeMessagesRead), realtime.HandleWebSocket)
+
+	// === APIキー ===
+	// APIキーの管理（admin スコープが必要。最初のキーは LINKGATE_ADMIN_TOKEN で発行します）
+	apiKeys := r.Group("/apikeys", middleware.RequireScope(model.ScopeAdmin))
+	apiKeys.GET("", service.GetAPIKeys)
+	apiKeys.POST("", service.CreateAPIKey)
+	apiKeys.DELETE("/:id", service.RevokeAPIKey)
+
 	// ヘルスチェック: サーバーと DB の状態をチェック
 	r.GET("/health", func(c *gin.Context) {
 		if err := client.Ping(ctx, nil); err != nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"status":   "unhealthy",
 				"database": "disconnected",
+				"discord":  discord.Status(),
 			})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
 			"status":   "healthy",
 			"database": "connected",
+			"discord":  discord.Status(),
 		})
 	})
 
-	// === LINE API ===
+	// === 外部サービス連携（Webhook） ===
+	// 連携の管理（admin スコープが必要）
+	integrations := r.Group("/integrations", middleware.RequireScope(model.ScopeAdmin))
+	integrations.GET("", service.GetIntegrations)
+	integrations.POST("", service.CreateIntegration)
+	integrations.DELETE("/:id", service.DeleteIntegration)
+	// 連携からのメッセージ投稿（署名による認証）
+	r.POST("/webhooks/:id/messages", controller.WebhookController)
+
+	// === WEB チャット ===
+	// チャット画面とゲストのエンドポイント
+	web.RegisterRoutes(r)
+	// Web チャットの有効化・無効化（admin スコープが必要）
+	webChat := r.Group("/webchat/channels", middleware.RequireScope(model.ScopeAdmin))
+	webChat.POST("", service.EnableWebChat)
+	webChat.DELETE("/:channelId", service.DisableWebChat)
+
 	// === LINE API ===
 	// webhookのイベントをキャッチ
 	r.Any("/linehook", func(c *gin.Context) {
 		controller.LINEController(c, collection)
 	})
 
+	// === TELEGRAM API ===
+	// ポーリング方式の場合やシークレットが未設定の場合は Webhook を受け付けない
+	if telegram.WebhookEnabled() {
+		r.POST("/telegram/webhook", controller.TelegramController)
+	}
+	// Telegram の画像を Bot トークンを公開せずに配信する（署名付きURLのみ）
+	r.GET("/telegram/files/:fileId", telegram.HandleFile)
+
+	// === MATRIX API ===
+	// ホームサーバーから呼び出される Application Service API
+	matrix.RegisterRoutes(r)
+
+	// === MATTERMOST API ===
+	// Mattermost の添付ファイル・アイコンは認証が必要なため LinkGate 経由で配信する
+	r.GET("/mattermost/files/:fileId", mattermost.HandleFile)
+	r.GET("/mattermost/users/:userId/image", mattermost.HandleUserImage)
+
+	// === EMAIL ===
+	// 受信メール Webhook（SendGrid Inbound Parse・Mailgun Routes など）
+	r.POST("/email/inbound", email.HandleInbound)
+
 	// === SLACK API ===
 	slackHandler := slack.NewSlackHandler(collection, ctx)
-	r.POST("/slack/events", slackHandler.HandleSlackEvents)
+	if slack.IsSocketMode() {
+		// Socket Mode の場合は公開エンドポイントを使わずWebSocketでイベントを受信
+		go slackHandler.RunSocketMode()
+	} else {
+		r.POST("/slack/events", slackHandler.HandleSlackEvents)
+	}
+
+	// 複数ワークスペースへのインストール（OAuth v2）
+	r.GET("/slack/install", slackHandler.HandleInstall)
+	r.GET("/slack/oauth/callback", slackHandler.HandleOAuthRedirect)
+
+	// Slackから投稿されたメッセージを取得するエンドポイント
+	// ?channel=C0123&since=2025-01-01T00:00:00Z&until=2025-02-01T00:00:00Z で絞り込みできます
+	r.GET("/slack/messages", middleware.RequireScope(model.ScopeMessagesRead), func(c *gin.Context) {
+		filter := slack.MessageFilter{ChannelID: c.Query("channel")}
+		for key, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+			value := c.Query(key)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " は RFC3339 形式で指定してください", "details": err.Error()})
+				return
+			}
+			*dst = t
+		}
 
-	// Slackメッセージを取得するエンドポイント
-	r.GET("/slack/messages", func(c *gin.Context) {
-		messages, err := slackHandler.GetSlackMessages()
+		messages, err := slackHandler.GetSlackMessages(filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
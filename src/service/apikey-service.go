@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/apikey"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIキーの発行リクエスト
+type createAPIKeyRequest struct {
+	// キーの名前
+	Name string `json:"name" binding:"required"`
+	// 許可するスコープ
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// 紐付ける連携ID（messages:write スコープの場合は必須）
+	IntegrationID string `json:"integrationId"`
+}
+
+// APIキーの一覧を取得
+func GetAPIKeys(c *gin.Context) {
+	keys, err := apikey.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// APIキーを発行
+func CreateAPIKey(c *gin.Context) {
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !model.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不明なスコープです: " + scope})
+			return
+		}
+	}
+
+	// 投稿できるキーは連携に紐付け、投稿者を連携に固定する
+	writable := model.APIKey{Scopes: req.Scopes}.HasScope(model.ScopeMessagesWrite)
+	if writable && req.IntegrationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "messages:write スコープのキーには integrationId を指定してください"})
+		return
+	}
+	if req.IntegrationID != "" {
+		if _, err := webhook.Get(req.IntegrationID); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, webhook.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": "連携が見つかりません", "details": err.Error()})
+			return
+		}
+	}
+
+	key, raw, err := apikey.Create(req.Name, req.IntegrationID, req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ登録に失敗しました", "details": err.Error()})
+		return
+	}
+
+	// キーは発行時にのみ返却する
+	c.JSON(http.StatusCreated, gin.H{"apiKey": key, "key": raw})
+}
+
+// APIキーを失効
+func RevokeAPIKey(c *gin.Context) {
+	if err := apikey.Revoke(c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, apikey.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": "APIキーの失効に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "APIキーを失効しました"})
+}
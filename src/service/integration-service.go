@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/apikey"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/scheduler"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 連携の登録リクエスト
+type createIntegrationRequest struct {
+	// 連携名
+	Name string `json:"name" binding:"required"`
+	// 転送先のURL（空の場合は受信のみ）
+	URL string `json:"url"`
+	// 参加するブリッジ
+	BridgeID string `json:"bridgeId" binding:"required"`
+}
+
+// 連携の一覧を取得
+func GetIntegrations(c *gin.Context) {
+	integrations, err := webhook.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, integrations)
+}
+
+// 連携を登録し、ブリッジに参加させる
+func CreateIntegration(c *gin.Context) {
+	var req createIntegrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	if req.URL != "" {
+		if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url は http(s) のURLを指定してください"})
+			return
+		}
+	}
+	if _, ok := bridge.Get(req.BridgeID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ブリッジが見つかりません"})
+		return
+	}
+
+	integration, secret, err := webhook.Create(req.Name, req.URL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ登録に失敗しました", "details": err.Error()})
+		return
+	}
+	if _, err := bridge.Join(req.BridgeID, model.Channel{Platform: model.PlatformWebhook, ChannelID: integration.ID}); err != nil {
+		webhook.Delete(integration.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ブリッジへの参加に失敗しました", "details": err.Error()})
+		return
+	}
+
+	// シークレットは登録時にのみ返却する
+	c.JSON(http.StatusCreated, gin.H{"integration": integration, "secret": secret})
+}
+
+// 連携を削除し、ブリッジから退出させる
+func DeleteIntegration(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := bridge.Leave(model.Channel{Platform: model.PlatformWebhook, ChannelID: id}); err != nil && !errors.Is(err, bridge.ErrNotJoined) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ブリッジからの退出に失敗しました", "details": err.Error()})
+		return
+	}
+	if err := webhook.Delete(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, webhook.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": "連携の削除に失敗しました", "details": err.Error()})
+		return
+	}
+	// 連携に紐付いたAPIキーは使えなくなるため失効させる
+	if err := apikey.RevokeByIntegration(id); err != nil {
+		log.Printf("APIキーの失効に失敗しました (integration: %s): %v", id, err)
+	}
+	// 連携の予約投稿は実行できなくなるため取り消す
+	if err := scheduler.CancelByIntegration(id); err != nil {
+		log.Printf("予約投稿の取り消しに失敗しました (integration: %s): %v", id, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "連携を削除しました"})
+}
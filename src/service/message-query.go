@@ -0,0 +1,186 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// メッセージ一覧の1ページあたりの件数
+const (
+	defaultMessageLimit = 50
+	maxMessageLimit     = 200
+)
+
+// messageQuery は GET /messages のクエリパラメータです
+type messageQuery struct {
+	filter    bson.M
+	limit     int64
+	ascending bool
+	// 前のページの最後のメッセージ（cursor を指定した場合のみ）
+	cursor *pageCursor
+}
+
+// pageCursor はページングの位置です
+type pageCursor struct {
+	createdAt time.Time
+	id        primitive.ObjectID
+}
+
+// parseMessageQuery はクエリパラメータから MongoDB の検索条件を作成します。
+//
+//	limit=50            取得件数（最大200）
+//	order=desc          並び順（asc: 古い順 / desc: 新しい順）
+//	cursor=...          前のページの nextCursor
+//	platform=Discord    投稿元プラットフォーム
+//	userId=...          投稿者のユーザーID
+//	bridgeId=...        ブリッジID（デフォルトブリッジの場合は bridgeId が未設定のメッセージも含む）
+//	since=, until=      投稿日時の範囲（RFC3339）
+//	hasAttachments=true 添付ファイルの有無
+func parseMessageQuery(c *gin.Context) (messageQuery, error) {
+	// 削除されたメッセージは返さない
+	q := messageQuery{filter: bson.M{"deletedAt": bson.M{"$exists": false}}, limit: defaultMessageLimit}
+
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 {
+			return q, errors.New("limit は1以上の整数で指定してください")
+		}
+		q.limit = min(n, maxMessageLimit)
+	}
+
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		q.ascending = true
+	case "desc":
+	default:
+		return q, errors.New("order は asc または desc を指定してください")
+	}
+
+	if value := c.Query("platform"); value != "" {
+		if !model.Platform(value).IsValid() {
+			return q, errors.New("platformの値が無効です: " + value)
+		}
+		q.filter["user.platform"] = value
+	}
+	if value := c.Query("userId"); value != "" {
+		q.filter["user.userId"] = value
+	}
+	if value := c.Query("bridgeId"); value != "" {
+		q.filter["bridgeId"] = bridge.MessageFilter(value)
+	}
+
+	createdAt := bson.M{}
+	for key, op := range map[string]string{"since": "$gte", "until": "$lt"} {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return q, errors.New(key + " は RFC3339 形式で指定してください")
+		}
+		createdAt[op] = t
+	}
+	if len(createdAt) > 0 {
+		q.filter["createdAt"] = createdAt
+	}
+
+	if value := c.Query("hasAttachments"); value != "" {
+		has, err := strconv.ParseBool(value)
+		if err != nil {
+			return q, errors.New("hasAttachments は true または false を指定してください")
+		}
+		q.filter["content.attachments.0"] = bson.M{"$exists": has}
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		t, id, err := decodeCursor(value)
+		if err != nil {
+			return q, err
+		}
+		q.cursor = &pageCursor{createdAt: t, id: id}
+	}
+	return q, nil
+}
+
+// pageFilter は検索条件に、前のページの最後のメッセージより後（desc の場合は前）の条件を加えて返します。
+func (q messageQuery) pageFilter() bson.M {
+	if q.cursor == nil {
+		return q.filter
+	}
+	op := "$lt"
+	if q.ascending {
+		op = "$gt"
+	}
+	filter := bson.M{}
+	for key, value := range q.filter {
+		filter[key] = value
+	}
+	filter["$or"] = bson.A{
+		bson.M{"createdAt": bson.M{op: q.cursor.createdAt}},
+		bson.M{"createdAt": q.cursor.createdAt, "_id": bson.M{op: q.cursor.id}},
+	}
+	return filter
+}
+
+// after はメッセージが前のページの最後のメッセージより後（desc の場合は前）かを返します。
+func (q messageQuery) after(message model.Message) bool {
+	if q.cursor == nil {
+		return true
+	}
+	t := message.CreatedAt.UnixMilli()
+	cursorTime := q.cursor.createdAt.UnixMilli()
+	if q.ascending {
+		return t > cursorTime || (t == cursorTime && message.ID.Hex() > q.cursor.id.Hex())
+	}
+	return t < cursorTime || (t == cursorTime && message.ID.Hex() < q.cursor.id.Hex())
+}
+
+// findOptions は並び順と取得件数を返します。次のページがあるかを判定するため1件多く取得します。
+func (q messageQuery) findOptions() *options.FindOptions {
+	order := -1
+	if q.ascending {
+		order = 1
+	}
+	return options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: order}, {Key: "_id", Value: order}}).
+		SetLimit(q.limit + 1)
+}
+
+// カーソルは「作成日時（ミリ秒）_ドキュメントID」を Base64 にしたもの
+func encodeCursor(message model.Message) string {
+	raw := strconv.FormatInt(message.CreatedAt.UnixMilli(), 10) + "_" + message.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	errInvalid := errors.New("cursor が正しくありません")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalid
+	}
+	millis, hexID, ok := strings.Cut(string(raw), "_")
+	if !ok {
+		return time.Time{}, primitive.NilObjectID, errInvalid
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalid
+	}
+	id, err := primitive.ObjectIDFromHex(hexID)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, errInvalid
+	}
+	return time.UnixMilli(ms), id, nil
+}
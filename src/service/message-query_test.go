@@ -0,0 +1,174 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// クエリ文字列から gin.Context を作成する
+func queryContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/messages?"+query, nil)
+	return c
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+	}{
+		{name: "現在時刻", createdAt: time.Now()},
+		{name: "ミリ秒未満を含む", createdAt: time.Date(2025, 4, 1, 9, 0, 0, 123456789, time.UTC)},
+		{name: "Unix エポック", createdAt: time.UnixMilli(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := model.Message{ID: primitive.NewObjectID(), CreatedAt: tt.createdAt}
+			createdAt, id, err := decodeCursor(encodeCursor(message))
+			if err != nil {
+				t.Fatalf("decodeCursor() error: %v", err)
+			}
+			if id != message.ID {
+				t.Errorf("id = %s, want %s", id.Hex(), message.ID.Hex())
+			}
+			// カーソルはミリ秒単位で保持する
+			if createdAt.UnixMilli() != tt.createdAt.UnixMilli() {
+				t.Errorf("createdAt = %v, want %v", createdAt, tt.createdAt)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []string{
+		"not base64!",
+		"MTIz",                                   // 123（区切りなし）
+		"YWJjXzY1ZjAwMDAwMDAwMDAwMDAwMDAwMDAwMA", // abc_65f0...（日時が数値でない）
+		"MTIzX3h5eg",                             // 123_xyz（IDが不正）
+	}
+
+	for _, cursor := range tests {
+		if _, _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded, want error", cursor)
+		}
+	}
+}
+
+func TestParseMessageQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+		check   func(t *testing.T, q messageQuery)
+	}{
+		{
+			name:  "既定値",
+			query: "",
+			check: func(t *testing.T, q messageQuery) {
+				if q.limit != defaultMessageLimit || q.ascending {
+					t.Errorf("limit = %d, ascending = %v", q.limit, q.ascending)
+				}
+			},
+		},
+		{
+			name:  "limit の上限",
+			query: "limit=1000&order=asc",
+			check: func(t *testing.T, q messageQuery) {
+				if q.limit != maxMessageLimit || !q.ascending {
+					t.Errorf("limit = %d, ascending = %v", q.limit, q.ascending)
+				}
+			},
+		},
+		{
+			name:  "デフォルトブリッジは bridgeId が未設定のメッセージも含む",
+			query: "bridgeId=default",
+			check: func(t *testing.T, q messageQuery) {
+				want := bson.M{"$in": bson.A{"default", "", nil}}
+				if !reflect.DeepEqual(q.filter["bridgeId"], want) {
+					t.Errorf("bridgeId filter = %v, want %v", q.filter["bridgeId"], want)
+				}
+			},
+		},
+		{
+			name:  "存在しないブリッジ",
+			query: "bridgeId=unknown",
+			check: func(t *testing.T, q messageQuery) {
+				if q.filter["bridgeId"] != "unknown" {
+					t.Errorf("bridgeId filter = %v, want unknown", q.filter["bridgeId"])
+				}
+			},
+		},
+		{
+			name:  "添付ファイルあり",
+			query: "hasAttachments=true&platform=Discord",
+			check: func(t *testing.T, q messageQuery) {
+				if !reflect.DeepEqual(q.filter["content.attachments.0"], bson.M{"$exists": true}) || q.filter["user.platform"] != "Discord" {
+					t.Errorf("filter = %v", q.filter)
+				}
+			},
+		},
+		{name: "limit が0", query: "limit=0", wantErr: true},
+		{name: "不正な order", query: "order=random", wantErr: true},
+		{name: "不正な platform", query: "platform=Unknown", wantErr: true},
+		{name: "不正な since", query: "since=yesterday", wantErr: true},
+		{name: "不正な hasAttachments", query: "hasAttachments=maybe", wantErr: true},
+		{name: "不正な cursor", query: "cursor=abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseMessageQuery(queryContext(tt.query))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("parseMessageQuery() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMessageQuery() error: %v", err)
+			}
+			tt.check(t, q)
+		})
+	}
+}
+
+func TestMessageQueryAfter(t *testing.T) {
+	base := time.UnixMilli(1700000000000)
+	cursorID := primitive.NewObjectIDFromTimestamp(base)
+	earlierID := primitive.NewObjectIDFromTimestamp(base.Add(-time.Second))
+	laterID := primitive.NewObjectIDFromTimestamp(base.Add(time.Second))
+
+	tests := []struct {
+		name      string
+		ascending bool
+		message   model.Message
+		want      bool
+	}{
+		{name: "desc で古いメッセージ", message: model.Message{ID: laterID, CreatedAt: base.Add(-time.Minute)}, want: true},
+		{name: "desc で新しいメッセージ", message: model.Message{ID: earlierID, CreatedAt: base.Add(time.Minute)}, want: false},
+		{name: "desc で同じ日時の小さいID", message: model.Message{ID: earlierID, CreatedAt: base}, want: true},
+		{name: "desc でカーソル自身", message: model.Message{ID: cursorID, CreatedAt: base}, want: false},
+		{name: "asc で新しいメッセージ", ascending: true, message: model.Message{ID: earlierID, CreatedAt: base.Add(time.Minute)}, want: true},
+		{name: "asc で同じ日時の大きいID", ascending: true, message: model.Message{ID: laterID, CreatedAt: base}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := messageQuery{ascending: tt.ascending, cursor: &pageCursor{createdAt: base, id: cursorID}}
+			if got := q.after(tt.message); got != tt.want {
+				t.Errorf("after() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"fuagfuga-2025-LinkGate/src/middleware"
 	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
 	"net/http"
 	"time"
 
@@ -10,42 +13,113 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// 全てのメッセージを取得
+// メッセージを取得（カーソルによるページングと絞り込みに対応）
 func GetMessages(c *gin.Context, collection *mongo.Collection) {
+	q, err := parseMessageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+
 	// タイムアウト付き context を作成
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	// すべてのドキュメントを取得
-	cur, err := collection.Find(ctx, bson.M{})
+	// 条件に一致するドキュメントを取得
+	cur, err := collection.Find(ctx, q.pageFilter(), q.findOptions())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
 		return
 	}
 	defer cur.Close(ctx)
-	var messages []model.Message
+	messages := []model.Message{}
 	if err := cur.All(ctx, &messages); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "データのパースに失敗しました", "details": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, messages)
+
+	// 1件多く取得できた場合は次のページがある
+	var nextCursor *string
+	if int64(len(messages)) > q.limit {
+		messages = messages[:q.limit]
+		cursor := encodeCursor(messages[len(messages)-1])
+		nextCursor = &cursor
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "nextCursor": nextCursor})
+}
+
+// メッセージの作成リクエスト
+// クライアントが指定できる項目のみを受け付け、それ以外（ID・日時・スレッドなど）はサーバー側で設定する
+type createMessageRequest struct {
+	// 投稿内容
+	Content struct {
+		// 本文
+		Text string `json:"text"`
+		// 添付ファイル
+		Attachments []model.Attachment `json:"attachments"`
+	} `json:"content"`
+	// 投稿者（表示名の指定が許可されている場合のみ name を使用する）
+	User struct {
+		Name string `json:"name"`
+	} `json:"user"`
+	// 転送先の指定
+	Targets []model.Target `json:"targets"`
 }
 
 // 新規メッセージを作成
+// 投稿者はAPIキーに紐付いた連携（API プラットフォーム）に固定し、リクエストで任意のユーザーを名乗れないようにする
+// targets を指定した場合は、一致するプラットフォーム・ブリッジ・チャンネルにのみ転送する
 func CreateMessage(c *gin.Context, collection *mongo.Collection) {
-	var message model.Message
+	key, _ := middleware.APIKey(c)
+	if key.IntegrationID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "投稿には連携に紐付いたAPIキーが必要です"})
+		return
+	}
+	integration, err := webhook.Get(key.IntegrationID)
+	if err != nil || integration.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "APIキーに紐付いた連携が見つからないか、無効化されています"})
+		return
+	}
+
+	var req createMessageRequest
 
 	// リクエスト JSON を構造体にバインド
-	if err := c.ShouldBindJSON(&message); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
 		return
 	}
-	// ID と作成日時を設定
-	message.ID = primitive.NewObjectID()
-	message.Content.ID = primitive.NewObjectID()
-	message.User.ID = primitive.NewObjectID()
-	message.CreatedAt = time.Now()
+	if req.Content.Text == "" && len(req.Content.Attachments) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "本文または添付ファイルを指定してください"})
+		return
+	}
+	user, err := webhook.APIUser(integration, req.User.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "表示名を使用できません", "details": err.Error()})
+		return
+	}
+
+	// 投稿者と投稿先を連携から決定してメッセージを組み立てる
+	message := model.Message{
+		ID:   primitive.NewObjectID(),
+		User: user,
+		Content: model.Content{
+			ID:          primitive.NewObjectID(),
+			Text:        req.Content.Text,
+			Attachments: req.Content.Attachments,
+		},
+		ChannelID: integration.ID,
+		Targets:   req.Targets,
+		CreatedAt: time.Now(),
+	}
+	if b, ok := bridge.Find(model.PlatformWebhook, integration.ID); ok {
+		message.BridgeID = b.ID
+	}
+	if err := validateTargets(key, message); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "転送先の指定が正しくありません", "details": err.Error()})
+		return
+	}
 
 	// MongoDB にドキュメントを挿入
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -58,6 +132,113 @@ func CreateMessage(c *gin.Context, collection *mongo.Collection) {
 	c.JSON(http.StatusCreated, message)
 }
 
+// メッセージを1件取得
+func GetMessage(c *gin.Context, collection *mongo.Collection) {
+	message, ok := findMessage(c, collection)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// メッセージの編集リクエスト
+type updateMessageRequest struct {
+	// 本文
+	Text string `json:"text" binding:"required"`
+}
+
+// メッセージの本文を編集（転送先への反映は change stream で行う）
+func UpdateMessage(c *gin.Context, collection *mongo.Collection) {
+	var req updateMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+
+	message, ok := findMessage(c, collection)
+	if !ok {
+		return
+	}
+	// 編集できるのは自分の連携が投稿したメッセージのみ
+	key, _ := middleware.APIKey(c)
+	if !ownsMessage(key, message) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "このメッセージを編集する権限がありません"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": message.ID, "deletedAt": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"content.text": req.Text, "editedAt": time.Now()}}
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "メッセージが見つかりません"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ更新に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// メッセージを削除（論理削除。転送先からの削除は change stream で行う）
+func DeleteMessage(c *gin.Context, collection *mongo.Collection) {
+	message, ok := findMessage(c, collection)
+	if !ok {
+		return
+	}
+	// 削除できるのは自分の連携が投稿したメッセージのみ（admin スコープは全てのメッセージを削除できる）
+	key, _ := middleware.APIKey(c)
+	if !ownsMessage(key, message) && !key.HasScope(model.ScopeAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "このメッセージを削除する権限がありません"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.M{"_id": message.ID, "deletedAt": bson.M{"$exists": false}}
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"deletedAt": time.Now()}})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ削除に失敗しました", "details": err.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "メッセージが見つかりません"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "メッセージを削除しました"})
+}
+
+// パスパラメータのIDのメッセージを取得する（見つからない場合はレスポンスを返して false）
+func findMessage(c *gin.Context, collection *mongo.Collection) (model.Message, bool) {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "メッセージが見つかりません"})
+		return model.Message{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var message model.Message
+	err = collection.FindOne(ctx, bson.M{"_id": id, "deletedAt": bson.M{"$exists": false}}).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, gin.H{"error": "メッセージが見つかりません"})
+		return model.Message{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return model.Message{}, false
+	}
+	return message, true
+}
+
+// APIキーの連携が Web API で投稿したメッセージかを判定する
+func ownsMessage(key model.APIKey, message model.Message) bool {
+	return key.IntegrationID != "" && message.User.Platform == model.PlatformAPI && message.User.UserID == key.IntegrationID
+}
+
 // 全てのメッセージを削除
 func DeleteAllMessage(c *gin.Context, collection *mongo.Collection) {
 	// タイムアウト付き context を作成
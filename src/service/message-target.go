@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+)
+
+// 1件のメッセージに指定できる転送先の最大数
+const maxTargets = 20
+
+var errNoDestinations = errors.New("指定された転送先に一致するチャンネルがありません")
+
+// validateTargets はメッセージの転送先の指定を検証します。
+// 投稿先以外のブリッジへの転送には admin スコープが必要です。
+func validateTargets(key model.APIKey, message model.Message) error {
+	if len(message.Targets) == 0 {
+		return nil
+	}
+	if len(message.Targets) > maxTargets {
+		return fmt.Errorf("targets は%d件以内で指定してください", maxTargets)
+	}
+
+	home, _ := bridge.Get(message.BridgeID)
+	for i, t := range message.Targets {
+		if t.Platform == "" && t.BridgeID == "" && t.ChannelID == "" {
+			return fmt.Errorf("targets[%d] に platform・bridgeId・channelId のいずれかを指定してください", i)
+		}
+		if t.BridgeID == "" {
+			continue
+		}
+		b, ok := bridge.Get(t.BridgeID)
+		if !ok {
+			return fmt.Errorf("targets[%d] のブリッジが見つかりません: %s", i, t.BridgeID)
+		}
+		if b.ID != home.ID && !key.HasScope(model.ScopeAdmin) {
+			return fmt.Errorf("targets[%d] は投稿先以外のブリッジのため admin スコープが必要です", i)
+		}
+	}
+
+	if len(bridge.AllDestinations(message)) == 0 {
+		return errNoDestinations
+	}
+	return nil
+}
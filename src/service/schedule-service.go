@@ -0,0 +1,173 @@
+package service
+
+import (
+	"errors"
+	"fuagfuga-2025-LinkGate/src/middleware"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/scheduler"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 予約投稿の登録リクエスト
+type createScheduleRequest struct {
+	// 予約投稿の名前
+	Name string `json:"name"`
+	// 投稿内容
+	Content model.Content `json:"content"`
+	// 投稿日時（1回のみの場合）
+	At *time.Time `json:"at"`
+	// cron 式（繰り返しの場合）
+	Cron string `json:"cron"`
+	// cron 式を解釈するタイムゾーン（例: Asia/Tokyo）
+	Timezone string `json:"timezone"`
+	// 転送先のブリッジ（未設定の場合は連携が参加しているブリッジ）
+	BridgeIDs []string `json:"bridgeIds"`
+}
+
+// 予約投稿を登録
+// 投稿者はAPIキーに紐付いた連携になり、投稿日時になるとリーダーのインスタンスがメッセージを保存する
+func CreateSchedule(c *gin.Context) {
+	key, _ := middleware.APIKey(c)
+	if key.IntegrationID == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "予約投稿には連携に紐付いたAPIキーが必要です"})
+		return
+	}
+	integration, err := webhook.Get(key.IntegrationID)
+	if err != nil || integration.Disabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "APIキーに紐付いた連携が見つからないか、無効化されています"})
+		return
+	}
+
+	var req createScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	if strings.TrimSpace(req.Content.Text) == "" && len(req.Content.Attachments) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content.text か content.attachments を指定してください"})
+		return
+	}
+
+	// 転送先のブリッジは POST /post の targets と同じ条件で検証する
+	probe := model.Message{User: model.User{Platform: model.PlatformAPI}, ChannelID: integration.ID}
+	if b, ok := bridge.Find(model.PlatformWebhook, integration.ID); ok {
+		probe.BridgeID = b.ID
+	}
+	for _, id := range req.BridgeIDs {
+		probe.Targets = append(probe.Targets, model.Target{BridgeID: id})
+	}
+	if err := validateTargets(key, probe); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "転送先の指定が正しくありません", "details": err.Error()})
+		return
+	}
+
+	schedule, err := scheduler.Create(model.Schedule{
+		Name:          req.Name,
+		IntegrationID: integration.ID,
+		Content:       req.Content,
+		BridgeIDs:     req.BridgeIDs,
+		APIKeyID:      key.ID,
+		At:            req.At,
+		Cron:          req.Cron,
+		Timezone:      req.Timezone,
+	})
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, scheduler.ErrInvalid) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": "予約投稿の登録に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// 予約投稿の一覧を取得（admin 以外はAPIキーの連携の予約投稿のみ）
+// ?status=active で状態を、admin の場合は ?integrationId= で連携を絞り込みできる
+func GetSchedules(c *gin.Context) {
+	key, _ := middleware.APIKey(c)
+	integrationID := key.IntegrationID
+	if key.HasScope(model.ScopeAdmin) {
+		integrationID = c.Query("integrationId")
+	} else if integrationID == "" {
+		c.JSON(http.StatusOK, []model.Schedule{})
+		return
+	}
+
+	schedules, err := scheduler.List(integrationID, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// 予約投稿を1件取得
+func GetSchedule(c *gin.Context) {
+	schedule, ok := findSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// 予約投稿を一時停止
+func PauseSchedule(c *gin.Context) {
+	changeSchedule(c, scheduler.Pause)
+}
+
+// 一時停止中の予約投稿を再開
+func ResumeSchedule(c *gin.Context) {
+	changeSchedule(c, scheduler.Resume)
+}
+
+// 予約投稿を取り消す
+func CancelSchedule(c *gin.Context) {
+	changeSchedule(c, scheduler.Cancel)
+}
+
+// 予約投稿の状態を変更する
+func changeSchedule(c *gin.Context, change func(id string) (model.Schedule, error)) {
+	if _, ok := findSchedule(c); !ok {
+		return
+	}
+
+	schedule, err := change(c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, scheduler.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, scheduler.ErrInvalidState):
+			status = http.StatusConflict
+		case errors.Is(err, scheduler.ErrInvalid):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": "予約投稿の変更に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// パスパラメータのIDの予約投稿を取得する（見つからないか、APIキーの連携の予約投稿でない場合はレスポンスを返して false）
+func findSchedule(c *gin.Context) (model.Schedule, bool) {
+	key, _ := middleware.APIKey(c)
+
+	schedule, err := scheduler.Get(c.Param("id"))
+	if errors.Is(err, scheduler.ErrNotFound) ||
+		(err == nil && schedule.IntegrationID != key.IntegrationID && !key.HasScope(model.ScopeAdmin)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "予約投稿が見つかりません"})
+		return model.Schedule{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return model.Schedule{}, false
+	}
+	return schedule, true
+}
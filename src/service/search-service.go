@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/search"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 1回の検索で確認するメッセージの最大件数（N-gram の一致を本文で確認するため、取得件数より多く確認する）
+const maxSearchScan = 1000
+
+// 検索結果
+type searchResult struct {
+	// メッセージ
+	Message model.Message `json:"message"`
+	// 一致した箇所を <mark> で囲んだ本文の抜粋（HTML）
+	Highlight string `json:"highlight"`
+}
+
+// メッセージを全文検索（GET /messages と同じ絞り込み・ページングに対応）
+func SearchMessages(c *gin.Context, collection *mongo.Collection) {
+	terms := search.Terms(c.Query("q"))
+	if len(terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q に検索語を指定してください"})
+		return
+	}
+	textSearch := search.TextSearch(terms)
+	if textSearch == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "検索語に文字を含めてください"})
+		return
+	}
+
+	q, err := parseMessageQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+
+	filter := bson.M{"$text": bson.M{"$search": textSearch}}
+	for key, value := range q.filter {
+		filter[key] = value
+	}
+	// $text と $or は併用できないため、カーソルは日時のみで絞り込み、同じ日時のメッセージは取得後に除外する
+	if q.cursor != nil {
+		op := "$lte"
+		if q.ascending {
+			op = "$gte"
+		}
+		filter["$and"] = bson.A{bson.M{"createdAt": bson.M{op: q.cursor.createdAt}}}
+	}
+
+	order := -1
+	if q.ascending {
+		order = 1
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: order}, {Key: "_id", Value: order}}).
+		SetBatchSize(100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cur, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+	defer cur.Close(ctx)
+
+	results := []searchResult{}
+	var last model.Message
+	scanned := 0
+	for int64(len(results)) <= q.limit && scanned < maxSearchScan && cur.Next(ctx) {
+		var message model.Message
+		if err := cur.Decode(&message); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データのパースに失敗しました", "details": err.Error()})
+			return
+		}
+		scanned++
+		if !q.after(message) {
+			continue
+		}
+		last = message
+		if !search.Match(message.Content.Text, terms) {
+			continue
+		}
+		results = append(results, searchResult{Message: message, Highlight: search.Highlight(message.Content.Text, terms)})
+	}
+	if err := cur.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+
+	// 1件多く見つかった場合、または確認する件数の上限に達した場合は続きがある
+	var nextCursor *string
+	if int64(len(results)) > q.limit {
+		results = results[:q.limit]
+		cursor := encodeCursor(results[len(results)-1].Message)
+		nextCursor = &cursor
+	} else if scanned >= maxSearchScan && !last.ID.IsZero() {
+		cursor := encodeCursor(last)
+		nextCursor = &cursor
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "nextCursor": nextCursor})
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Web チャットのチャンネルIDに使用できる文字（URL に含めるため英数字・ハイフン・アンダースコアのみ）
+var webChatChannelPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Web チャットの有効化リクエスト
+type enableWebChatRequest struct {
+	// 参加するブリッジ
+	BridgeID string `json:"bridgeId" binding:"required"`
+	// チャンネルID（チャット画面の URL に使用します）
+	ChannelID string `json:"channelId" binding:"required"`
+}
+
+// ブリッジで Web チャットを有効にする
+func EnableWebChat(c *gin.Context) {
+	var req enableWebChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	// channels は管理用のパス（/webchat/channels）と重なるため使用できない
+	if !webChatChannelPattern.MatchString(req.ChannelID) || req.ChannelID == "channels" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channelId は64文字以内の英数字・ハイフン・アンダースコアで指定してください"})
+		return
+	}
+	if _, ok := bridge.Get(req.BridgeID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ブリッジが見つかりません"})
+		return
+	}
+	if b, ok := bridge.Find(model.PlatformWeb, req.ChannelID); ok {
+		c.JSON(http.StatusConflict, gin.H{"error": "チャンネルIDは既に使用されています", "bridgeId": b.ID})
+		return
+	}
+
+	b, err := bridge.Join(req.BridgeID, model.Channel{Platform: model.PlatformWeb, ChannelID: req.ChannelID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ブリッジへの参加に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"bridgeId": b.ID, "channelId": req.ChannelID, "url": "/chat/?channel=" + req.ChannelID})
+}
+
+// ブリッジの Web チャットを無効にする
+func DisableWebChat(c *gin.Context) {
+	channelID := c.Param("channelId")
+	if _, err := bridge.Leave(model.Channel{Platform: model.PlatformWeb, ChannelID: channelID}); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, bridge.ErrNotJoined) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": "Web チャットの無効化に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Web チャットを無効にしました"})
+}
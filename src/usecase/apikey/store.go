@@ -0,0 +1,179 @@
+package apikey
+
+// このパッケージは Web API の認証に使用する APIキーを管理します。
+// キーは発行時にのみ返却し、MongoDB には SHA-256 ハッシュのみを保存します。
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// キーの接頭辞（ログやリポジトリに紛れ込んだ場合に見分けやすくする）
+const keyPrefix = "lgk_"
+
+// 最終使用日時を更新する間隔（リクエストごとに書き込まないようにする）
+const touchInterval = time.Minute
+
+var (
+	ErrNotInitialized = errors.New("APIキーの保存先が初期化されていません")
+	ErrNotFound       = errors.New("APIキーが見つかりません")
+	ErrInvalidKey     = errors.New("APIキーが正しくないか、失効しています")
+)
+
+// APIキーの保存先
+var collection *mongo.Collection
+
+// Init はAPIキーの保存先を設定し、検索用のインデックスを作成します。
+func Init(coll *mongo.Collection) error {
+	collection = coll
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// Create はAPIキーを発行します。返却するキーは再表示できないため、呼び出し元で利用者に渡してください。
+func Create(name, integrationID string, scopes []string) (model.APIKey, string, error) {
+	if collection == nil {
+		return model.APIKey{}, "", ErrNotInitialized
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.APIKey{}, "", err
+	}
+	raw := keyPrefix + hex.EncodeToString(b)
+
+	key := model.APIKey{
+		ID:            primitive.NewObjectID().Hex(),
+		Name:          name,
+		Prefix:        raw[:len(keyPrefix)+8],
+		Hash:          hash(raw),
+		Scopes:        scopes,
+		IntegrationID: integrationID,
+		CreatedAt:     time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := collection.InsertOne(ctx, key); err != nil {
+		return model.APIKey{}, "", err
+	}
+	return key, raw, nil
+}
+
+// Authenticate はキーに対応する失効していないAPIキーを返します。
+func Authenticate(raw string) (model.APIKey, error) {
+	if collection == nil {
+		return model.APIKey{}, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var key model.APIKey
+	err := collection.FindOne(ctx, bson.M{"hash": hash(raw), "revokedAt": bson.M{"$exists": false}}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return model.APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		collection.UpdateOne(ctx, bson.M{"_id": key.ID}, bson.M{"$set": bson.M{"lastUsedAt": now}})
+		key.LastUsedAt = &now
+	}
+	return key, nil
+}
+
+// Get はIDのAPIキーを返します（失効したキーも返します）。
+func Get(id string) (model.APIKey, error) {
+	if collection == nil {
+		return model.APIKey{}, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var key model.APIKey
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return model.APIKey{}, ErrNotFound
+	}
+	return key, err
+}
+
+// List は発行済みのAPIキーを作成日時の順に返します。
+func List() ([]model.APIKey, error) {
+	if collection == nil {
+		return nil, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cur, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []model.APIKey{}
+	err = cur.All(ctx, &keys)
+	return keys, err
+}
+
+// Revoke はAPIキーを失効させます。失効したキーは監査のため削除せずに残します。
+func Revoke(id string) error {
+	if collection == nil {
+		return ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RevokeByIntegration は連携に紐付いたAPIキーを全て失効させます。
+func RevokeByIntegration(integrationID string) error {
+	if collection == nil {
+		return ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"integrationId": integrationID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}})
+	return err
+}
+
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,311 @@
+package bridge
+
+// このパッケージはプラットフォーム間でメッセージを中継する「ブリッジ」の設定を管理します。
+// ブリッジは各プラットフォームのチャンネルの組で、あるチャンネルに投稿されたメッセージは
+// 同じブリッジに属する他のチャンネルへ転送されます。
+//
+// ブリッジは設定ファイル（または環境変数）で定義されたものと、Discord のコマンドなどから
+// 実行時に作成され MongoDB に保存されたものを合わせて扱います。
+
+import (
+	"encoding/json"
+	"fuagfuga-2025-LinkGate/src/model"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ブリッジIDが未設定のメッセージに使用されるデフォルトブリッジのID
+const DefaultBridgeID = "default"
+
+var (
+	// 設定ファイル・環境変数で定義されたブリッジ
+	configured []model.Bridge
+	// MongoDB に保存されたブリッジ
+	stored []model.Bridge
+	// configured と stored を統合したブリッジ
+	bridges []model.Bridge
+
+	loadOnce sync.Once
+	mu       sync.RWMutex
+)
+
+// 設定を読み込む（初回のみ）
+func load() {
+	loadOnce.Do(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		configured = loadBridges()
+		rebuild()
+	})
+}
+
+// ブリッジ設定を読み込みます。
+// 環境変数 BRIDGE_CONFIG_PATH に JSON ファイルが指定されていればそれを使用し、
+// 指定されていない場合は各プラットフォームのチャンネルID環境変数からデフォルトブリッジを作成します。
+func loadBridges() []model.Bridge {
+	path := os.Getenv("BRIDGE_CONFIG_PATH")
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("ブリッジ設定ファイルの読み込みに失敗しました: %v", err)
+		} else {
+			var configured []model.Bridge
+			if err := json.Unmarshal(data, &configured); err != nil {
+				log.Printf("ブリッジ設定ファイルの解析に失敗しました: %v", err)
+			} else {
+				log.Printf("ブリッジ設定を読み込みました: %d件", len(configured))
+				return configured
+			}
+		}
+	}
+
+	return []model.Bridge{defaultBridge()}
+}
+
+// 環境変数からデフォルトブリッジを作成する
+func defaultBridge() model.Bridge {
+	b := model.Bridge{ID: DefaultBridgeID, Name: "デフォルト"}
+
+	envs := []struct {
+		platform model.Platform
+		key      string
+	}{
+		{model.PlatformLINE, "LINE_GROUP_ID"},
+		{model.PlatformDiscord, "DISCORD_CHANNEL_ID"},
+		{model.PlatformSlack, "SLACK_CHANNEL_ID"},
+		{model.PlatformTelegram, "TELEGRAM_CHAT_ID"},
+		{model.PlatformMatrix, "MATRIX_ROOM_ID"},
+		{model.PlatformIRC, "IRC_CHANNEL"},
+		{model.PlatformMattermost, "MATTERMOST_CHANNEL_ID"},
+		{model.PlatformEmail, "EMAIL_LIST_ADDRESS"},
+		{model.PlatformWeb, "WEBCHAT_CHANNEL_ID"},
+	}
+	for _, env := range envs {
+		if id := os.Getenv(env.key); id != "" {
+			b.Channels = append(b.Channels, model.Channel{Platform: env.platform, ChannelID: id})
+		}
+	}
+	return b
+}
+
+// configured と stored を統合して bridges を作り直す（mu をロックした状態で呼び出すこと）
+// 同じIDのブリッジはチャンネルを結合します。
+func rebuild() {
+	merged := []model.Bridge{}
+	index := map[string]int{}
+
+	for _, list := range [][]model.Bridge{configured, stored} {
+		for _, b := range list {
+			if i, ok := index[b.ID]; ok {
+				for _, ch := range b.Channels {
+					merged[i].Channels = append(merged[i].Channels, normalize(ch))
+				}
+				continue
+			}
+			index[b.ID] = len(merged)
+			channels := make([]model.Channel, 0, len(b.Channels))
+			for _, ch := range b.Channels {
+				channels = append(channels, normalize(ch))
+			}
+			b.Channels = channels
+			merged = append(merged, b)
+		}
+	}
+	bridges = merged
+}
+
+// All は設定されている全てのブリッジを返します。
+func All() []model.Bridge {
+	load()
+	mu.RLock()
+	defer mu.RUnlock()
+	return bridges
+}
+
+// Get は指定したIDのブリッジを返します。IDが空の場合はデフォルトブリッジを返します。
+func Get(id string) (model.Bridge, bool) {
+	load()
+	mu.RLock()
+	defer mu.RUnlock()
+	return get(id)
+}
+
+func get(id string) (model.Bridge, bool) {
+	if id == "" {
+		id = DefaultBridgeID
+	}
+	for _, b := range bridges {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	// 設定ファイルにデフォルトブリッジがない場合は先頭のブリッジを使用する
+	if id == DefaultBridgeID && len(bridges) > 0 {
+		return bridges[0], true
+	}
+	return model.Bridge{}, false
+}
+
+// MessageFilter はメッセージの bridgeId をブリッジで絞り込む検索条件を返します。
+// デフォルトブリッジのメッセージは bridgeId が未設定の場合があるため、空文字・null も含めます。
+func MessageFilter(id string) interface{} {
+	b, ok := Get(id)
+	if !ok {
+		return id
+	}
+	if def, ok := Get(""); ok && def.ID == b.ID {
+		return bson.M{"$in": bson.A{b.ID, "", nil}}
+	}
+	return b.ID
+}
+
+// Find はプラットフォームとチャンネルIDから所属するブリッジを探します。
+func Find(platform model.Platform, channelID string) (model.Bridge, bool) {
+	load()
+	mu.RLock()
+	defer mu.RUnlock()
+	return find(bridges, platform, channelID)
+}
+
+func find(list []model.Bridge, platform model.Platform, channelID string) (model.Bridge, bool) {
+	target := normalize(model.Channel{Platform: platform, ChannelID: channelID})
+	for _, b := range list {
+		for _, ch := range b.Channels {
+			if normalize(ch) == target {
+				return b, true
+			}
+		}
+	}
+	return model.Bridge{}, false
+}
+
+// Destinations はメッセージの転送先となる指定プラットフォームのチャンネルを返します。
+// 投稿元のチャンネル自身は含まれません。メッセージに転送先が指定されている場合は、一致するチャンネルのみを返します。
+func Destinations(msg model.Message, platform model.Platform) []model.Channel {
+	var channels []model.Channel
+	for _, ch := range AllDestinations(msg) {
+		if ch.Platform == platform {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// AllDestinations はメッセージの転送先となる全てのプラットフォームのチャンネルを返します。
+func AllDestinations(msg model.Message) []model.Channel {
+	from := origin(msg)
+
+	var channels []model.Channel
+	for _, b := range targetBridges(msg) {
+		for _, ch := range b.Channels {
+			if ch == from {
+				continue
+			}
+			if !matchTargets(msg, b.ID, ch) || slices.Contains(channels, ch) {
+				continue
+			}
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// Reaches はメッセージがチャンネルに表示されるか（投稿元のチャンネルか、転送先に含まれるか）を返します。
+func Reaches(msg model.Message, ch model.Channel) bool {
+	ch = normalize(ch)
+	return origin(msg) == ch || slices.Contains(AllDestinations(msg), ch)
+}
+
+// メッセージの投稿元のチャンネル
+func origin(msg model.Message) model.Channel {
+	// Web API から投稿されたメッセージは、APIキーに紐付いた連携のチャンネルから投稿されたものとして扱う
+	source := msg.User.Platform
+	if source == model.PlatformAPI {
+		source = model.PlatformWebhook
+	}
+	return normalize(model.Channel{Platform: source, ChannelID: msg.ChannelID})
+}
+
+// BridgeIDs はメッセージが投稿・転送されるブリッジのIDを返します（投稿先のブリッジと、転送先に指定されたブリッジ）。
+func BridgeIDs(msg model.Message) []string {
+	var ids []string
+	if b, ok := Get(msg.BridgeID); ok {
+		ids = append(ids, b.ID)
+	}
+	for _, b := range targetBridges(msg) {
+		if !slices.Contains(ids, b.ID) {
+			ids = append(ids, b.ID)
+		}
+	}
+	return ids
+}
+
+// 転送先のブリッジを返す（転送先の指定がない場合は投稿先のブリッジのみ）
+func targetBridges(msg model.Message) []model.Bridge {
+	ids := []string{msg.BridgeID}
+	if len(msg.Targets) > 0 {
+		ids = ids[:0]
+		for _, t := range msg.Targets {
+			ids = append(ids, targetBridgeID(msg, t))
+		}
+	}
+
+	var bridges []model.Bridge
+	for _, id := range ids {
+		b, ok := Get(id)
+		if !ok {
+			log.Printf("ブリッジが見つかりません: %s", id)
+			continue
+		}
+		if !slices.ContainsFunc(bridges, func(found model.Bridge) bool { return found.ID == b.ID }) {
+			bridges = append(bridges, b)
+		}
+	}
+	return bridges
+}
+
+// チャンネルがメッセージの転送先の指定のいずれかに一致するか
+func matchTargets(msg model.Message, bridgeID string, ch model.Channel) bool {
+	if len(msg.Targets) == 0 {
+		return true
+	}
+	for _, t := range msg.Targets {
+		if t.Platform != "" && t.Platform != ch.Platform {
+			continue
+		}
+		if t.ChannelID != "" && normalize(model.Channel{Platform: ch.Platform, ChannelID: t.ChannelID}) != normalize(ch) {
+			continue
+		}
+		if targetBridgeID(msg, t) != bridgeID {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// チャンネルIDを比較できる形にそろえる
+// IRC のチャンネル名は大文字・小文字を区別しないため小文字にする
+func normalize(ch model.Channel) model.Channel {
+	if ch.Platform == model.PlatformIRC {
+		ch.ChannelID = strings.ToLower(ch.ChannelID)
+	}
+	return ch
+}
+
+// 転送先の指定のブリッジID（未設定の場合は投稿先のブリッジ）
+func targetBridgeID(msg model.Message, t model.Target) string {
+	id := t.BridgeID
+	if id == "" {
+		id = msg.BridgeID
+	}
+	if b, ok := Get(id); ok {
+		return b.ID
+	}
+	return id
+}
@@ -0,0 +1,221 @@
+package bridge
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// テスト用のブリッジ設定に差し替える
+func setBridges(t *testing.T, list []model.Bridge) {
+	t.Helper()
+	load()
+	mu.Lock()
+	prevConfigured, prevStored := configured, stored
+	configured, stored = list, nil
+	rebuild()
+	mu.Unlock()
+
+	t.Cleanup(func() {
+		mu.Lock()
+		configured, stored = prevConfigured, prevStored
+		rebuild()
+		mu.Unlock()
+	})
+}
+
+func TestFindNormalizesIRCChannel(t *testing.T) {
+	setBridges(t, []model.Bridge{{
+		ID: "team",
+		Channels: []model.Channel{
+			{Platform: model.PlatformIRC, ChannelID: "#LinkGate"},
+			{Platform: model.PlatformDiscord, ChannelID: "AbC"},
+		},
+	}})
+
+	tests := []struct {
+		name      string
+		platform  model.Platform
+		channelID string
+		want      bool
+	}{
+		{name: "IRC 小文字", platform: model.PlatformIRC, channelID: "#linkgate", want: true},
+		{name: "IRC 設定と同じ表記", platform: model.PlatformIRC, channelID: "#LinkGate", want: true},
+		{name: "IRC 大文字", platform: model.PlatformIRC, channelID: "#LINKGATE", want: true},
+		{name: "IRC 別のチャンネル", platform: model.PlatformIRC, channelID: "#other", want: false},
+		{name: "IRC 以外は大文字・小文字を区別する", platform: model.PlatformDiscord, channelID: "abc", want: false},
+		{name: "IRC 以外で一致", platform: model.PlatformDiscord, channelID: "AbC", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := Find(tt.platform, tt.channelID)
+			if ok != tt.want {
+				t.Errorf("Find(%s, %q) = %v, want %v", tt.platform, tt.channelID, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestinationsNormalizesIRCChannel(t *testing.T) {
+	setBridges(t, []model.Bridge{{
+		ID: "team",
+		Channels: []model.Channel{
+			{Platform: model.PlatformIRC, ChannelID: "#LinkGate"},
+			{Platform: model.PlatformDiscord, ChannelID: "123"},
+		},
+	}})
+
+	// IRC から受信したメッセージ（チャンネル名は小文字）は投稿元の IRC チャンネルへ戻さない
+	fromIRC := model.Message{BridgeID: "team", ChannelID: "#linkgate"}
+	fromIRC.User.Platform = model.PlatformIRC
+	if got := Destinations(fromIRC, model.PlatformIRC); len(got) != 0 {
+		t.Errorf("Destinations(from IRC) = %v, want none", got)
+	}
+
+	// 転送先の指定のチャンネル名も大文字・小文字を区別しない
+	fromDiscord := model.Message{
+		BridgeID:  "team",
+		ChannelID: "123",
+		Targets:   []model.Target{{Platform: model.PlatformIRC, ChannelID: "#LINKGATE"}},
+	}
+	fromDiscord.User.Platform = model.PlatformDiscord
+	got := Destinations(fromDiscord, model.PlatformIRC)
+	if len(got) != 1 || got[0].ChannelID != "#linkgate" {
+		t.Errorf("Destinations(targeted) = %v, want [#linkgate]", got)
+	}
+}
+
+func TestMessageFilter(t *testing.T) {
+	// 設定ファイルにデフォルトブリッジがない場合は先頭のブリッジがデフォルトになる
+	setBridges(t, []model.Bridge{{ID: "general"}, {ID: "staff"}})
+
+	tests := []struct {
+		id   string
+		want interface{}
+	}{
+		{id: "general", want: bson.M{"$in": bson.A{"general", "", nil}}},
+		{id: DefaultBridgeID, want: bson.M{"$in": bson.A{"general", "", nil}}},
+		{id: "staff", want: "staff"},
+		{id: "unknown", want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := MessageFilter(tt.id); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MessageFilter(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestReaches(t *testing.T) {
+	setBridges(t, []model.Bridge{
+		{ID: "general", Channels: []model.Channel{
+			{Platform: model.PlatformDiscord, ChannelID: "d-general"},
+			{Platform: model.PlatformWeb, ChannelID: "w-general"},
+		}},
+		{ID: "staff", Channels: []model.Channel{
+			{Platform: model.PlatformIRC, ChannelID: "#Staff"},
+			{Platform: model.PlatformWeb, ChannelID: "w-staff"},
+		}},
+	})
+
+	fromDiscord := model.Message{BridgeID: "general", ChannelID: "d-general", User: model.User{Platform: model.PlatformDiscord}}
+	toStaffWeb := fromDiscord
+	toStaffWeb.Targets = []model.Target{{BridgeID: "staff", Platform: model.PlatformWeb}}
+	toIRCOnly := fromDiscord
+	toIRCOnly.Targets = []model.Target{{BridgeID: "staff", Platform: model.PlatformIRC}}
+
+	tests := []struct {
+		name string
+		msg  model.Message
+		ch   model.Channel
+		want bool
+	}{
+		{name: "同じブリッジの Web チャット", msg: fromDiscord, ch: model.Channel{Platform: model.PlatformWeb, ChannelID: "w-general"}, want: true},
+		{name: "投稿元のチャンネル", msg: fromDiscord, ch: model.Channel{Platform: model.PlatformDiscord, ChannelID: "d-general"}, want: true},
+		{name: "別のブリッジ", msg: fromDiscord, ch: model.Channel{Platform: model.PlatformWeb, ChannelID: "w-staff"}, want: false},
+		{name: "転送先に指定された別のブリッジ", msg: toStaffWeb, ch: model.Channel{Platform: model.PlatformWeb, ChannelID: "w-staff"}, want: true},
+		{name: "転送先の指定から除かれた投稿先のブリッジ", msg: toStaffWeb, ch: model.Channel{Platform: model.PlatformWeb, ChannelID: "w-general"}, want: false},
+		{name: "転送先の指定は投稿元のチャンネルに影響しない", msg: toStaffWeb, ch: model.Channel{Platform: model.PlatformDiscord, ChannelID: "d-general"}, want: true},
+		{name: "転送先が IRC のみ", msg: toIRCOnly, ch: model.Channel{Platform: model.PlatformWeb, ChannelID: "w-staff"}, want: false},
+		{name: "IRC のチャンネル名は大文字・小文字を区別しない", msg: toIRCOnly, ch: model.Channel{Platform: model.PlatformIRC, ChannelID: "#STAFF"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Reaches(tt.msg, tt.ch); got != tt.want {
+				t.Errorf("Reaches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBridgeIDs(t *testing.T) {
+	setBridges(t, []model.Bridge{{ID: "general"}, {ID: "staff"}, {ID: "ops"}})
+
+	tests := []struct {
+		name string
+		msg  model.Message
+		want []string
+	}{
+		{name: "転送先の指定なし", msg: model.Message{BridgeID: "staff"}, want: []string{"staff"}},
+		{name: "ブリッジID未設定はデフォルトブリッジ", msg: model.Message{}, want: []string{"general"}},
+		{
+			name: "転送先に指定された別のブリッジを含む",
+			msg:  model.Message{BridgeID: "general", Targets: []model.Target{{BridgeID: "staff"}, {BridgeID: "ops"}, {BridgeID: "staff"}}},
+			want: []string{"general", "staff", "ops"},
+		},
+		{
+			name: "デフォルトブリッジの指定",
+			msg:  model.Message{BridgeID: "staff", Targets: []model.Target{{BridgeID: DefaultBridgeID}}},
+			want: []string{"staff", "general"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BridgeIDs(tt.msg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BridgeIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDestinationsLINEOnlyFromSameBridge(t *testing.T) {
+	// LINE は送信先のグループが1つのため、他のブリッジのメッセージを送らないことを確認する
+	line := model.Channel{Platform: model.PlatformLINE, ChannelID: "line-group"}
+	setBridges(t, []model.Bridge{
+		{ID: "a", Channels: []model.Channel{line, {Platform: model.PlatformDiscord, ChannelID: "d-a"}}},
+		{ID: "b", Channels: []model.Channel{{Platform: model.PlatformDiscord, ChannelID: "d-b"}}},
+	})
+
+	fromA := model.Message{BridgeID: "a", ChannelID: "d-a", User: model.User{Platform: model.PlatformDiscord}}
+	fromB := model.Message{BridgeID: "b", ChannelID: "d-b", User: model.User{Platform: model.PlatformDiscord}}
+	fromBToA := fromB
+	fromBToA.Targets = []model.Target{{BridgeID: "a", Platform: model.PlatformLINE}}
+	fromAToB := fromA
+	fromAToB.Targets = []model.Target{{BridgeID: "b"}}
+
+	tests := []struct {
+		name string
+		msg  model.Message
+		want bool
+	}{
+		{name: "同じブリッジ", msg: fromA, want: true},
+		{name: "別のブリッジ", msg: fromB, want: false},
+		{name: "別のブリッジから転送先に指定", msg: fromBToA, want: true},
+		{name: "転送先の指定から除かれた", msg: fromAToB, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Contains(Destinations(tt.msg, model.PlatformLINE), line)
+			if got != tt.want {
+				t.Errorf("LINE に送信 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 招待コードの有効期間
+const inviteDuration = 24 * time.Hour
+
+var (
+	ErrInvalidInvite = errors.New("招待コードが正しくないか、有効期限が切れています")
+	ErrDefaultBridge = errors.New("デフォルトブリッジには招待で参加できません。設定ファイルで追加してください")
+)
+
+// 招待コードの保存先
+var inviteStore *mongo.Collection
+
+// invite はブリッジへの1回だけ使える招待です（コードはハッシュのみ保存します）
+type invite struct {
+	// 招待コードの SHA-256 ハッシュ
+	Hash string `bson:"_id"`
+	// 招待先のブリッジID
+	BridgeID string `bson:"bridgeId"`
+	// 招待を発行したチャンネル
+	CreatedBy model.Channel `bson:"createdBy"`
+	// 有効期限
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// 招待コードの保存先を設定し、有効期限が切れた招待を自動で削除するインデックスを作成する
+func initInvites(collection *mongo.Collection) error {
+	inviteStore = collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := inviteStore.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// CreateInvite はブリッジに参加しているチャンネルから、そのブリッジに1回だけ参加できる招待コードを発行します。
+// デフォルトブリッジの招待は発行できません。
+func CreateInvite(from model.Channel) (model.Bridge, string, time.Time, error) {
+	if inviteStore == nil {
+		return model.Bridge{}, "", time.Time{}, ErrNotInitialized
+	}
+
+	b, ok := Find(from.Platform, from.ChannelID)
+	if !ok {
+		return model.Bridge{}, "", time.Time{}, ErrNotJoined
+	}
+	if isDefault(b.ID) {
+		return model.Bridge{}, "", time.Time{}, ErrDefaultBridge
+	}
+
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return model.Bridge{}, "", time.Time{}, err
+	}
+	code := hex.EncodeToString(raw)
+	inv := invite{Hash: hashInvite(code), BridgeID: b.ID, CreatedBy: from, ExpiresAt: time.Now().Add(inviteDuration)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := inviteStore.InsertOne(ctx, inv); err != nil {
+		return model.Bridge{}, "", time.Time{}, err
+	}
+	return b, code, inv.ExpiresAt, nil
+}
+
+// JoinWithInvite は招待コードを使用して、チャンネルを招待元のブリッジに参加させます。
+// 招待コードは参加に成功すると使用できなくなります。
+func JoinWithInvite(code string, ch model.Channel) (model.Bridge, error) {
+	if inviteStore == nil {
+		return model.Bridge{}, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// 同じコードで同時に参加できないよう、取得と同時に削除する
+	var inv invite
+	filter := bson.M{"_id": hashInvite(code), "expiresAt": bson.M{"$gt": time.Now()}}
+	if err := inviteStore.FindOneAndDelete(ctx, filter).Decode(&inv); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return model.Bridge{}, ErrInvalidInvite
+		}
+		return model.Bridge{}, err
+	}
+	if isDefault(inv.BridgeID) {
+		return model.Bridge{}, ErrDefaultBridge
+	}
+
+	b, err := Join(inv.BridgeID, ch)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		// 参加できなかった場合は招待コードを使えるように戻す
+		inviteStore.InsertOne(ctx, inv)
+	}
+	return b, err
+}
+
+// デフォルトブリッジ（ID が default か、設定ファイルにない場合に代わりに使われる先頭のブリッジ）か
+func isDefault(id string) bool {
+	if id == DefaultBridgeID {
+		return true
+	}
+	b, ok := Get("")
+	return ok && b.ID == id
+}
+
+func hashInvite(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fuagfuga-2025-LinkGate/src/model"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotInitialized = errors.New("ブリッジの保存先が初期化されていません")
+	ErrNotFound       = errors.New("ブリッジが見つかりません")
+	ErrChannelInUse   = errors.New("このチャンネルは既に他のブリッジに参加しています")
+	ErrNotJoined      = errors.New("このチャンネルはどのブリッジにも参加していません")
+	ErrConfigured     = errors.New("設定ファイルで定義されたチャンネルは削除できません")
+)
+
+// 実行時に作成したブリッジの保存先
+var store *mongo.Collection
+
+// Init は MongoDB に保存されたブリッジを読み込み、以降の作成・参加・退出と招待を保存できるようにします。
+func Init(collection *mongo.Collection) error {
+	load()
+	store = collection
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cur, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var list []model.Bridge
+	if err := cur.All(ctx, &list); err != nil {
+		return err
+	}
+
+	mu.Lock()
+	stored = list
+	rebuild()
+	mu.Unlock()
+
+	// チャンネルの参加に使用する招待コード
+	return initInvites(collection.Database().Collection("bridge_invites"))
+}
+
+// Create は新しいブリッジを作成し、指定したチャンネルを参加させます。
+func Create(name string, ch model.Channel) (model.Bridge, error) {
+	ch = normalize(ch)
+	if store == nil {
+		return model.Bridge{}, ErrNotInitialized
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := find(bridges, ch.Platform, ch.ChannelID); ok {
+		return model.Bridge{}, ErrChannelInUse
+	}
+
+	b := model.Bridge{
+		ID:       primitive.NewObjectID().Hex(),
+		Name:     name,
+		Channels: []model.Channel{ch},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := store.InsertOne(ctx, b); err != nil {
+		return model.Bridge{}, err
+	}
+
+	stored = append(stored, b)
+	rebuild()
+	return b, nil
+}
+
+// Join は既存のブリッジにチャンネルを参加させます。
+func Join(id string, ch model.Channel) (model.Bridge, error) {
+	ch = normalize(ch)
+	if store == nil {
+		return model.Bridge{}, ErrNotInitialized
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	b, ok := get(id)
+	if !ok || (id != "" && b.ID != id) {
+		return model.Bridge{}, ErrNotFound
+	}
+	if _, ok := find(bridges, ch.Platform, ch.ChannelID); ok {
+		return model.Bridge{}, ErrChannelInUse
+	}
+
+	// 設定ファイルのブリッジに参加する場合も、追加したチャンネルは MongoDB に保存する
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := store.UpdateOne(ctx,
+		bson.M{"_id": b.ID},
+		bson.M{
+			"$push":        bson.M{"channels": ch},
+			"$setOnInsert": bson.M{"name": b.Name},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return model.Bridge{}, err
+	}
+
+	added := false
+	for i := range stored {
+		if stored[i].ID == b.ID {
+			stored[i].Channels = append(stored[i].Channels, ch)
+			added = true
+		}
+	}
+	if !added {
+		stored = append(stored, model.Bridge{ID: b.ID, Name: b.Name, Channels: []model.Channel{ch}})
+	}
+	rebuild()
+
+	b.Channels = append(b.Channels, ch)
+	return b, nil
+}
+
+// Leave はチャンネルを参加しているブリッジから退出させます。
+func Leave(ch model.Channel) (model.Bridge, error) {
+	ch = normalize(ch)
+	if store == nil {
+		return model.Bridge{}, ErrNotInitialized
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	b, ok := find(bridges, ch.Platform, ch.ChannelID)
+	if !ok {
+		return model.Bridge{}, ErrNotJoined
+	}
+	if _, ok := find(configured, ch.Platform, ch.ChannelID); ok {
+		return model.Bridge{}, ErrConfigured
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := store.UpdateOne(ctx,
+		bson.M{"_id": b.ID},
+		bson.M{"$pull": bson.M{"channels": bson.M{"platform": ch.Platform, "channelId": ch.ChannelID}}},
+	)
+	if err != nil {
+		return model.Bridge{}, err
+	}
+
+	for i := range stored {
+		if stored[i].ID != b.ID {
+			continue
+		}
+		channels := []model.Channel{}
+		for _, c := range stored[i].Channels {
+			if normalize(c) == ch {
+				continue
+			}
+			channels = append(channels, c)
+		}
+		stored[i].Channels = channels
+	}
+	rebuild()
+	return b, nil
+}
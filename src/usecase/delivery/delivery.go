@@ -0,0 +1,70 @@
+package delivery
+
+// このパッケージは他のプラットフォームへ転送したメッセージの記録を管理します。
+// Web API でメッセージが編集・削除されたときに、各プラットフォームの転送先メッセージを特定するために使用します。
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 転送記録の保存先
+var collection *mongo.Collection
+
+// Init は転送記録の保存先を設定し、検索用のインデックスを作成します。
+func Init(coll *mongo.Collection) error {
+	collection = coll
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "messageId", Value: 1}, {Key: "platform", Value: 1}},
+	})
+	return err
+}
+
+// Record はメッセージを転送したことを記録します。
+// 記録に失敗しても転送自体は成功しているため、エラーはログに出力するだけにします。
+func Record(messageID primitive.ObjectID, d model.Delivery) {
+	if collection == nil {
+		return
+	}
+
+	d.ID = primitive.NewObjectID()
+	d.MessageID = messageID
+	d.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := collection.InsertOne(ctx, d); err != nil {
+		log.Printf("転送記録の保存に失敗しました (message: %s, platform: %s): %v", messageID.Hex(), d.Platform, err)
+	}
+}
+
+// ForMessage はメッセージをプラットフォームへ転送した記録を返します。
+func ForMessage(messageID primitive.ObjectID, platform model.Platform) []model.Delivery {
+	if collection == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cur, err := collection.Find(ctx, bson.M{"messageId": messageID, "platform": platform})
+	if err != nil {
+		log.Printf("転送記録の取得に失敗しました (message: %s): %v", messageID.Hex(), err)
+		return nil
+	}
+	var deliveries []model.Delivery
+	if err := cur.All(ctx, &deliveries); err != nil {
+		log.Printf("転送記録の取得に失敗しました (message: %s): %v", messageID.Hex(), err)
+		return nil
+	}
+	return deliveries
+}
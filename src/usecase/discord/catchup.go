@@ -0,0 +1,224 @@
+package discord
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 取りこぼし取得時の1リクエストあたりの取得件数（Discord API の上限）
+const catchUpPageSize = 100
+
+// チャンネルごとに最後に受信したメッセージIDの保存先
+const cursorCollectionName = "discord_cursors"
+
+// ゲートウェイの接続状態
+const (
+	StateDisabled     = "disabled"
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+)
+
+// SessionStatus はヘルスチェックで表示する Discord セッションの状態です
+type SessionStatus struct {
+	// 接続状態
+	State string `json:"state"`
+	// ハートビートの遅延（ミリ秒）
+	LatencyMs int64 `json:"latencyMs"`
+	// 最後に Ready / Resumed を受信した日時
+	LastConnectedAt *time.Time `json:"lastConnectedAt,omitempty"`
+	// 最後に切断された日時
+	LastDisconnectedAt *time.Time `json:"lastDisconnectedAt,omitempty"`
+	// 起動後に再接続した回数
+	Reconnects int `json:"reconnects"`
+	// 最後に取りこぼしを取得した件数
+	LastCatchUpCount int `json:"lastCatchUpCount"`
+}
+
+var (
+	status   = SessionStatus{State: StateDisabled}
+	statusMu sync.RWMutex
+
+	// 取りこぼしの取得を同時に実行しないためのロック
+	catchUpMu sync.Mutex
+)
+
+// Status は Discord セッションの現在の状態を返します。
+func Status() SessionStatus {
+	statusMu.RLock()
+	defer statusMu.RUnlock()
+
+	st := status
+	if session != nil && st.State == StateConnected {
+		st.LatencyMs = session.HeartbeatLatency().Milliseconds()
+	}
+	return st
+}
+
+func setState(state string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	status.State = state
+}
+
+func onConnect(s *discordgo.Session, c *discordgo.Connect) {
+	log.Println("Discord ゲートウェイに接続しました")
+}
+
+func onDisconnect(s *discordgo.Session, d *discordgo.Disconnect) {
+	now := time.Now()
+	statusMu.Lock()
+	status.State = StateDisconnected
+	status.LastDisconnectedAt = &now
+	statusMu.Unlock()
+	log.Println("⚠️ Discord ゲートウェイから切断されました。再接続を待機しています...")
+}
+
+func onReady(s *discordgo.Session, r *discordgo.Ready) {
+	markConnected()
+	go catchUp(s)
+}
+
+func onResumed(s *discordgo.Session, r *discordgo.Resumed) {
+	markConnected()
+	go catchUp(s)
+}
+
+// 接続済みとして記録する（2回目以降は再接続としてカウント）
+func markConnected() {
+	now := time.Now()
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	if status.LastConnectedAt != nil {
+		status.Reconnects++
+	}
+	status.State = StateConnected
+	status.LastConnectedAt = &now
+}
+
+// saveLastMessageID はチャンネルで最後に受信したメッセージIDを記録します。
+// 古いメッセージIDで上書きしないよう、数値として大きい場合のみ更新します。
+func saveLastMessageID(channelID, messageID string) {
+	if mongoCollection == nil {
+		return
+	}
+	id, err := strconv.ParseInt(messageID, 10, 64)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = mongoCollection.Database().Collection(cursorCollectionName).UpdateOne(ctx,
+		bson.M{"_id": channelID},
+		bson.M{
+			"$max": bson.M{"lastMessageId": id},
+			"$set": bson.M{"updatedAt": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("最終メッセージIDの保存に失敗しました: %v", err)
+	}
+}
+
+// catchUp は記録済みの各チャンネルについて、最後に受信したメッセージ以降のメッセージを
+// REST API で取得し、古い順に取り込みます。
+func catchUp(s *discordgo.Session) {
+	if mongoCollection == nil {
+		return
+	}
+	if !catchUpMu.TryLock() {
+		return
+	}
+	defer catchUpMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	cur, err := mongoCollection.Database().Collection(cursorCollectionName).Find(ctx, bson.M{})
+	if err != nil {
+		cancel()
+		log.Printf("最終メッセージIDの取得に失敗しました: %v", err)
+		return
+	}
+	var cursors []struct {
+		ChannelID     string `bson:"_id"`
+		LastMessageID int64  `bson:"lastMessageId"`
+	}
+	err = cur.All(ctx, &cursors)
+	cancel()
+	if err != nil {
+		log.Printf("最終メッセージIDの取得に失敗しました: %v", err)
+		return
+	}
+
+	total := 0
+	for _, c := range cursors {
+		total += catchUpChannel(s, c.ChannelID, strconv.FormatInt(c.LastMessageID, 10))
+	}
+
+	statusMu.Lock()
+	status.LastCatchUpCount = total
+	statusMu.Unlock()
+
+	if total > 0 {
+		log.Printf("Discord の取りこぼしメッセージを %d 件取り込みました", total)
+	}
+}
+
+// 1チャンネル分の取りこぼしを取得する
+func catchUpChannel(s *discordgo.Session, channelID, afterID string) int {
+	// 停止中に LinkGate の Webhook で転送した投稿を取り込まないよう、先に Webhook を確認する
+	// （スレッドの Webhook は親チャンネルに作成される）
+	webhookChannelID := channelID
+	if ch, err := lookupChannel(s, channelID); err == nil && ch.IsThread() {
+		webhookChannelID = ch.ParentID
+	}
+	loadChannelWebhooks(s, webhookChannelID)
+
+	count := 0
+	for {
+		messages, err := s.ChannelMessages(channelID, catchUpPageSize, "", afterID, "")
+		if err != nil {
+			log.Printf("Discordメッセージの取得に失敗しました (channel: %s): %v", channelID, err)
+			return count
+		}
+		if len(messages) == 0 {
+			return count
+		}
+
+		// 古い順に取り込む
+		sort.Slice(messages, func(i, j int) bool {
+			return snowflake(messages[i].ID) < snowflake(messages[j].ID)
+		})
+		for _, m := range messages {
+			if m.GuildID == "" {
+				if ch, err := lookupChannel(s, m.ChannelID); err == nil {
+					m.GuildID = ch.GuildID
+				}
+			}
+			// 転送した投稿・無視した投稿・取り込み済みの投稿は数えない
+			if handleMessage(s, m) {
+				count++
+			}
+		}
+
+		afterID = messages[len(messages)-1].ID
+		if len(messages) < catchUpPageSize {
+			return count
+		}
+	}
+}
+
+// Discord の ID（snowflake）を数値に変換する
+func snowflake(id string) int64 {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
@@ -0,0 +1,464 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// /linkgate who で表示する直近の期間
+const whoPeriod = 7 * 24 * time.Hour
+
+// コマンドを登録するサーバーID。環境変数 DISCORD_COMMAND_GUILD_ID を設定すると
+// そのサーバーにのみ即時登録し、未設定の場合はグローバルコマンドとして登録します。
+var commandGuildID = os.Getenv("DISCORD_COMMAND_GUILD_ID")
+
+// ミュート中のユーザー（「サーバーID:ユーザーID」。ミュートは実行したサーバー内の投稿にのみ適用します）
+var (
+	mutedUsers = map[string]struct{}{}
+	mutedMu    sync.RWMutex
+)
+
+// コマンドを登録済みか
+var (
+	commandsRegistered bool
+	commandsMu         sync.Mutex
+)
+
+// 管理者向けコマンドの実行に必要な権限
+var adminPermission int64 = discordgo.PermissionManageChannels
+
+// /linkgate コマンドの定義
+var linkgateCommand = &discordgo.ApplicationCommand{
+	Name:        "linkgate",
+	Description: "LinkGate のブリッジを管理します",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "status",
+			Description: "このチャンネルのブリッジと接続状態を表示します",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        "bridge",
+			Description: "ブリッジを管理します（チャンネルの管理権限が必要）",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "create",
+					Description: "新しいブリッジを作成し、このチャンネルを参加させます",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "name",
+							Description: "ブリッジ名",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "invite",
+					Description: "このチャンネルのブリッジに1回だけ参加できる招待コードを発行します",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "join",
+					Description: "招待コードを使って、このチャンネルを既存のブリッジに参加させます",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "code",
+							Description: "/linkgate bridge invite で発行した招待コード",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        "leave",
+					Description: "このチャンネルをブリッジから退出させます",
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "mute",
+			Description: "ユーザーの投稿を他のプラットフォームへ転送しないようにします（チャンネルの管理権限が必要）",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "ミュートするユーザー",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "unmute",
+			Description: "ユーザーのミュートを解除します（チャンネルの管理権限が必要）",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionUser,
+					Name:        "user",
+					Description: "ミュートを解除するユーザー",
+					Required:    true,
+				},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Name:        "who",
+			Description: "このブリッジで最近発言したユーザーを表示します",
+		},
+	},
+}
+
+// registerCommands はアプリケーションコマンドを登録します（Ready イベントで呼び出します）。
+// Ready は再接続のたびに受信するため、登録に成功した後は登録し直しません。
+func registerCommands(s *discordgo.Session, r *discordgo.Ready) {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	if commandsRegistered {
+		return
+	}
+
+	_, err := s.ApplicationCommandBulkOverwrite(r.Application.ID, commandGuildID, []*discordgo.ApplicationCommand{linkgateCommand})
+	if err != nil {
+		log.Printf("Discordコマンドの登録に失敗しました: %v", err)
+		return
+	}
+	commandsRegistered = true
+	log.Println("Discordコマンド /linkgate を登録しました")
+}
+
+// interactionCreate はスラッシュコマンドを処理します。
+func interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+	data := i.ApplicationCommandData()
+	if data.Name != linkgateCommand.Name || len(data.Options) == 0 {
+		return
+	}
+
+	// DM では実行できない
+	if i.GuildID == "" || i.Member == nil {
+		respond(s, i, "このコマンドはサーバー内でのみ使用できます")
+		return
+	}
+
+	sub := data.Options[0]
+	var reply string
+	switch sub.Name {
+	case "status":
+		reply = commandStatus(s, i)
+	case "who":
+		reply = commandWho(i)
+	case "bridge":
+		if !hasAdminPermission(i) {
+			reply = "このコマンドの実行には「チャンネルの管理」権限が必要です"
+			break
+		}
+		reply = commandBridge(i, sub)
+	case "mute", "unmute":
+		if !hasAdminPermission(i) {
+			reply = "このコマンドの実行には「チャンネルの管理」権限が必要です"
+			break
+		}
+		user := sub.GetOption("user").UserValue(s)
+		reply = commandMute(i, user, sub.Name == "mute")
+	default:
+		reply = "不明なコマンドです"
+	}
+
+	respond(s, i, reply)
+}
+
+// 実行者だけに見えるメッセージで応答する
+func respond(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Discordコマンドへの応答に失敗しました: %v", err)
+	}
+}
+
+// 実行者がチャンネルの管理権限を持っているか
+func hasAdminPermission(i *discordgo.InteractionCreate) bool {
+	return i.Member.Permissions&adminPermission != 0 ||
+		i.Member.Permissions&discordgo.PermissionAdministrator != 0
+}
+
+// /linkgate status
+func commandStatus(s *discordgo.Session, i *discordgo.InteractionCreate) string {
+	lines := []string{
+		fmt.Sprintf("🔗 ゲートウェイ遅延: %dms", s.HeartbeatLatency().Milliseconds()),
+		fmt.Sprintf("📨 送信方式: %s", currentSendMode()),
+	}
+
+	route, ok := resolveRoute(s, i.ChannelID)
+	if !ok {
+		lines = append(lines, "このチャンネルはどのブリッジにも参加していません")
+		return strings.Join(lines, "\n")
+	}
+
+	b, _ := bridge.Get(route.BridgeID)
+	lines = append(lines, fmt.Sprintf("🌉 ブリッジ: %s (ID: `%s`)", b.Name, b.ID))
+	lines = append(lines, bridgeChannelLines(b, hasAdminPermission(i))...)
+	return strings.Join(lines, "\n")
+}
+
+// ブリッジのチャンネルの一覧
+// 他のプラットフォームのチャンネルID（LINE グループIDなど）は管理者にのみ表示し、それ以外にはプラットフォームごとの数を表示する
+func bridgeChannelLines(b model.Bridge, showIDs bool) []string {
+	var lines []string
+	if showIDs {
+		for _, ch := range b.Channels {
+			lines = append(lines, fmt.Sprintf("・%s: `%s`", ch.Platform, ch.ChannelID))
+		}
+		return lines
+	}
+
+	var platforms []model.Platform
+	counts := map[model.Platform]int{}
+	for _, ch := range b.Channels {
+		if counts[ch.Platform] == 0 {
+			platforms = append(platforms, ch.Platform)
+		}
+		counts[ch.Platform]++
+	}
+	for _, platform := range platforms {
+		lines = append(lines, fmt.Sprintf("・%s: %dチャンネル", platform, counts[platform]))
+	}
+	return lines
+}
+
+// /linkgate bridge create|invite|join|leave
+func commandBridge(i *discordgo.InteractionCreate, group *discordgo.ApplicationCommandInteractionDataOption) string {
+	if len(group.Options) == 0 {
+		return "不明なコマンドです"
+	}
+	sub := group.Options[0]
+	ch := model.Channel{Platform: model.PlatformDiscord, ChannelID: i.ChannelID, WorkspaceID: i.GuildID}
+
+	var (
+		b   model.Bridge
+		err error
+	)
+	switch sub.Name {
+	case "invite":
+		b, code, expiresAt, err := bridge.CreateInvite(ch)
+		if err == nil {
+			return fmt.Sprintf("ブリッジ「%s」の招待コードを発行しました。参加させるチャンネルで `/linkgate bridge join code:%s` を実行してください（1回のみ・%s まで有効）",
+				b.Name, code, expiresAt.Local().Format("01/02 15:04"))
+		}
+		if errors.Is(err, bridge.ErrNotJoined) || errors.Is(err, bridge.ErrDefaultBridge) {
+			return err.Error()
+		}
+		log.Printf("招待コードの発行に失敗しました: %v", err)
+		return "招待コードの発行に失敗しました"
+	case "create":
+		b, err = bridge.Create(sub.GetOption("name").StringValue(), ch)
+		if err == nil {
+			return fmt.Sprintf("ブリッジ「%s」を作成しました。他のチャンネルを参加させるには `/linkgate bridge invite` で招待コードを発行してください", b.Name)
+		}
+	case "join":
+		b, err = bridge.JoinWithInvite(strings.TrimSpace(sub.GetOption("code").StringValue()), ch)
+		if err == nil {
+			return fmt.Sprintf("ブリッジ「%s」に参加しました", b.Name)
+		}
+	case "leave":
+		b, err = bridge.Leave(ch)
+		if err == nil {
+			return fmt.Sprintf("ブリッジ「%s」から退出しました", b.Name)
+		}
+	default:
+		return "不明なコマンドです"
+	}
+
+	if errors.Is(err, bridge.ErrNotFound) || errors.Is(err, bridge.ErrChannelInUse) ||
+		errors.Is(err, bridge.ErrNotJoined) || errors.Is(err, bridge.ErrConfigured) ||
+		errors.Is(err, bridge.ErrInvalidInvite) || errors.Is(err, bridge.ErrDefaultBridge) {
+		return err.Error()
+	}
+	log.Printf("ブリッジの更新に失敗しました: %v", err)
+	return "ブリッジの更新に失敗しました"
+}
+
+// /linkgate mute|unmute
+func commandMute(i *discordgo.InteractionCreate, user *discordgo.User, mute bool) string {
+	if user == nil {
+		return "ユーザーが見つかりません"
+	}
+	if err := setMuted(user.ID, i.GuildID, i.Member.User.ID, mute); err != nil {
+		log.Printf("ミュート設定の保存に失敗しました: %v", err)
+		return "ミュート設定の保存に失敗しました"
+	}
+	if mute {
+		return fmt.Sprintf("%s さんの投稿を転送しないようにしました", user.Username)
+	}
+	return fmt.Sprintf("%s さんのミュートを解除しました", user.Username)
+}
+
+// /linkgate who
+func commandWho(i *discordgo.InteractionCreate) string {
+	route, ok := resolveRoute(session, i.ChannelID)
+	if !ok {
+		return "このチャンネルはどのブリッジにも参加していません"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	bridgeFilter := bson.M{"bridgeId": bridge.MessageFilter(route.BridgeID)}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"$and": bson.A{bridgeFilter, bson.M{"createdAt": bson.M{"$gte": time.Now().Add(-whoPeriod)}}},
+		}}},
+		// $last で最新の表示名を使うため、古い順に並べてからまとめる
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":  bson.M{"platform": "$user.platform", "userId": "$user.userId"},
+			"name": bson.M{"$last": "$user.name"},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.platform", Value: 1}, {Key: "name", Value: 1}}}},
+	}
+	cur, err := mongoCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("発言者の集計に失敗しました: %v", err)
+		return "発言者の取得に失敗しました"
+	}
+	defer cur.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			Platform model.Platform `bson:"platform"`
+		} `bson:"_id"`
+		Name string `bson:"name"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		log.Printf("発言者の集計に失敗しました: %v", err)
+		return "発言者の取得に失敗しました"
+	}
+	if len(rows) == 0 {
+		return "直近7日間に発言したユーザーはいません"
+	}
+
+	lines := []string{"👥 直近7日間に発言したユーザー"}
+	for _, row := range rows {
+		lines = append(lines, fmt.Sprintf("・%s (%s)", row.Name, row.ID.Platform))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ミュート設定の保存先
+func muteCollection() *mongo.Collection {
+	return mongoCollection.Database().Collection("discord_mutes")
+}
+
+// loadMutedUsers は保存されているミュート設定を読み込みます。
+func loadMutedUsers() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cur, err := muteCollection().Find(ctx, bson.M{})
+	if err != nil {
+		log.Printf("ミュート設定の読み込みに失敗しました: %v", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	var rows []struct {
+		ID      string `bson:"_id"`
+		GuildID string `bson:"guildId"`
+		UserID  string `bson:"userId"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		log.Printf("ミュート設定の読み込みに失敗しました: %v", err)
+		return
+	}
+
+	mutedMu.Lock()
+	defer mutedMu.Unlock()
+	for _, row := range rows {
+		// 以前の形式（_id がユーザーID）の設定は、ミュートを実行したサーバーにのみ適用する
+		userID := row.UserID
+		if userID == "" {
+			userID = row.ID
+		}
+		mutedUsers[muteKey(row.GuildID, userID)] = struct{}{}
+	}
+}
+
+// ミュート設定を保存する
+func setMuted(userID, guildID, operatorID string, mute bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	key := muteKey(guildID, userID)
+	var err error
+	if mute {
+		_, err = muteCollection().UpdateOne(ctx,
+			bson.M{"_id": key},
+			bson.M{"$set": bson.M{"guildId": guildID, "userId": userID, "mutedBy": operatorID, "mutedAt": time.Now()}},
+			options.Update().SetUpsert(true),
+		)
+	} else {
+		_, err = muteCollection().DeleteMany(ctx, bson.M{"$or": bson.A{
+			bson.M{"_id": key},
+			bson.M{"_id": userID, "guildId": guildID},
+		}})
+	}
+	if err != nil {
+		return err
+	}
+
+	mutedMu.Lock()
+	defer mutedMu.Unlock()
+	if mute {
+		mutedUsers[key] = struct{}{}
+	} else {
+		delete(mutedUsers, key)
+	}
+	return nil
+}
+
+// isMuted はユーザーがサーバーでミュートされているかを返します。
+func isMuted(guildID, userID string) bool {
+	mutedMu.RLock()
+	defer mutedMu.RUnlock()
+	_, ok := mutedUsers[muteKey(guildID, userID)]
+	return ok
+}
+
+func muteKey(guildID, userID string) string {
+	return guildID + ":" + userID
+}
+
+// 現在の送信方式
+func currentSendMode() string {
+	if isWebhookMode() {
+		return SendModeWebhook
+	}
+	return SendModeEmbed
+}
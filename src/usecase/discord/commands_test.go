@@ -0,0 +1,35 @@
+package discord
+
+import (
+	"fuagfuga-2025-LinkGate/src/model"
+	"slices"
+	"testing"
+)
+
+func TestBridgeChannelLines(t *testing.T) {
+	b := model.Bridge{
+		ID:   "general",
+		Name: "General",
+		Channels: []model.Channel{
+			{Platform: model.PlatformDiscord, ChannelID: "d-general"},
+			{Platform: model.PlatformLINE, ChannelID: "C0123456789"},
+			{Platform: model.PlatformDiscord, ChannelID: "d-random"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		showIDs bool
+		want    []string
+	}{
+		{"管理者にはIDを表示", true, []string{"・Discord: `d-general`", "・LINE: `C0123456789`", "・Discord: `d-random`"}},
+		{"それ以外にはプラットフォームごとの数のみ", false, []string{"・Discord: 2チャンネル", "・LINE: 1チャンネル"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bridgeChannelLines(b, tt.showIDs); !slices.Equal(got, tt.want) {
+				t.Errorf("bridgeChannelLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
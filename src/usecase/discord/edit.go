@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/delivery"
+	"github.com/bwmarrin/discordgo"
+)
+
+// EditDiscordMessage は編集されたメッセージの本文を、転送先の Discord メッセージに反映します。
+func EditDiscordMessage(msg model.Message) {
+	if botToken == "" {
+		return
+	}
+
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformDiscord) {
+		var err error
+		if isWebhookMode() {
+			err = editWebhookMessage(d, msg)
+		} else {
+			url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages/%s", targetChannel(d.ChannelID, d.ThreadID), d.ExternalID)
+			_, err = requestEmbed(http.MethodPatch, url, msg)
+		}
+		if err != nil {
+			log.Printf("Discordメッセージの編集に失敗しました (channel: %s, message: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Discordメッセージを編集しました (channel: %s, message: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// DeleteDiscordMessage は削除されたメッセージを、転送先の Discord から削除します。
+func DeleteDiscordMessage(msg model.Message) {
+	if botToken == "" {
+		return
+	}
+
+	s, err := restSession()
+	if err != nil {
+		log.Printf("Discordセッションの作成に失敗しました: %v", err)
+		return
+	}
+
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformDiscord) {
+		if isWebhookMode() {
+			var uri string
+			uri, err = webhookMessageURI(s, d)
+			if err == nil {
+				_, err = s.RequestWithBucketID(http.MethodDelete, uri, nil, discordgo.EndpointWebhookToken("", ""))
+			}
+		} else {
+			err = s.ChannelMessageDelete(targetChannel(d.ChannelID, d.ThreadID), d.ExternalID)
+		}
+		if err != nil {
+			log.Printf("Discordメッセージの削除に失敗しました (channel: %s, message: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Discordメッセージを削除しました (channel: %s, message: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// Webhook から投稿したメッセージの本文を編集する
+func editWebhookMessage(d model.Delivery, msg model.Message) error {
+	s, err := restSession()
+	if err != nil {
+		return err
+	}
+	uri, err := webhookMessageURI(s, d)
+	if err != nil {
+		return err
+	}
+
+	content := createWebhookContent(msg.Content)
+	params := &discordgo.WebhookEdit{
+		Content:         &content,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+	_, err = s.RequestWithBucketID(http.MethodPatch, uri, params, discordgo.EndpointWebhookToken("", ""))
+	return err
+}
+
+// Webhook から投稿したメッセージのURL（スレッド内のメッセージは thread_id が必要）
+func webhookMessageURI(s *discordgo.Session, d model.Delivery) (string, error) {
+	webhook, err := channelWebhook(s, d.ChannelID)
+	if err != nil {
+		return "", err
+	}
+	uri := discordgo.EndpointWebhookMessage(webhook.ID, webhook.Token, d.ExternalID)
+	if d.ThreadID != "" {
+		uri += "?thread_id=" + d.ThreadID
+	}
+	return uri, nil
+}
+
+// スレッド内のメッセージはスレッドを、それ以外はチャンネルを返す
+func targetChannel(channelID, threadID string) string {
+	if threadID != "" {
+		return threadID
+	}
+	return channelID
+}
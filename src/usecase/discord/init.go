@@ -19,35 +19,117 @@ import (
 	"time"
 
 	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/delivery"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
 	"github.com/bwmarrin/discordgo"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Discord API への認証に使用する Bot トークン。
 // 環境変数 DISCORD_BOT_TOKEN に設定してください。
 var botToken = os.Getenv("DISCORD_BOT_TOKEN")
 
-// メッセージ送信先となる Discord チャンネル ID。
-// 環境変数 DISCORD_CHANNEL_ID に設定してください。
-var channelID = os.Getenv("DISCORD_CHANNEL_ID")
-
 // Discord セッション
 var session *discordgo.Session
 
 var mongoCollection *mongo.Collection
 
-// CreateDiscordMessage はMongoDBに新規追加されたメッセージをDiscordへ転送します。
+// CreateDiscordMessage はMongoDBに新規追加されたメッセージを、同じブリッジに属するDiscordチャンネルへ転送します。
 func CreateDiscordMessage(msg model.Message) {
 	if botToken == "" {
 		log.Println("DiscordのBotトークンが設定されていません")
 		return
 	}
-	if channelID == "" {
-		log.Println("DiscordのチャンネルIDが設定されていません")
+
+	channels := bridge.Destinations(msg, model.PlatformDiscord)
+	if len(channels) == 0 {
+		log.Println("送信先のDiscordチャンネルが設定されていません")
 		return
 	}
 
+	// スレッド内のメッセージであれば対応するスレッドへ送信する
+	link, inThread := thread.ForMessage(msg)
+
+	for _, ch := range channels {
+		threadID := ""
+		if inThread {
+			threadID = discordThread(link, ch.ChannelID)
+		}
+
+		// Webhook 方式の場合は送信者になりすまして投稿する
+		if isWebhookMode() {
+			sent, err := sendWebhookMessage(ch.ChannelID, threadID, msg)
+			if err != nil {
+				log.Printf("Discord Webhook での送信に失敗しました (channel: %s): %v", ch.ChannelID, err)
+				continue
+			}
+			log.Printf("Discord送信成功 (Webhook形式, channel: %s)", ch.ChannelID)
+			delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformDiscord, ChannelID: ch.ChannelID, ThreadID: threadID, ExternalID: sent.ID})
+			continue
+		}
+
+		// スレッドは Bot から見ると通常のチャンネルとして送信できる
+		messageID := sendEmbedMessage(targetChannel(ch.ChannelID, threadID), msg)
+		if messageID != "" {
+			delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformDiscord, ChannelID: ch.ChannelID, ThreadID: threadID, ExternalID: messageID})
+		}
+	}
+}
+
+// sendEmbedMessage は Bot として送信者情報を Embed に表示したメッセージを送信し、送信したメッセージIDを返します。
+// 送信に失敗した場合は空文字を返します。
+func sendEmbedMessage(channelID string, msg model.Message) string {
+	url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages", channelID)
+	respBody, err := requestEmbed(http.MethodPost, url, msg)
+	if err != nil {
+		log.Printf("Discordへの送信に失敗しました: %v", err)
+		return ""
+	}
+
+	var sent struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &sent); err != nil {
+		log.Printf("Discordのレスポンスの解析に失敗しました: %v", err)
+	}
+	log.Println("Discord送信成功 (Embed形式)")
+	return sent.ID
+}
+
+// requestEmbed は Embed 形式のメッセージの送信・編集リクエストを送り、レスポンスボディを返します。
+func requestEmbed(method, url string, msg model.Message) ([]byte, error) {
+	body, err := json.Marshal(createEmbedPayload(msg))
+	if err != nil {
+		return nil, fmt.Errorf("DiscordメッセージのJSONエンコードに失敗しました: %w", err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, fmt.Errorf("Discordリクエストの生成に失敗しました: %w", err)
+	}
+	req.Header.Set("Authorization", "Bot "+botToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Discord API 呼び出しでエラーが発生しました: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Discord API からエラーコード %d が返されました: %s", resp.StatusCode, string(respBody))
+	}
+	return respBody, nil
+}
+
+// 送信者情報を author に表示する Embed を作成する
+func createEmbedPayload(msg model.Message) map[string]interface{} {
 	// プラットフォームごとにEmbedカラーを設定
 	var colorInt int
 	switch msg.User.Platform {
@@ -57,12 +139,28 @@ func CreateDiscordMessage(msg model.Message) {
 		colorInt = 0x00C300 // LINEブランドカラー（ライトグリーン）
 	case model.PlatformSlack:
 		colorInt = 0xFFFFFF // Slackは白
+	case model.PlatformTelegram:
+		colorInt = 0x26A5E4 // Telegramブランドカラー（ブルー）
+	case model.PlatformMatrix:
+		colorInt = 0x0DBD8B // Matrix（Element）カラー（グリーン）
+	case model.PlatformIRC:
+		colorInt = 0x6B7280 // IRCはスレートグレー
+	case model.PlatformMattermost:
+		colorInt = 0x1E325C // Mattermostブランドカラー（ネイビー）
+	case model.PlatformEmail:
+		colorInt = 0xEA4335 // メールはレッド
+	case model.PlatformWebhook:
+		colorInt = 0xF59E0B // Webhook連携はオレンジ
+	case model.PlatformAPI:
+		colorInt = 0x6366F1 // Web APIはインディゴ
+	case model.PlatformWeb:
+		colorInt = 0x0EA5E9 // Webチャットはスカイブルー
 	default:
 		colorInt = 0xCCCCCC // その他はグレー
 	}
 
 	// Embedペイロードを作成
-	embedPayload := map[string]interface{}{
+	return map[string]interface{}{
 		"embeds": []map[string]interface{}{
 			{
 				"color": colorInt,
@@ -74,36 +172,6 @@ func CreateDiscordMessage(msg model.Message) {
 			},
 		},
 	}
-
-	body, err := json.Marshal(embedPayload)
-	if err != nil {
-		log.Printf("DiscordメッセージのJSONエンコードに失敗しました: %v", err)
-		return
-	}
-
-	url := fmt.Sprintf("https://discord.com/api/v10/channels/%s/messages", channelID)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		log.Printf("Discordリクエストの生成に失敗しました: %v", err)
-		return
-	}
-	req.Header.Set("Authorization", "Bot "+botToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("Discord API 呼び出しでエラーが発生しました: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		log.Printf("Discord API からエラーコード %d が返されました: %s", resp.StatusCode, string(respBody))
-		return
-	}
-	log.Println("Discord送信成功 (Embed形式)")
 }
 
 func StartDiscordBot(collection *mongo.Collection) error {
@@ -111,11 +179,6 @@ func StartDiscordBot(collection *mongo.Collection) error {
 		log.Println("DiscordのBotトークンが設定されていません")
 		return fmt.Errorf("DISCORD_BOT_TOKEN is not set")
 	}
-	if channelID == "" {
-		log.Println("DiscordのチャンネルIDが設定されていません")
-		return fmt.Errorf("DISCORD_CHANNEL_ID is not set")
-	}
-
 	mongoCollection = collection
 
 	var err error
@@ -126,12 +189,24 @@ func StartDiscordBot(collection *mongo.Collection) error {
 	}
 
 	session.AddHandler(messageCreate)
+	session.AddHandler(onConnect)
+	session.AddHandler(onDisconnect)
+	session.AddHandler(onReady)
+	session.AddHandler(onResumed)
+	session.AddHandler(registerCommands)
+	session.AddHandler(interactionCreate)
 
-	session.Identify.Intents = discordgo.IntentsGuildMessages
+	loadMutedUsers()
 
+	// 参加している全サーバーのメッセージとスレッドを受信する
+	// MessageContent は特権インテントのため Developer Portal で有効化が必要です
+	session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
+
+	setState(StateConnecting)
 	err = session.Open()
 	if err != nil {
 		log.Printf("Discordセッションのオープンに失敗しました: %v", err)
+		setState(StateDisconnected)
 		return err
 	}
 
@@ -146,25 +221,46 @@ func CloseDiscordBot() {
 }
 
 func messageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-	if m.Author.ID == s.State.User.ID {
-		return
+	handleMessage(s, m.Message)
+}
+
+// handleMessage は受信したメッセージ（または取りこぼしを取得したメッセージ）を処理し、新たに保存したかを返します。
+func handleMessage(s *discordgo.Session, m *discordgo.Message) bool {
+	// ブリッジに登録されていないチャンネルのメッセージは無視
+	route, ok := resolveRoute(s, m.ChannelID)
+	if !ok {
+		return false
 	}
 
-	if m.ChannelID != channelID {
-		return
+	// 再接続時に取りこぼしを取得できるよう、最後に受信したメッセージを記録
+	saveLastMessageID(m.ChannelID, m.ID)
+
+	if m.Author == nil || m.Author.ID == s.State.User.ID {
+		return false
 	}
 
-	if m.Type != discordgo.MessageTypeDefault {
-		return
+	// LinkGate の Webhook から転送した投稿は保存しない
+	if m.WebhookID != "" && isOwnWebhook(s, m.WebhookID) {
+		return false
+	}
+
+	if m.Type != discordgo.MessageTypeDefault && m.Type != discordgo.MessageTypeReply {
+		return false
 	}
 
-	SaveDiscordMessageToMongoDB(s, m)
+	// ミュートされたユーザーの投稿は転送しない
+	if isMuted(m.GuildID, m.Author.ID) {
+		return false
+	}
+
+	return SaveDiscordMessageToMongoDB(s, m, route)
 }
 
-func SaveDiscordMessageToMongoDB(s *discordgo.Session, m *discordgo.MessageCreate) {
+// SaveDiscordMessageToMongoDB は Discord のメッセージを保存し、新たに登録したかを返します（登録済みの場合は false）。
+func SaveDiscordMessageToMongoDB(s *discordgo.Session, m *discordgo.Message, route channelRoute) bool {
 	if mongoCollection == nil {
 		log.Println("MongoDB collection is not initialized")
-		return
+		return false
 	}
 
 	userName := m.Author.Username
@@ -185,17 +281,40 @@ func SaveDiscordMessageToMongoDB(s *discordgo.Session, m *discordgo.MessageCreat
 	message.User.IconUrl = iconURL
 	message.Content.ID = primitive.NewObjectID()
 	message.Content.Text = strings.TrimSpace(m.Content)
-	message.CreatedAt = time.Now()
+	message.BridgeID = route.BridgeID
+	message.ChannelID = route.ChannelID
+	message.ThreadID = route.ThreadID
+	message.WorkspaceID = m.GuildID
+	message.ExternalID = m.ID
+	message.CreatedAt = m.Timestamp
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+
+	// スレッド内の投稿であれば、転送先でも同じスレッドにまとめられるよう対応関係を作成
+	if route.ThreadID != "" {
+		ref := model.ThreadRef{Platform: model.PlatformDiscord, ChannelID: route.ChannelID, ThreadID: route.ThreadID}
+		if _, err := thread.Ensure(route.BridgeID, route.ThreadName, ref); err != nil {
+			log.Printf("スレッドの対応関係の作成に失敗しました: %v", err)
+		}
+	}
 
 	// MongoDB にドキュメントを挿入
+	// 取りこぼしの取得とリアルタイム受信が重複しても二重に登録しないよう、メッセージIDで upsert する
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	if _, err := mongoCollection.InsertOne(ctx, message); err != nil {
+	filter := bson.M{"user.platform": model.PlatformDiscord, "externalId": m.ID}
+	result, err := mongoCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": message}, options.Update().SetUpsert(true))
+	if err != nil {
 		log.Printf("Discordメッセージのデータ登録に失敗しました: %v", err)
-		return
+		return false
+	}
+	if result.UpsertedCount == 0 {
+		return false
 	}
 
 	log.Printf("Discord message saved: %s from %s", message.Content.Text, userName)
+	return true
 }
 
 func InitializeDiscordBot(collection *mongo.Collection) {
@@ -0,0 +1,52 @@
+package discord
+
+import (
+	"log"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"github.com/bwmarrin/discordgo"
+)
+
+// channelRoute は Discord のチャンネル（またはスレッド）が属するブリッジの情報です
+type channelRoute struct {
+	// ブリッジID
+	BridgeID string
+	// ブリッジに登録されているチャンネルID
+	ChannelID string
+	// 親チャンネル経由でブリッジに属するスレッドの場合はスレッドID
+	ThreadID string
+	// スレッド名（フォーラムの場合は投稿のタイトル）
+	ThreadName string
+}
+
+// resolveRoute はメッセージが投稿されたチャンネルからブリッジを特定します。
+// チャンネル自身がブリッジに登録されていればそのブリッジを、
+// スレッドやフォーラム投稿の場合は親チャンネルが登録されているブリッジを返します。
+func resolveRoute(s *discordgo.Session, channelID string) (channelRoute, bool) {
+	if b, ok := bridge.Find(model.PlatformDiscord, channelID); ok {
+		return channelRoute{BridgeID: b.ID, ChannelID: channelID}, true
+	}
+
+	ch, err := lookupChannel(s, channelID)
+	if err != nil {
+		log.Printf("Discordチャンネル情報の取得に失敗しました (channel: %s): %v", channelID, err)
+		return channelRoute{}, false
+	}
+	if !ch.IsThread() || ch.ParentID == "" {
+		return channelRoute{}, false
+	}
+
+	if b, ok := bridge.Find(model.PlatformDiscord, ch.ParentID); ok {
+		return channelRoute{BridgeID: b.ID, ChannelID: ch.ParentID, ThreadID: channelID, ThreadName: ch.Name}, true
+	}
+	return channelRoute{}, false
+}
+
+// キャッシュ（State）を優先してチャンネル情報を取得する
+func lookupChannel(s *discordgo.Session, channelID string) (*discordgo.Channel, error) {
+	if ch, err := s.State.Channel(channelID); err == nil {
+		return ch, nil
+	}
+	return s.Channel(channelID)
+}
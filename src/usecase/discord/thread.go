@@ -0,0 +1,61 @@
+package discord
+
+import (
+	"log"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"github.com/bwmarrin/discordgo"
+)
+
+// 作成したスレッドが自動アーカイブされるまでの時間（分）
+const threadArchiveDuration = 1440
+
+// Discord のスレッド名の最大文字数
+const maxThreadNameLength = 100
+
+// discordThread は対応関係に記録されたチャンネル内のスレッドIDを返します。
+// まだスレッドがない場合は作成して対応関係に追加します。作成に失敗した場合は空文字を返し、
+// メッセージはチャンネルへ直接投稿されます。
+func discordThread(link model.ThreadLink, channelID string) string {
+	if ref, ok := thread.Counterpart(link, model.PlatformDiscord, channelID); ok {
+		return ref.ThreadID
+	}
+
+	s, err := restSession()
+	if err != nil {
+		log.Printf("Discordセッションの作成に失敗しました: %v", err)
+		return ""
+	}
+
+	name := truncateRunes(thread.DisplayTitle(link), maxThreadNameLength)
+
+	var created *discordgo.Channel
+	parent, err := lookupChannel(s, channelID)
+	if err == nil && parent.Type == discordgo.ChannelTypeGuildForum {
+		// フォーラムでは最初のメッセージ付きで投稿を作成する
+		created, err = s.ForumThreadStart(channelID, name, threadArchiveDuration, "🧵 "+name)
+	} else {
+		created, err = s.ThreadStart(channelID, name, discordgo.ChannelTypeGuildPublicThread, threadArchiveDuration)
+	}
+	if err != nil {
+		log.Printf("Discordスレッドの作成に失敗しました (channel: %s): %v", channelID, err)
+		return ""
+	}
+
+	ref := model.ThreadRef{Platform: model.PlatformDiscord, ChannelID: channelID, ThreadID: created.ID}
+	if err := thread.AddThread(link.ID, ref); err != nil {
+		log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+	}
+	log.Printf("Discordスレッドを作成しました: %s (thread: %s)", name, created.ID)
+	return created.ID
+}
+
+// 文字数（rune 単位）で切り詰める
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
@@ -0,0 +1,230 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"github.com/bwmarrin/discordgo"
+)
+
+// 転送メッセージの送信方式
+const (
+	// Bot として送信し、送信者を Embed の author に表示する
+	SendModeEmbed = "embed"
+	// チャンネルの Webhook を使い、送信者の名前とアイコンで投稿する
+	SendModeWebhook = "webhook"
+)
+
+// LinkGate が作成・再利用する Webhook の名前
+const webhookName = "LinkGate"
+
+// Webhook のユーザー名に設定できる最大文字数
+const maxWebhookUsernameLength = 80
+
+// 送信方式。環境変数 DISCORD_SEND_MODE に "webhook" を設定すると Webhook 方式になります。
+// Webhook 方式には Bot に「ウェブフックの管理」権限が必要です。
+var sendMode = os.Getenv("DISCORD_SEND_MODE")
+
+// チャンネルIDごとの Webhook キャッシュ
+var (
+	webhooks = map[string]*discordgo.Webhook{}
+	// Webhook ID ごとの LinkGate の Webhook かどうか（キャッシュにない Webhook の判定結果）
+	ownWebhooks = map[string]bool{}
+	webhooksMu  sync.Mutex
+)
+
+// isWebhookMode は Webhook 方式で送信するかを返します。
+func isWebhookMode() bool {
+	return sendMode == SendModeWebhook
+}
+
+// sendWebhookMessage は送信者の名前とアイコンで Webhook からメッセージを投稿し、投稿したメッセージを返します。
+// threadID を指定した場合は、channelID 内のそのスレッドへ投稿します。
+func sendWebhookMessage(channelID, threadID string, msg model.Message) (*discordgo.Message, error) {
+	s, err := restSession()
+	if err != nil {
+		return nil, err
+	}
+
+	params := &discordgo.WebhookParams{
+		Content:   createWebhookContent(msg.Content),
+		Username:  createWebhookUsername(msg.User),
+		AvatarURL: msg.User.IconUrl,
+		// 転送元の @everyone などでメンションが飛ばないようにする
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+
+	for retry := 0; retry < 2; retry++ {
+		webhook, err := channelWebhook(s, channelID)
+		if err != nil {
+			return nil, err
+		}
+
+		var sent *discordgo.Message
+		if threadID != "" {
+			sent, err = s.WebhookThreadExecute(webhook.ID, webhook.Token, true, threadID, params)
+		} else {
+			sent, err = s.WebhookExecute(webhook.ID, webhook.Token, true, params)
+		}
+		if err == nil {
+			return sent, nil
+		}
+
+		// Webhook が削除されていた場合はキャッシュを破棄して作り直す
+		var restErr *discordgo.RESTError
+		if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound {
+			forgetWebhook(channelID)
+			continue
+		}
+		return nil, err
+	}
+	return nil, fmt.Errorf("チャンネル %s の Webhook が見つかりません", channelID)
+}
+
+// チャンネルの LinkGate 用 Webhook を取得する（なければ作成する）
+func channelWebhook(s *discordgo.Session, channelID string) (*discordgo.Webhook, error) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+
+	if webhook, ok := webhooks[channelID]; ok {
+		return webhook, nil
+	}
+
+	existing, err := s.ChannelWebhooks(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("Webhook 一覧の取得に失敗しました: %w", err)
+	}
+	for _, webhook := range existing {
+		if webhook.Name == webhookName && webhook.Token != "" {
+			webhooks[channelID] = webhook
+			return webhook, nil
+		}
+	}
+
+	webhook, err := s.WebhookCreate(channelID, webhookName, "")
+	if err != nil {
+		return nil, fmt.Errorf("Webhook の作成に失敗しました: %w", err)
+	}
+	log.Printf("Discord Webhook を作成しました (channel: %s)", channelID)
+	webhooks[channelID] = webhook
+	return webhook, nil
+}
+
+// キャッシュから Webhook を削除する
+func forgetWebhook(channelID string) {
+	webhooksMu.Lock()
+	delete(webhooks, channelID)
+	webhooksMu.Unlock()
+}
+
+// isOwnWebhook は LinkGate が作成した Webhook からの投稿かを判定します。
+// 再起動後はキャッシュに Webhook がないため、Webhook の作成者が Bot 自身かを API で確認して結果を記録します。
+func isOwnWebhook(s *discordgo.Session, webhookID string) bool {
+	webhooksMu.Lock()
+	for _, webhook := range webhooks {
+		if webhook.ID == webhookID {
+			webhooksMu.Unlock()
+			return true
+		}
+	}
+	own, known := ownWebhooks[webhookID]
+	webhooksMu.Unlock()
+	if known {
+		return own
+	}
+
+	webhook, err := s.Webhook(webhookID)
+	if err != nil {
+		// 権限がない・削除済みの場合は何度も問い合わせないよう LinkGate 以外として記録する
+		var restErr *discordgo.RESTError
+		if !errors.As(err, &restErr) {
+			log.Printf("Webhook の取得に失敗しました (webhook: %s): %v", webhookID, err)
+			return false
+		}
+	}
+	own = err == nil && createdByBot(s, webhook)
+	rememberWebhook(webhookID, own)
+	return own
+}
+
+// loadChannelWebhooks はチャンネルの Webhook が LinkGate のものかを記録します。
+// 取りこぼしの取得前に呼び出し、停止中に LinkGate が転送した投稿を取り込まないようにします。
+func loadChannelWebhooks(s *discordgo.Session, channelID string) {
+	existing, err := s.ChannelWebhooks(channelID)
+	if err != nil {
+		return
+	}
+	for _, webhook := range existing {
+		rememberWebhook(webhook.ID, createdByBot(s, webhook))
+	}
+}
+
+// Webhook が LinkGate のものかを記録する
+func rememberWebhook(webhookID string, own bool) {
+	webhooksMu.Lock()
+	ownWebhooks[webhookID] = own
+	webhooksMu.Unlock()
+}
+
+// Bot 自身が作成した Webhook か
+func createdByBot(s *discordgo.Session, webhook *discordgo.Webhook) bool {
+	if s.State == nil || s.State.User == nil {
+		return false
+	}
+	botID := s.State.User.ID
+	return (webhook.User != nil && webhook.User.ID == botID) || webhook.ApplicationID == botID
+}
+
+// Discord の Webhook のユーザー名に使用できない語句
+var reservedWebhookWords = regexp.MustCompile(`(?i)discord|clyde`)
+
+// 「名前 (プラットフォーム)」形式のユーザー名を作成する
+// Discord は "discord" を含むユーザー名を拒否するため、Discord からの転送にはプラットフォーム名を付けず、
+// 名前に含まれる場合は見た目の似た文字に置き換える
+func createWebhookUsername(user model.User) string {
+	name := user.Name
+	if name == "" {
+		name = "unknown"
+	}
+	username := name
+	if user.Platform != model.PlatformDiscord {
+		username = fmt.Sprintf("%s (%s)", name, user.Platform)
+	}
+	username = reservedWebhookWords.ReplaceAllStringFunc(username, func(word string) string {
+		// o・y をキリル文字の о・у に置き換える
+		return strings.NewReplacer("o", "\u043e", "O", "\u041e", "y", "\u0443", "Y", "\u0423").Replace(word)
+	})
+
+	runes := []rune(username)
+	if len(runes) > maxWebhookUsernameLength {
+		username = string(runes[:maxWebhookUsernameLength])
+	}
+	return username
+}
+
+// 本文と添付ファイルのURLをまとめる（画像URLは Discord 側で自動的に展開されます）
+func createWebhookContent(content model.Content) string {
+	lines := []string{}
+	if content.Text != "" {
+		lines = append(lines, content.Text)
+	}
+	for _, attachment := range content.Attachments {
+		lines = append(lines, attachment.URL)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// REST API 用のセッションを返す（Bot 起動前でも送信できるようにする）
+func restSession() (*discordgo.Session, error) {
+	if session != nil {
+		return session, nil
+	}
+	return discordgo.New("Bot " + botToken)
+}
@@ -0,0 +1,119 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ダイジェストの送信待ちの保存先（再起動しても失われないよう MongoDB に保存する）
+const digestCollectionName = "email_digest"
+
+// digestEntry はダイジェストの送信待ちのメッセージです
+type digestEntry struct {
+	ID primitive.ObjectID `bson:"_id"`
+	// 送信先のメーリングリスト
+	To string `bson:"to"`
+	// 転送するメッセージ
+	Message model.Message `bson:"message"`
+	// 送信待ちに追加した日時
+	QueuedAt time.Time `bson:"queuedAt"`
+}
+
+// queueDigest はメッセージをダイジェストの送信待ちに追加します。
+func queueDigest(to string, msg model.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	entry := digestEntry{ID: primitive.NewObjectID(), To: to, Message: msg, QueuedAt: time.Now()}
+	if _, err := mongoCollection.Database().Collection(digestCollectionName).InsertOne(ctx, entry); err != nil {
+		log.Printf("ダイジェストへの追加に失敗しました (to: %s): %v", to, err)
+	}
+}
+
+// runDigest は一定間隔で送信待ちのメッセージをメーリングリストごとにまとめて送信します。
+func runDigest() {
+	ticker := time.NewTicker(digestInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := sendDigests(); err != nil {
+			log.Printf("ダイジェストの送信に失敗しました: %v", err)
+		}
+	}
+}
+
+func sendDigests() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	coll := mongoCollection.Database().Collection(digestCollectionName)
+	cur, err := coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"queuedAt": 1}))
+	if err != nil {
+		return err
+	}
+	var entries []digestEntry
+	if err := cur.All(ctx, &entries); err != nil {
+		return err
+	}
+
+	// メーリングリストごとにまとめる
+	grouped := map[string][]digestEntry{}
+	for _, entry := range entries {
+		grouped[entry.To] = append(grouped[entry.To], entry)
+	}
+
+	for to, list := range grouped {
+		if err := sendDigest(to, list); err != nil {
+			log.Printf("ダイジェストの送信に失敗しました (to: %s): %v", to, err)
+			continue
+		}
+
+		// 送信できたものだけを送信待ちから削除する
+		ids := make([]primitive.ObjectID, len(list))
+		for i, entry := range list {
+			ids[i] = entry.ID
+		}
+		if _, err := coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			log.Printf("送信済みのダイジェストの削除に失敗しました: %v", err)
+		}
+		log.Printf("ダイジェスト送信成功 (to: %s, %d件)", to, len(list))
+	}
+	return nil
+}
+
+// メーリングリストにダイジェストを送信する
+func sendDigest(to string, entries []digestEntry) error {
+	return sendMail(outboundMail{
+		FromName:  "LinkGate",
+		To:        to,
+		Subject:   fmt.Sprintf("%sダイジェスト（%d件） %s", subjectPrefix, len(entries), time.Now().Format("2006/01/02 15:04")),
+		Body:      createDigestBody(entries),
+		MessageID: newMessageID("digest." + primitive.NewObjectID().Hex()),
+	})
+}
+
+// 本文: 「[時刻] 名前 (プラットフォーム): 本文」を古い順に並べる
+func createDigestBody(entries []digestEntry) string {
+	var b strings.Builder
+	for _, entry := range entries {
+		msg := entry.Message
+		fmt.Fprintf(&b, "[%s] %s (%s):\n", msg.CreatedAt.Local().Format("01/02 15:04"), msg.User.Name, msg.User.Platform)
+		if msg.Content.Text != "" {
+			b.WriteString(msg.Content.Text)
+			b.WriteString("\n")
+		}
+		for _, attachment := range msg.Content.Attachments {
+			fmt.Fprintf(&b, "📎 %s: %s\n", attachment.Type, attachment.URL)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("-- \nこのメールに返信すると、チャットに投稿されます。\n")
+	return b.String()
+}
@@ -0,0 +1,196 @@
+package email
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 1通あたりの最大サイズ（これを超えるメールは取り込まない）
+const maxMailSize = 10 << 20
+
+// imapClient は未読メールの取得に必要な最低限の IMAP4rev1 クライアントです
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse はコマンドに対する応答です
+type imapResponse struct {
+	// 「* 」で始まる応答行
+	Untagged []string
+	// 応答に含まれるリテラル（{n} で送られるデータ）
+	Literals [][]byte
+}
+
+func dialIMAP() (*imapClient, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if imapTLS {
+		host, _, _ := net.SplitHostPort(imapAddr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", imapAddr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", imapAddr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &imapClient{conn: conn, reader: bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	// サーバーの挨拶（* OK ...）
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("IMAP サーバーに接続できませんでした: %s", strings.TrimSpace(greeting))
+	}
+	return c, nil
+}
+
+// command はコマンドを送信し、タグ付きの応答が返るまで読み込みます。
+func (c *imapClient) command(format string, args ...interface{}) (imapResponse, error) {
+	c.tag++
+	tag := fmt.Sprintf("A%03d", c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return imapResponse{}, err
+	}
+
+	var resp imapResponse
+	tooLarge := 0
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return resp, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// 行末が {n} の場合は続く n バイトがリテラル
+		// 大きすぎるリテラルも読み捨てて、以降の応答とずれないようにする
+		if n, ok := literalSize(line); ok {
+			if n > maxMailSize {
+				if _, err := io.CopyN(io.Discard, c.reader, int64(n)); err != nil {
+					return resp, err
+				}
+				tooLarge = n
+				continue
+			}
+			data := make([]byte, n)
+			if _, err := io.ReadFull(c.reader, data); err != nil {
+				return resp, err
+			}
+			resp.Literals = append(resp.Literals, data)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "* "):
+			resp.Untagged = append(resp.Untagged, line)
+		case strings.HasPrefix(line, tag+" OK"):
+			if tooLarge > 0 {
+				return resp, fmt.Errorf("メールのサイズが大きすぎます: %d bytes", tooLarge)
+			}
+			return resp, nil
+		case strings.HasPrefix(line, tag+" "):
+			return resp, fmt.Errorf("IMAP コマンドが失敗しました: %s", strings.TrimPrefix(line, tag+" "))
+		}
+	}
+}
+
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(line[i+1 : len(line)-1])
+	return n, err == nil
+}
+
+func (c *imapClient) login(user, password string) error {
+	_, err := c.command("LOGIN %s %s", quoteIMAP(user), quoteIMAP(password))
+	return err
+}
+
+func (c *imapClient) selectMailbox(name string) error {
+	_, err := c.command("SELECT %s", quoteIMAP(name))
+	return err
+}
+
+// searchUnseen は未読メールの UID を返します。
+func (c *imapClient) searchUnseen() ([]string, error) {
+	resp, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, line := range resp.Untagged {
+		if rest, ok := strings.CutPrefix(line, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+	return uids, nil
+}
+
+// size はメールのサイズ（RFC822.SIZE）を返します。
+func (c *imapClient) size(uid string) (int, error) {
+	resp, err := c.command("UID FETCH %s (RFC822.SIZE)", uid)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range resp.Untagged {
+		fields := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(line))
+		for i := 0; i+1 < len(fields); i++ {
+			if strings.EqualFold(fields[i], "RFC822.SIZE") {
+				return strconv.Atoi(fields[i+1])
+			}
+		}
+	}
+	return 0, fmt.Errorf("メールのサイズを取得できませんでした (uid: %s)", uid)
+}
+
+// fetch はメール全体を既読にせずに取得します。
+func (c *imapClient) fetch(uid string) ([]byte, error) {
+	resp, err := c.command("UID FETCH %s BODY.PEEK[]", uid)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Literals) == 0 {
+		return nil, fmt.Errorf("メールを取得できませんでした (uid: %s)", uid)
+	}
+	return resp.Literals[0], nil
+}
+
+// markSeen はメールを既読にします。
+func (c *imapClient) markSeen(uid string) error {
+	_, err := c.command(`UID STORE %s +FLAGS.SILENT (\Seen)`, uid)
+	return err
+}
+
+// flagSkipped は取り込まなかったメールを既読にし、確認できるようフラグを付けます。
+func (c *imapClient) flagSkipped(uid string) error {
+	_, err := c.command(`UID STORE %s +FLAGS.SILENT (\Seen \Flagged)`, uid)
+	return err
+}
+
+func (c *imapClient) logout() {
+	c.command("LOGOUT")
+	c.conn.Close()
+}
+
+// IMAP の quoted string に変換する
+func quoteIMAP(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,187 @@
+package email
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIMAP は IMAP サーバーのスタンドインです。受信したコマンドを記録します
+type fakeIMAP struct {
+	listener net.Listener
+	// UID ごとのメール
+	mails map[string]string
+	// UID ごとに RFC822.SIZE として返すサイズ（未設定の場合はメールの長さ）
+	sizes map[string]int
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newFakeIMAP(t *testing.T, mails map[string]string, sizes map[string]int) *fakeIMAP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeIMAP{listener: listener, mails: mails, sizes: sizes}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	prevAddr, prevTLS := imapAddr, imapTLS
+	imapAddr, imapTLS = listener.Addr().String(), false
+	t.Cleanup(func() { imapAddr, imapTLS = prevAddr, prevTLS })
+	return s
+}
+
+func (s *fakeIMAP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeIMAP) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	reader := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake IMAP ready\r\n")
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		fields := strings.Fields(command)
+		switch {
+		case strings.HasPrefix(command, "UID SEARCH"):
+			var uids []string
+			for uid := range s.mails {
+				uids = append(uids, uid)
+			}
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case strings.HasPrefix(command, "UID FETCH") && strings.Contains(command, "RFC822.SIZE"):
+			uid := fields[2]
+			size, ok := s.sizes[uid]
+			if !ok {
+				size = len(s.mails[uid])
+			}
+			fmt.Fprintf(conn, "* 1 FETCH (UID %s RFC822.SIZE %d)\r\n", uid, size)
+		case strings.HasPrefix(command, "UID FETCH"):
+			mail := s.mails[fields[2]]
+			fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n%s)\r\n", fields[2], len(mail), mail)
+		case command == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+func (s *fakeIMAP) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+func TestPollIMAP(t *testing.T) {
+	mail := strings.Join([]string{
+		"From: alice@example.com",
+		"To: team@lists.example.com",
+		"Subject: hello",
+		"",
+		"body",
+	}, "\r\n")
+	server := newFakeIMAP(t,
+		map[string]string{"1": mail, "2": "large"},
+		map[string]int{"2": maxMailSize + 1},
+	)
+
+	// MongoDB が未設定のため uid 1 の取り込みは失敗する
+	prevCollection := mongoCollection
+	mongoCollection = nil
+	defer func() { mongoCollection = prevCollection }()
+
+	if err := pollIMAP(); err != nil {
+		t.Fatalf("pollIMAP() error: %v", err)
+	}
+
+	commands := strings.Join(server.received(), "\n")
+	tests := []struct {
+		name    string
+		command string
+		want    bool
+	}{
+		{name: "uid 1 を取得する", command: "UID FETCH 1 BODY.PEEK[]", want: true},
+		{name: "取り込めなかった uid 1 は既読にしない", command: "UID STORE 1", want: false},
+		{name: "大きすぎる uid 2 は本文を取得しない", command: "UID FETCH 2 BODY.PEEK[]", want: false},
+		{name: "大きすぎる uid 2 は既読・フラグ付きにする", command: `UID STORE 2 +FLAGS.SILENT (\Seen \Flagged)`, want: true},
+	}
+	for _, tt := range tests {
+		if got := strings.Contains(commands, tt.command); got != tt.want {
+			t.Errorf("%s: sent %q = %v, want %v\ncommands:\n%s", tt.name, tt.command, got, tt.want, commands)
+		}
+	}
+}
+
+// 大きすぎるリテラルを受信しても、以降のコマンドの応答がずれないことを確認する
+func TestCommandSkipsOversizeLiteral(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		reader := bufio.NewReader(server)
+		reader.ReadString('\n')
+		fmt.Fprintf(server, "* 1 FETCH (UID 1 BODY[] {%d}\r\n", maxMailSize+1)
+		server.Write(make([]byte, maxMailSize+1))
+		fmt.Fprint(server, ")\r\nA001 OK FETCH completed\r\n")
+
+		reader.ReadString('\n')
+		fmt.Fprint(server, "* SEARCH 5\r\nA002 OK SEARCH completed\r\n")
+	}()
+
+	c := &imapClient{conn: client, reader: bufio.NewReader(client)}
+	if _, err := c.fetch("1"); err == nil || !strings.Contains(err.Error(), "大きすぎます") {
+		t.Fatalf("fetch() error = %v, want size error", err)
+	}
+	uids, err := c.searchUnseen()
+	if err != nil {
+		t.Fatalf("searchUnseen() error: %v", err)
+	}
+	if len(uids) != 1 || uids[0] != "5" {
+		t.Errorf("searchUnseen() = %v, want [5]", uids)
+	}
+}
+
+func TestLiteralSize(t *testing.T) {
+	tests := []struct {
+		line   string
+		want   int
+		wantOK bool
+	}{
+		{line: "* 1 FETCH (UID 1 BODY[] {123}", want: 123, wantOK: true},
+		{line: "* 1 FETCH (UID 1 RFC822.SIZE 10)", wantOK: false},
+		{line: "{abc}", wantOK: false},
+		{line: "}", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := literalSize(tt.line)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("literalSize(%q) = (%d, %v), want (%d, %v)", tt.line, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
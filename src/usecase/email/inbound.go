@@ -0,0 +1,88 @@
+package email
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleInbound は受信メール Webhook（SendGrid Inbound Parse・Mailgun Routes など）でメールを受け取ります。
+// 本文にメール全体（message/rfc822）を送るか、フォームの email / body-mime フィールドにメール全体を含めてください。
+// 認証には X-LinkGate-Token ヘッダーまたは token クエリパラメータに EMAIL_INBOUND_TOKEN を指定します。
+func HandleInbound(c *gin.Context) {
+	if inboundToken == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "受信メール Webhook が設定されていません"})
+		return
+	}
+	token := c.GetHeader("X-LinkGate-Token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(inboundToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "トークンが正しくありません"})
+		return
+	}
+
+	// トークンを確認してから本文を読み込む。フォームの場合も含めて maxMailSize を超える本文は受け付けない
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxMailSize)
+
+	var raw []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") || c.ContentType() == "application/x-www-form-urlencoded" {
+		if err := parseForm(c.Request); err != nil {
+			respondReadError(c, err)
+			return
+		}
+		for _, field := range []string{"email", "body-mime"} {
+			if value := c.Request.PostFormValue(field); value != "" {
+				raw = []byte(value)
+				break
+			}
+		}
+	} else {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondReadError(c, err)
+			return
+		}
+		raw = body
+	}
+	if len(raw) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "メールが含まれていません"})
+		return
+	}
+
+	if err := Ingest(raw); err != nil {
+		log.Printf("受信メールの取り込みに失敗しました: %v", err)
+		// 本文が空のメールなどは再送されても取り込めないため 200 を返す
+		if err == errEmptyMail {
+			c.JSON(http.StatusOK, gin.H{"message": "本文がないため取り込みませんでした"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "メールの取り込みに失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "メールを受信しました"})
+}
+
+// フォームを解析する。本文は maxMailSize までのため、添付ファイルも一時ファイルに書き出さずメモリ上で扱う
+func parseForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(maxMailSize)
+	}
+	return r.ParseForm()
+}
+
+// 本文の読み込みに失敗した場合のレスポンス（上限を超えた場合は 413）
+func respondReadError(c *gin.Context, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "メールのサイズが上限を超えています"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "リクエストの読み込みに失敗しました", "details": err.Error()})
+}
@@ -0,0 +1,92 @@
+package email
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 読み込まれたバイト数を数える本文
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestHandleInbound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := inboundToken
+	inboundToken = "inbound-secret"
+	t.Cleanup(func() { inboundToken = prev })
+
+	oversize := strings.Repeat("a", maxMailSize+1)
+
+	multipartBody := func(field, value string) (string, []byte) {
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		w.WriteField(field, value)
+		w.Close()
+		return w.FormDataContentType(), b.Bytes()
+	}
+	largeType, largeForm := multipartBody("email", oversize)
+	otherType, otherForm := multipartBody("subject", "hello")
+
+	tests := []struct {
+		name        string
+		token       string
+		contentType string
+		body        []byte
+		wantStatus  int
+		// 本文を読まずに拒否する
+		wantUnread bool
+	}{
+		{name: "トークンなし", contentType: "message/rfc822", body: []byte(oversize), wantStatus: http.StatusUnauthorized, wantUnread: true},
+		{name: "トークンが異なる", token: "wrong", contentType: largeType, body: largeForm, wantStatus: http.StatusUnauthorized, wantUnread: true},
+		{name: "メール全体が上限超過", token: "inbound-secret", contentType: "message/rfc822", body: []byte(oversize), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "multipart が上限超過", token: "inbound-secret", contentType: largeType, body: largeForm, wantStatus: http.StatusRequestEntityTooLarge},
+		{
+			name: "urlencoded が上限超過", token: "inbound-secret", contentType: "application/x-www-form-urlencoded",
+			body: []byte(url.Values{"email": {oversize}}.Encode()), wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{name: "メールのフィールドがない", token: "inbound-secret", contentType: otherType, body: otherForm, wantStatus: http.StatusBadRequest},
+		{name: "本文が空", token: "inbound-secret", contentType: "message/rfc822", wantStatus: http.StatusBadRequest},
+	}
+
+	r := gin.New()
+	r.POST("/email/inbound", HandleInbound)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &countingReader{r: bytes.NewReader(tt.body)}
+			req := httptest.NewRequest(http.MethodPost, "/email/inbound", body)
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.token != "" {
+				req.Header.Set("X-LinkGate-Token", tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantUnread && body.n != 0 {
+				t.Errorf("read %d bytes before authentication, want 0", body.n)
+			}
+			if body.n > maxMailSize+1 {
+				t.Errorf("read %d bytes, want at most %d", body.n, maxMailSize+1)
+			}
+		})
+	}
+}
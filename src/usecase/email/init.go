@@ -0,0 +1,316 @@
+package email
+
+// このパッケージはメールとのメッセージの送受信を担当します。
+// 他のプラットフォームのメッセージはメーリングリスト宛てに SMTP で送信し（1通ずつ、またはダイジェスト）、
+// メーリングリストへの返信は IMAP メールボックスのポーリングまたは受信メールの Webhook で取り込みます。
+// Message-ID / In-Reply-To でメールのスレッドと他のプラットフォームのスレッドを対応付けます。
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 件名に使用する本文の最大文字数
+const maxSubjectLength = 40
+
+// 件名の接頭辞
+const subjectPrefix = "[LinkGate] "
+
+var (
+	// 送信元アドレス（Message-ID のドメインにも使用します）
+	fromAddress = os.Getenv("EMAIL_FROM")
+
+	// SMTP サーバー
+	smtpHost     = os.Getenv("EMAIL_SMTP_HOST")
+	smtpPort     = defaultString(os.Getenv("EMAIL_SMTP_PORT"), "587")
+	smtpUser     = os.Getenv("EMAIL_SMTP_USER")
+	smtpPassword = os.Getenv("EMAIL_SMTP_PASSWORD")
+	// 接続時から TLS を使用するか（ポート465の場合は true）
+	smtpTLS = os.Getenv("EMAIL_SMTP_TLS") == "true"
+
+	// IMAP サーバー（ホスト:ポート）。未設定の場合は IMAP から取り込みません
+	imapAddr     = os.Getenv("EMAIL_IMAP_ADDR")
+	imapUser     = os.Getenv("EMAIL_IMAP_USER")
+	imapPassword = os.Getenv("EMAIL_IMAP_PASSWORD")
+	imapMailbox  = defaultString(os.Getenv("EMAIL_IMAP_MAILBOX"), "INBOX")
+	imapTLS      = os.Getenv("EMAIL_IMAP_TLS") != "false"
+	imapInterval = parseDuration(os.Getenv("EMAIL_IMAP_INTERVAL"), time.Minute)
+
+	// ダイジェストの送信間隔。設定した場合はメッセージをまとめて送信します
+	digestInterval = parseDuration(os.Getenv("EMAIL_DIGEST_INTERVAL"), 0)
+
+	// 受信メール Webhook の認証トークン
+	inboundToken = os.Getenv("EMAIL_INBOUND_TOKEN")
+)
+
+var mongoCollection *mongo.Collection
+
+// Enabled はメールの送信が設定されているかを返します。
+func Enabled() bool {
+	return smtpHost != "" && fromAddress != ""
+}
+
+// InitializeEmailGateway はメール連携を開始します。
+// IMAP が設定されている場合はメールボックスのポーリングを、ダイジェストが有効な場合は定期送信を開始します。
+func InitializeEmailGateway(collection *mongo.Collection) {
+	if !Enabled() {
+		log.Println("EMAIL_SMTP_HOST / EMAIL_FROM が設定されていないためメール連携は無効です")
+		return
+	}
+	mongoCollection = collection
+
+	if digestInterval > 0 {
+		go runDigest()
+	}
+
+	if imapAddr == "" {
+		log.Println("🔍 Email gateway started (smtp only)")
+		return
+	}
+	log.Printf("🔍 Email gateway started (imap: %s, every %s)", imapAddr, imapInterval)
+	for {
+		if err := pollIMAP(); err != nil {
+			log.Printf("IMAP からのメール取得に失敗しました: %v", err)
+		}
+		time.Sleep(imapInterval)
+	}
+}
+
+// pollIMAP は未読メールを取り込み、既読にします。
+func pollIMAP() error {
+	c, err := dialIMAP()
+	if err != nil {
+		return err
+	}
+	defer c.logout()
+
+	if err := c.login(imapUser, imapPassword); err != nil {
+		return err
+	}
+	if err := c.selectMailbox(imapMailbox); err != nil {
+		return err
+	}
+	uids, err := c.searchUnseen()
+	if err != nil {
+		return err
+	}
+
+	for _, uid := range uids {
+		// 大きすぎるメールは本文を取得せず、繰り返し処理しないよう既読・フラグ付きにする
+		size, err := c.size(uid)
+		if err != nil {
+			log.Printf("メールのサイズの取得に失敗しました (uid: %s): %v", uid, err)
+			continue
+		}
+		if size > maxMailSize {
+			log.Printf("メールのサイズが大きすぎるため取り込みません (uid: %s, %d bytes)", uid, size)
+			if err := c.flagSkipped(uid); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, err := c.fetch(uid)
+		if err != nil {
+			log.Printf("メールの取得に失敗しました (uid: %s): %v", uid, err)
+			continue
+		}
+		// 取り込みに失敗したメールは未読のまま残し、次回のポーリングで再試行する
+		// （本文が空のメールは再試行しても取り込めないため既読にする）
+		if err := Ingest(raw); err != nil && err != errEmptyMail {
+			log.Printf("メールの取り込みに失敗しました (uid: %s): %v", uid, err)
+			continue
+		}
+		if err := c.markSeen(uid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ingest は受信したメール（RFC 5322 形式）を解析し、ブリッジ宛てであれば model.Message として保存します。
+func Ingest(raw []byte) error {
+	if mongoCollection == nil {
+		return fmt.Errorf("MongoDB collection is not initialized")
+	}
+
+	m, err := parseMail(raw)
+	if err != nil {
+		return err
+	}
+
+	// LinkGate が送信したメールは取り込まない（ループ防止）
+	if m.FromLinkGate || m.FromAddr == strings.ToLower(fromAddress) {
+		return nil
+	}
+
+	// 宛先のメーリングリストからブリッジを決める
+	var b model.Bridge
+	var listAddr string
+	for _, addr := range m.Recipients {
+		if found, ok := bridge.Find(model.PlatformEmail, addr); ok {
+			b, listAddr = found, addr
+			break
+		}
+	}
+	if listAddr == "" {
+		return nil
+	}
+
+	text := stripQuoted(m.Body)
+	root := m.RootID()
+	// 返信でないメールは件名も本文に含める
+	if root == "" && m.Subject != "" {
+		text = strings.TrimSpace(m.Subject + "\n" + text)
+	}
+	if text == "" {
+		return errEmptyMail
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = m.FromAddr
+	message.User.Platform = model.PlatformEmail
+	message.User.Name = m.FromName
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = text
+	message.BridgeID = b.ID
+	message.ChannelID = listAddr
+	message.ExternalID = m.MessageID
+	message.CreatedAt = m.Date
+
+	// 返信はスレッドの起点のメールごとにスレッドとして対応付ける
+	if root != "" {
+		ref := model.ThreadRef{Platform: model.PlatformEmail, ChannelID: listAddr, ThreadID: root}
+		if _, err := thread.Ensure(b.ID, truncateRunes(cleanSubject(m.Subject), maxSubjectLength), ref); err != nil {
+			log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+		}
+		message.ThreadID = root
+	}
+
+	// MongoDB にドキュメントを挿入（IMAP と Webhook の両方で受信しても二重登録しないよう upsert する）
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if message.ExternalID == "" {
+		message.ExternalID = message.ID.Hex()
+	}
+	filter := bson.M{"user.platform": model.PlatformEmail, "externalId": message.ExternalID}
+	if _, err := mongoCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": message}, options.Update().SetUpsert(true)); err != nil {
+		return err
+	}
+
+	log.Printf("Email message saved: %s from %s", message.Content.Text, message.User.Name)
+	return nil
+}
+
+// CreateEmailMessage はMongoDBに新規追加されたメッセージを、同じブリッジのメーリングリストへ送信します。
+// ダイジェストが有効な場合は送信待ちに追加し、一定間隔でまとめて送信します。
+func CreateEmailMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	channels := bridge.Destinations(msg, model.PlatformEmail)
+	if len(channels) == 0 {
+		return
+	}
+
+	for _, ch := range channels {
+		if digestInterval > 0 {
+			queueDigest(ch.ChannelID, msg)
+			continue
+		}
+
+		m := outboundMail{
+			FromName:  fmt.Sprintf("%s (%s)", msg.User.Name, msg.User.Platform),
+			To:        ch.ChannelID,
+			Subject:   subjectPrefix + createSubject(msg),
+			Body:      createEmailBody(msg),
+			MessageID: newMessageID(msg.ID.Hex() + "." + primitive.NewObjectID().Hex()),
+		}
+
+		// スレッド内のメッセージは起点のメールへの返信として送信する
+		if link, ok := thread.ForMessage(msg); ok {
+			title := thread.DisplayTitle(link)
+			if ref, ok := thread.Counterpart(link, model.PlatformEmail, ch.ChannelID); ok {
+				m.Subject = "Re: " + subjectPrefix + title
+				m.InReplyTo = ref.ThreadID
+				m.References = []string{ref.ThreadID}
+			} else {
+				// このメールをスレッドの起点にする
+				m.Subject = subjectPrefix + title
+				ref := model.ThreadRef{Platform: model.PlatformEmail, ChannelID: ch.ChannelID, ThreadID: m.MessageID}
+				if err := thread.AddThread(link.ID, ref); err != nil {
+					log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+				}
+			}
+		}
+
+		if err := sendMail(m); err != nil {
+			log.Printf("メールの送信に失敗しました (to: %s): %v", ch.ChannelID, err)
+			continue
+		}
+		log.Printf("メール送信成功 (to: %s)", ch.ChannelID)
+	}
+}
+
+// 件名: 本文の1行目（本文がない場合は送信者）
+func createSubject(msg model.Message) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(msg.Content.Text), "\n")
+	if first == "" {
+		return fmt.Sprintf("%s (%s) からのメッセージ", msg.User.Name, msg.User.Platform)
+	}
+	return truncateRunes(first, maxSubjectLength)
+}
+
+// 本文: 本文 + 添付ファイルのURL + 返信の案内
+func createEmailBody(msg model.Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s):\n\n", msg.User.Name, msg.User.Platform)
+	if msg.Content.Text != "" {
+		b.WriteString(msg.Content.Text)
+		b.WriteString("\n")
+	}
+	for _, attachment := range msg.Content.Attachments {
+		fmt.Fprintf(&b, "\n📎 %s: %s", attachment.Type, attachment.URL)
+	}
+	b.WriteString("\n\n-- \nこのメールに返信すると、チャットに投稿されます。\n")
+	return b.String()
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max]) + "…"
+	}
+	return s
+}
+
+func parseDuration(value string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,286 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// 本文として読み込む最大サイズ
+const maxBodySize = 1 << 20
+
+// inboundMail は受信したメールのうち、中継に必要な情報です
+type inboundMail struct {
+	MessageID  string
+	InReplyTo  string
+	References []string
+	FromName   string
+	FromAddr   string
+	Recipients []string
+	Subject    string
+	Body       string
+	Date       time.Time
+	// LinkGate が送信したメールか（X-LinkGate ヘッダー）
+	FromLinkGate bool
+}
+
+// RootID はスレッドの起点となるメールの Message-ID を返します（返信でない場合は空文字）。
+func (m inboundMail) RootID() string {
+	if len(m.References) > 0 {
+		return m.References[0]
+	}
+	return m.InReplyTo
+}
+
+// 文字コード（ISO-2022-JP など）に対応したヘッダーのデコーダー
+var wordDecoder = &mime.WordDecoder{CharsetReader: charsetReader}
+
+// parseMail は RFC 5322 形式のメールを解析します。
+func parseMail(raw []byte) (inboundMail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return inboundMail{}, err
+	}
+	header := msg.Header
+
+	var m inboundMail
+	m.MessageID = normalizeID(header.Get("Message-ID"))
+	m.InReplyTo = normalizeID(header.Get("In-Reply-To"))
+	for _, ref := range strings.Fields(header.Get("References")) {
+		if id := normalizeID(ref); id != "" {
+			m.References = append(m.References, id)
+		}
+	}
+	m.FromLinkGate = header.Get("X-LinkGate") != ""
+
+	parser := &mail.AddressParser{WordDecoder: wordDecoder}
+	if from, err := parser.Parse(header.Get("From")); err == nil {
+		m.FromName = from.Name
+		m.FromAddr = strings.ToLower(from.Address)
+	}
+	if m.FromName == "" {
+		m.FromName, _, _ = strings.Cut(m.FromAddr, "@")
+	}
+	for _, key := range []string{"To", "Cc", "Delivered-To", "X-Original-To"} {
+		if list, err := parser.ParseList(header.Get(key)); err == nil {
+			for _, addr := range list {
+				m.Recipients = append(m.Recipients, strings.ToLower(addr.Address))
+			}
+		}
+	}
+
+	if subject, err := wordDecoder.DecodeHeader(header.Get("Subject")); err == nil {
+		m.Subject = subject
+	} else {
+		m.Subject = header.Get("Subject")
+	}
+	if date, err := header.Date(); err == nil {
+		m.Date = date
+	} else {
+		m.Date = time.Now()
+	}
+
+	body, err := readBody(header, msg.Body)
+	if err != nil {
+		return inboundMail{}, err
+	}
+	m.Body = body
+	return m, nil
+}
+
+// メール全体（mail.Header）とマルチパートの各パート（textproto.MIMEHeader）のヘッダー
+type partHeader interface {
+	Get(key string) string
+}
+
+// readBody は本文をテキストとして読み込みます。マルチパートの場合は text/plain を優先し、
+// text/html しかない場合はタグを取り除いて使用します。
+func readBody(header partHeader, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var htmlBody string
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			// 添付ファイルは読み込まない
+			if disposition, _, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition")); disposition == "attachment" {
+				continue
+			}
+			text, err := readBody(part.Header, part)
+			if err != nil {
+				continue
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType == "text/html" {
+				htmlBody = text
+				continue
+			}
+			if text != "" {
+				return text, nil
+			}
+		}
+		return htmlBody, nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", nil
+	}
+
+	decoded, err := decodeTransfer(header.Get("Content-Transfer-Encoding"), io.LimitReader(body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+	text, err := decodeCharset(params["charset"], decoded)
+	if err != nil {
+		return "", err
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if mediaType == "text/html" {
+		text = htmlToText(text)
+	}
+	return text, nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, newlineStripper{r}))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(r))
+	default:
+		return io.ReadAll(r)
+	}
+}
+
+// base64 の本文に含まれる改行を取り除く
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	j := 0
+	for _, c := range p[:n] {
+		if c != '\r' && c != '\n' {
+			p[j] = c
+			j++
+		}
+	}
+	return j, err
+}
+
+// decodeCharset は指定された文字コードのテキストを UTF-8 に変換します。
+func decodeCharset(charset string, data []byte) (string, error) {
+	if charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "us-ascii") {
+		return string(data), nil
+	}
+	reader, err := charsetReader(charset, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	decoded, err := io.ReadAll(reader)
+	return string(decoded), err
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("未対応の文字コードです: %s", charset)
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlBlockPattern = regexp.MustCompile(`(?is)<(style|script|head)[^>]*>.*?</(style|script|head)>`)
+)
+
+// HTML のタグを取り除いてテキストにする
+func htmlToText(s string) string {
+	s = htmlBlockPattern.ReplaceAllString(s, "")
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	return html.UnescapeString(s)
+}
+
+// 引用の開始とみなす行
+var quoteHeaderPatterns = []*regexp.Regexp{
+	// On Mon, Jan 1, 2025 at 10:00 AM Name <addr> wrote:
+	regexp.MustCompile(`^On .+ wrote:$`),
+	// 2025年1月1日(水) 10:00 Name <addr>:
+	regexp.MustCompile(`^\d{4}年\d{1,2}月\d{1,2}日.*:$`),
+	// 2025/01/01 10:00、Name <addr> のメッセージ:
+	regexp.MustCompile(`のメッセージ:$`),
+	// -----Original Message----- / -------- 転送メッセージ --------
+	regexp.MustCompile(`^-{2,}\s*(Original Message|Forwarded message|元のメッセージ|転送メッセージ)\s*-{2,}$`),
+	// Outlook の引用ヘッダー
+	regexp.MustCompile(`^(From|差出人): .+`),
+}
+
+// stripQuoted は返信メールの本文から引用部分と署名を取り除きます。
+func stripQuoted(body string) string {
+	lines := strings.Split(body, "\n")
+	result := []string{}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// 署名の区切り（「-- 」）以降は本文に含めない
+		if line == "-- " || line == "--" {
+			break
+		}
+		if isQuoteHeader(trimmed) {
+			break
+		}
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		result = append(result, strings.TrimRight(line, " \t"))
+	}
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
+
+func isQuoteHeader(line string) bool {
+	for _, pattern := range quoteHeaderPatterns {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// 件名から「Re:」「Fwd:」「[LinkGate]」などの接頭辞を取り除く
+var subjectPrefixPattern = regexp.MustCompile(`(?i)^\s*((re|fwd?|aw|返信|転送)\s*[:：]\s*|\[[^\]]*\]\s*)+`)
+
+func cleanSubject(subject string) string {
+	return strings.TrimSpace(subjectPrefixPattern.ReplaceAllString(subject, ""))
+}
+
+// Message-ID の「<>」と空白を取り除く
+func normalizeID(id string) string {
+	return strings.Trim(strings.TrimSpace(id), "<>")
+}
+
+var errEmptyMail = errors.New("メールの本文がありません")
@@ -0,0 +1,151 @@
+package email
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// テスト用のメールを組み立てる（改行は CRLF にする）
+func rawMail(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+func TestParseMail(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want inboundMail
+	}{
+		{
+			name: "テキストの返信",
+			raw: rawMail(
+				"From: Alice Example <Alice@Example.com>",
+				"To: team@lists.example.com",
+				"Cc: Bob <bob@example.com>",
+				"Subject: Re: [LinkGate] hello",
+				"Message-ID: <reply-1@example.com>",
+				"In-Reply-To: <root@example.com>",
+				"References: <root@example.com> <second@example.com>",
+				"Date: Mon, 02 Jan 2006 15:04:05 +0000",
+				"",
+				"thanks!",
+			),
+			want: inboundMail{
+				MessageID:  "reply-1@example.com",
+				InReplyTo:  "root@example.com",
+				References: []string{"root@example.com", "second@example.com"},
+				FromName:   "Alice Example",
+				FromAddr:   "alice@example.com",
+				Recipients: []string{"team@lists.example.com", "bob@example.com"},
+				Subject:    "Re: [LinkGate] hello",
+				Body:       "thanks!",
+			},
+		},
+		{
+			name: "ISO-2022-JP の件名と本文",
+			raw: rawMail(
+				"From: taro@example.jp",
+				"To: team@lists.example.com",
+				"Subject: =?ISO-2022-JP?B?GyRCMnE1RCROJCpDTiRpJDsbKEI=?=",
+				"Content-Type: text/plain; charset=ISO-2022-JP",
+				"Content-Transfer-Encoding: base64",
+				"",
+				"GyRCS1xKOCRHJDkbKEI=",
+			),
+			want: inboundMail{
+				FromName:   "taro",
+				FromAddr:   "taro@example.jp",
+				Recipients: []string{"team@lists.example.com"},
+				Subject:    "会議のお知らせ",
+				Body:       "本文です",
+			},
+		},
+		{
+			name: "HTML のみのマルチパート",
+			raw: rawMail(
+				"From: carol@example.com",
+				"To: team@lists.example.com",
+				"X-LinkGate: 1",
+				`Content-Type: multipart/alternative; boundary="b1"`,
+				"",
+				"--b1",
+				"Content-Type: text/html; charset=UTF-8",
+				"",
+				"<p>Hello&amp;bye<br>next</p>",
+				"--b1--",
+			),
+			want: inboundMail{
+				FromName:     "carol",
+				FromAddr:     "carol@example.com",
+				Recipients:   []string{"team@lists.example.com"},
+				Body:         "Hello&bye\nnext",
+				FromLinkGate: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMail(tt.raw)
+			if err != nil {
+				t.Fatalf("parseMail() error: %v", err)
+			}
+			got.Body = strings.TrimSpace(got.Body)
+			// Date ヘッダーがない場合は受信時刻になるため比較しない
+			got.Date = tt.want.Date
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMail() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMailInvalid(t *testing.T) {
+	if _, err := parseMail([]byte("not a mail")); err == nil {
+		t.Error("parseMail(invalid) succeeded, want error")
+	}
+}
+
+func TestStripQuoted(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "引用なし", body: "hello\nworld\n", want: "hello\nworld"},
+		{name: "引用行", body: "reply\n> quoted\n> more", want: "reply"},
+		{name: "英語の引用ヘッダー", body: "reply\n\nOn Mon, Jan 1, 2025 at 10:00 AM Alice <a@example.com> wrote:\n> hi", want: "reply"},
+		{name: "日本語の引用ヘッダー", body: "返信です\n2025年1月1日(水) 10:00 Alice <a@example.com>:\n> こんにちは", want: "返信です"},
+		{name: "Outlook", body: "ok\n-----Original Message-----\nFrom: Alice", want: "ok"},
+		{name: "署名", body: "body\n-- \nAlice\nExample Inc.", want: "body"},
+		{name: "行末の空白", body: "a  \nb\t", want: "a\nb"},
+		{name: "引用のみ", body: "> only quote", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripQuoted(tt.body); got != tt.want {
+				t.Errorf("stripQuoted(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanSubject(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{subject: "Re: [LinkGate] hello", want: "hello"},
+		{subject: "RE: Fwd: re: topic", want: "topic"},
+		{subject: "返信： 会議", want: "会議"},
+		{subject: "plain", want: "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := cleanSubject(tt.subject); got != tt.want {
+			t.Errorf("cleanSubject(%q) = %q, want %q", tt.subject, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// outboundMail は送信するメールです
+type outboundMail struct {
+	FromName   string
+	To         string
+	Subject    string
+	Body       string
+	MessageID  string
+	InReplyTo  string
+	References []string
+}
+
+// bytes は送信するメールを RFC 5322 形式に変換します。本文は UTF-8 の quoted-printable で送信します。
+func (m outboundMail) bytes() []byte {
+	var b bytes.Buffer
+	from := mail.Address{Name: m.FromName, Address: fromAddress}
+	header := [][2]string{
+		{"From", from.String()},
+		{"To", m.To},
+		// 返信がメーリングリストに届くようにする
+		{"Reply-To", m.To},
+		{"Subject", mime.QEncoding.Encode("UTF-8", m.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + m.MessageID + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `text/plain; charset="UTF-8"`},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+		// LinkGate が送信したメールを受信時に除外するための目印
+		{"X-LinkGate", "1"},
+	}
+	if m.InReplyTo != "" {
+		header = append(header, [2]string{"In-Reply-To", "<" + m.InReplyTo + ">"})
+	}
+	if len(m.References) > 0 {
+		refs := make([]string, len(m.References))
+		for i, ref := range m.References {
+			refs[i] = "<" + ref + ">"
+		}
+		header = append(header, [2]string{"References", strings.Join(refs, " ")})
+	}
+
+	for _, h := range header {
+		fmt.Fprintf(&b, "%s: %s\r\n", h[0], h[1])
+	}
+	b.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(&b)
+	w.Write([]byte(strings.ReplaceAll(m.Body, "\n", "\r\n")))
+	w.Close()
+	return b.Bytes()
+}
+
+// SMTP サーバーの証明書を検証するルート証明書（nil の場合はシステムのもの。テストで差し替える）
+var smtpRootCAs *x509.CertPool
+
+// sendMail は SMTP サーバーにメールを送信します。
+// EMAIL_SMTP_TLS=true の場合は接続時から TLS を使用し（ポート465）、それ以外はサーバーが対応していれば STARTTLS を使用します。
+func sendMail(m outboundMail) error {
+	addr := net.JoinHostPort(smtpHost, smtpPort)
+	tlsConfig := &tls.Config{ServerName: smtpHost, RootCAs: smtpRootCAs}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if smtpTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, smtpHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !smtpTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		}
+	}
+	if smtpUser != "" {
+		if err := client.Auth(smtp.PlainAuth("", smtpUser, smtpPassword, smtpHost)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(fromAddress); err != nil {
+		return err
+	}
+	if err := client.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// newMessageID は送信するメールの Message-ID を作成します。
+func newMessageID(id string) string {
+	_, domain, ok := strings.Cut(fromAddress, "@")
+	if !ok {
+		domain = "linkgate.local"
+	}
+	return id + "@" + domain
+}
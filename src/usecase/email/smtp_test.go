@@ -0,0 +1,356 @@
+package email
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+)
+
+// テスト用のブリッジ設定を読み込ませる
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "email")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "bridges.json")
+	config := `[
+		{"id": "general", "channels": [
+			{"platform": "Email", "channelId": "list@example.com"},
+			{"platform": "Discord", "channelId": "d-general"}
+		]}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv("BRIDGE_CONFIG_PATH", path)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// smtpDelivery は SMTP のスタンドインが受信したメールです
+type smtpDelivery struct {
+	// 送信中に TLS を使用したか
+	tls  bool
+	auth string
+	from string
+	rcpt []string
+	data string
+}
+
+// fakeSMTP は SMTP サーバーのスタンドインです
+type fakeSMTP struct {
+	listener net.Listener
+	// STARTTLS・接続時の TLS に使用する設定
+	tlsConfig *tls.Config
+	// 接続時から TLS を使用するか
+	implicitTLS bool
+
+	mu         sync.Mutex
+	deliveries []smtpDelivery
+}
+
+// newFakeSMTP は SMTP のスタンドインを起動し、送信先の設定を差し替えます。
+func newFakeSMTP(t *testing.T, implicitTLS bool) *fakeSMTP {
+	t.Helper()
+
+	// httptest の証明書（127.0.0.1 用）を使用する
+	certServer := httptest.NewTLSServer(nil)
+	certServer.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(certServer.Certificate())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{listener: listener, tlsConfig: certServer.TLS, implicitTLS: implicitTLS}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	prev := []string{smtpHost, smtpPort, smtpUser, smtpPassword, fromAddress}
+	prevTLS, prevRoots := smtpTLS, smtpRootCAs
+	smtpHost, smtpPort, smtpUser, smtpPassword, fromAddress = host, port, "linkgate", "smtp-pass", "linkgate@example.com"
+	smtpTLS, smtpRootCAs = implicitTLS, roots
+	t.Cleanup(func() {
+		smtpHost, smtpPort, smtpUser, smtpPassword, fromAddress = prev[0], prev[1], prev[2], prev[3], prev[4]
+		smtpTLS, smtpRootCAs = prevTLS, prevRoots
+	})
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var d smtpDelivery
+	if s.implicitTLS {
+		conn = tls.Server(conn, s.tlsConfig)
+		d.tls = true
+	}
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 fake ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"250-fake"}
+			if !d.tls {
+				lines = append(lines, "250-STARTTLS")
+			}
+			lines = append(lines, "250 AUTH PLAIN")
+			reply(strings.Join(lines, "\r\n"))
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, d.tls = tlsConn, true
+			r = bufio.NewReader(conn)
+		case "AUTH":
+			d.auth = arg
+			reply("235 ok")
+		case "MAIL":
+			d.from = arg
+			reply("250 ok")
+		case "RCPT":
+			d.rcpt = append(d.rcpt, arg)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			d.data = data.String()
+			s.mu.Lock()
+			s.deliveries = append(s.deliveries, d)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// 受信したメールを返す
+func (s *fakeSMTP) received(t *testing.T) []smtpDelivery {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpDelivery(nil), s.deliveries...)
+}
+
+// 受信したメールのヘッダーと本文（quoted-printable をデコードしたもの）を返す
+func parseDelivered(t *testing.T, d smtpDelivery) (mail.Header, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(d.data))
+	if err != nil {
+		t.Fatalf("メールの解析に失敗しました: %v", err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatalf("本文のデコードに失敗しました: %v", err)
+	}
+	return msg.Header, strings.ReplaceAll(string(body), "\r\n", "\n")
+}
+
+func decodeSubject(t *testing.T, h mail.Header) string {
+	t.Helper()
+	subject, err := new(mime.WordDecoder).DecodeHeader(h.Get("Subject"))
+	if err != nil {
+		t.Fatalf("件名のデコードに失敗しました: %v", err)
+	}
+	return subject
+}
+
+func TestCreateEmailMessageSTARTTLS(t *testing.T) {
+	server := newFakeSMTP(t, false)
+	prevDigest := digestInterval
+	digestInterval = 0
+	t.Cleanup(func() { digestInterval = prevDigest })
+
+	msg := model.Message{
+		BridgeID:  "general",
+		ChannelID: "d-general",
+		User:      model.User{Platform: model.PlatformDiscord, Name: "Carol"},
+		Content: model.Content{
+			Text:        "明日の定例会\n10時からです",
+			Attachments: []model.Attachment{{Type: "image", URL: "https://example.com/a.png"}},
+		},
+	}
+	CreateEmailMessage(msg)
+
+	deliveries := server.received(t)
+	if len(deliveries) != 1 {
+		t.Fatalf("received %d mails, want 1", len(deliveries))
+	}
+	d := deliveries[0]
+	if !d.tls {
+		t.Error("STARTTLS を使用せずに送信しました")
+	}
+	if d.auth == "" {
+		t.Error("AUTH を使用せずに送信しました")
+	}
+	if d.from != "FROM:<linkgate@example.com>" || len(d.rcpt) != 1 || d.rcpt[0] != "TO:<list@example.com>" {
+		t.Errorf("envelope = %s %v, want FROM:<linkgate@example.com> [TO:<list@example.com>]", d.from, d.rcpt)
+	}
+
+	h, body := parseDelivered(t, d)
+	from, err := mail.ParseAddress(h.Get("From"))
+	if err != nil || from.Name != "Carol (Discord)" || from.Address != "linkgate@example.com" {
+		t.Errorf("From = %q, want Carol (Discord) <linkgate@example.com>", h.Get("From"))
+	}
+	wantHeaders := map[string]string{
+		"To":          "list@example.com",
+		"Reply-To":    "list@example.com",
+		"X-LinkGate":  "1",
+		"In-Reply-To": "",
+		"References":  "",
+	}
+	for key, want := range wantHeaders {
+		if got := h.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if got := decodeSubject(t, h); got != "[LinkGate] 明日の定例会" {
+		t.Errorf("Subject = %q, want %q", got, "[LinkGate] 明日の定例会")
+	}
+	if id := h.Get("Message-ID"); !strings.HasPrefix(id, "<"+msg.ID.Hex()+".") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+	wantBody := "Carol (Discord):\n\n明日の定例会\n10時からです\n\n📎 image: https://example.com/a.png\n\n-- \nこのメールに返信すると、チャットに投稿されます。\n"
+	if body != wantBody {
+		t.Errorf("body = %q, want %q", body, wantBody)
+	}
+}
+
+func TestSendMailImplicitTLSThreading(t *testing.T) {
+	server := newFakeSMTP(t, true)
+
+	err := sendMail(outboundMail{
+		FromName:   "Alice (Slack)",
+		To:         "list@example.com",
+		Subject:    "Re: [LinkGate] 議題",
+		Body:       "賛成です",
+		MessageID:  "reply.1@example.com",
+		InReplyTo:  "root.1@example.com",
+		References: []string{"root.1@example.com", "reply.0@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("sendMail() error = %v", err)
+	}
+
+	deliveries := server.received(t)
+	if len(deliveries) != 1 || !deliveries[0].tls {
+		t.Fatalf("deliveries = %+v, want one over TLS", deliveries)
+	}
+	h, body := parseDelivered(t, deliveries[0])
+	wantHeaders := map[string]string{
+		"Message-ID":  "<reply.1@example.com>",
+		"In-Reply-To": "<root.1@example.com>",
+		"References":  "<root.1@example.com> <reply.0@example.com>",
+		"X-LinkGate":  "1",
+	}
+	for key, want := range wantHeaders {
+		if got := h.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if got := decodeSubject(t, h); got != "Re: [LinkGate] 議題" {
+		t.Errorf("Subject = %q", got)
+	}
+	// 本文の末尾の改行は DATA の終端のために付与される
+	if body != "賛成です\n" {
+		t.Errorf("body = %q, want %q", body, "賛成です\n")
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	server := newFakeSMTP(t, false)
+
+	at := time.Date(2025, 1, 2, 9, 30, 0, 0, time.Local)
+	entries := []digestEntry{
+		{To: "list@example.com", Message: model.Message{
+			User: model.User{Platform: model.PlatformDiscord, Name: "Carol"}, Content: model.Content{Text: "おはよう"}, CreatedAt: at,
+		}},
+		{To: "list@example.com", Message: model.Message{
+			User:      model.User{Platform: model.PlatformSlack, Name: "Dave"},
+			Content:   model.Content{Attachments: []model.Attachment{{Type: "file", URL: "https://example.com/doc.pdf"}}},
+			CreatedAt: at.Add(time.Minute),
+		}},
+	}
+	if err := sendDigest("list@example.com", entries); err != nil {
+		t.Fatalf("sendDigest() error = %v", err)
+	}
+
+	deliveries := server.received(t)
+	if len(deliveries) != 1 {
+		t.Fatalf("received %d mails, want 1", len(deliveries))
+	}
+	h, body := parseDelivered(t, deliveries[0])
+
+	from, err := mail.ParseAddress(h.Get("From"))
+	if err != nil || from.Name != "LinkGate" {
+		t.Errorf("From = %q, want LinkGate <linkgate@example.com>", h.Get("From"))
+	}
+	if h.Get("X-LinkGate") != "1" || h.Get("To") != "list@example.com" {
+		t.Errorf("X-LinkGate = %q, To = %q", h.Get("X-LinkGate"), h.Get("To"))
+	}
+	if got := decodeSubject(t, h); !strings.HasPrefix(got, "[LinkGate] ダイジェスト（2件） ") {
+		t.Errorf("Subject = %q, want digest of 2 messages", got)
+	}
+	if id := h.Get("Message-ID"); !strings.HasPrefix(id, "<digest.") {
+		t.Errorf("Message-ID = %q, want digest id", id)
+	}
+	wantBody := "[01/02 09:30] Carol (Discord):\nおはよう\n\n" +
+		"[01/02 09:31] Dave (Slack):\n📎 file: https://example.com/doc.pdf\n\n" +
+		"-- \nこのメールに返信すると、チャットに投稿されます。\n"
+	if body != wantBody {
+		t.Errorf("body = %q, want %q", body, wantBody)
+	}
+}
@@ -0,0 +1,359 @@
+package irc
+
+// このパッケージは IRC サーバーにクライアントとして接続し、チャンネルのメッセージを中継します。
+// ブリッジに登録されたチャンネルの PRIVMSG をDBに保存し、他のプラットフォームのメッセージは
+// 「<名前@プラットフォーム> 本文」の形式で投稿します。
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 無通信と判断してPINGを送るまでの時間
+const pingInterval = 2 * time.Minute
+
+// サーバーからの応答がなく切断とみなすまでの時間
+const readTimeout = 5 * time.Minute
+
+// 1行に含める本文の最小バイト数（接頭辞が長い場合も本文にはこれだけ残す）
+const minTextLength = 100
+
+var (
+	// 接続先（例: irc.libera.chat:6697）
+	server = os.Getenv("IRC_SERVER")
+	// TLS で接続するか（IRC_TLS=false で平文接続）
+	useTLS = os.Getenv("IRC_TLS") != "false"
+	// ニックネーム
+	nickname = defaultString(os.Getenv("IRC_NICK"), "linkgate")
+	// ユーザー名と実名
+	username = defaultString(os.Getenv("IRC_USER"), "linkgate")
+	realname = defaultString(os.Getenv("IRC_REALNAME"), "LinkGate bridge")
+	// サーバーパスワード（PASS）
+	serverPassword = os.Getenv("IRC_PASSWORD")
+	// SASL PLAIN の認証情報
+	saslUser     = os.Getenv("IRC_SASL_USER")
+	saslPassword = os.Getenv("IRC_SASL_PASSWORD")
+	// NickServ の IDENTIFY に使うパスワード
+	nickServPassword = os.Getenv("IRC_NICKSERV_PASSWORD")
+)
+
+var mongoCollection *mongo.Collection
+
+// 現在の接続
+var (
+	conn        net.Conn
+	currentNick string
+	connMu      sync.Mutex
+)
+
+// InitializeIRCBot は IRC サーバーに接続し、切断された場合は再接続を繰り返します。
+func InitializeIRCBot(collection *mongo.Collection) {
+	if server == "" {
+		log.Println("IRC_SERVER が設定されていないため IRC 連携は無効です")
+		return
+	}
+	mongoCollection = collection
+
+	go runSender()
+
+	backoff := time.Second
+	for {
+		started := time.Now()
+		if err := run(); err != nil {
+			log.Printf("⚠️ IRC の接続が切断されました: %v", err)
+		}
+
+		// しばらく接続できていた場合は待ち時間をリセットする
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("IRC に %s 後に再接続します", backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// run はサーバーに接続して登録し、切断されるまで受信を続けます。
+func run() error {
+	c, err := dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	connMu.Lock()
+	conn = c
+	currentNick = nickname
+	connMu.Unlock()
+	defer func() {
+		connMu.Lock()
+		conn = nil
+		connMu.Unlock()
+	}()
+
+	// 登録（SASL を使う場合は CAP ネゴシエーションから開始する）
+	if saslUser != "" {
+		writeNow("CAP REQ :sasl")
+	}
+	if serverPassword != "" {
+		writeNow("PASS " + serverPassword)
+	}
+	writeNow("NICK " + nickname)
+	writeNow(fmt.Sprintf("USER %s 0 * :%s", username, realname))
+
+	// 無通信時は PING を送り、応答がなければ読み込みのタイムアウトで切断を検知する
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				writeNow("PING :linkgate")
+			}
+		}
+	}()
+
+	reader := bufio.NewReaderSize(c, maxLineLength)
+	for {
+		c.SetReadDeadline(time.Now().Add(readTimeout))
+		raw, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		handleLine(parseLine(raw))
+	}
+}
+
+func dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	if !useTLS {
+		return dialer.Dial("tcp", server)
+	}
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, err
+	}
+	return tls.DialWithDialer(dialer, "tcp", server, &tls.Config{ServerName: host})
+}
+
+// handleLine はサーバーから受信した1行を処理します。
+func handleLine(l line) {
+	switch l.Command {
+	case "PING":
+		writeNow("PONG :" + l.param(0))
+
+	case "CAP":
+		// CAP * ACK :sasl / CAP * NAK :sasl
+		switch l.param(1) {
+		case "ACK":
+			writeNow("AUTHENTICATE PLAIN")
+		case "NAK":
+			log.Println("IRC サーバーが SASL に対応していません")
+			writeNow("CAP END")
+		}
+
+	case "AUTHENTICATE":
+		if l.param(0) == "+" {
+			token := base64.StdEncoding.EncodeToString([]byte(saslUser + "\x00" + saslUser + "\x00" + saslPassword))
+			writeNow("AUTHENTICATE " + token)
+		}
+
+	case "903":
+		// SASL 認証成功
+		writeNow("CAP END")
+
+	case "902", "904", "905", "906":
+		log.Printf("IRC の SASL 認証に失敗しました: %s", l.param(len(l.Params)-1))
+		writeNow("CAP END")
+
+	case "001":
+		// 登録完了
+		connMu.Lock()
+		currentNick = l.param(0)
+		connMu.Unlock()
+		log.Printf("🔍 IRC bot connected (%s as %s)", server, l.param(0))
+
+		if nickServPassword != "" {
+			writeNow("PRIVMSG NickServ :IDENTIFY " + nickServPassword)
+		}
+		for _, channel := range channels() {
+			writeNow("JOIN " + channel)
+		}
+
+	case "433":
+		// ニックネームが使用中の場合は末尾に「_」を付けて再試行する
+		nick := l.param(1) + "_"
+		connMu.Lock()
+		currentNick = nick
+		connMu.Unlock()
+		writeNow("NICK " + nick)
+
+	case "NICK":
+		connMu.Lock()
+		if l.Nick() == currentNick {
+			currentNick = l.param(0)
+		}
+		connMu.Unlock()
+
+	case "PRIVMSG":
+		handlePrivmsg(l)
+	}
+}
+
+func handlePrivmsg(l line) {
+	target := l.param(0)
+	if !strings.HasPrefix(target, "#") && !strings.HasPrefix(target, "&") {
+		return
+	}
+	// 自分自身のメッセージは転送しない（ループ防止）
+	if strings.EqualFold(l.Nick(), nick()) {
+		return
+	}
+
+	channelID := normalizeChannel(target)
+	b, ok := bridge.Find(model.PlatformIRC, channelID)
+	if !ok {
+		return
+	}
+
+	text := l.param(1)
+	// CTCP ACTION（/me）は「* 本文」として扱い、それ以外の CTCP は無視する
+	if strings.HasPrefix(text, "\x01") {
+		body, ok := strings.CutPrefix(strings.Trim(text, "\x01"), "ACTION ")
+		if !ok {
+			return
+		}
+		text = "* " + body
+	}
+
+	SaveIRCMessageToMongoDB(l, channelID, stripFormatting(text), b.ID)
+}
+
+// SaveIRCMessageToMongoDB は IRC のメッセージを model.Message として保存します。
+func SaveIRCMessageToMongoDB(l line, channelID, text, bridgeID string) {
+	if mongoCollection == nil {
+		log.Println("MongoDB collection is not initialized")
+		return
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = l.Nick()
+	message.User.Platform = model.PlatformIRC
+	message.User.Name = l.Nick()
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = text
+	message.BridgeID = bridgeID
+	message.ChannelID = channelID
+	// IRCv3 の message-tags に対応したサーバーではメッセージIDを記録する
+	message.ExternalID = l.Tags["msgid"]
+	message.CreatedAt = time.Now()
+
+	// MongoDB にドキュメントを挿入
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := mongoCollection.InsertOne(ctx, message); err != nil {
+		log.Printf("IRCメッセージのデータ登録に失敗しました: %v", err)
+		return
+	}
+
+	log.Printf("IRC message saved: %s from %s", message.Content.Text, message.User.Name)
+}
+
+// CreateIRCMessage はMongoDBに新規追加されたメッセージを、同じブリッジのIRCチャンネルへ転送します。
+func CreateIRCMessage(msg model.Message) {
+	if server == "" {
+		return
+	}
+
+	channels := bridge.Destinations(msg, model.PlatformIRC)
+	if len(channels) == 0 {
+		return
+	}
+
+	prefix := fmt.Sprintf("<%s@%s> ", ircSafe(msg.User.Name), msg.User.Platform)
+	// IRC にはスレッドがないため、スレッド内のメッセージにはタイトルを付与する
+	if link, ok := thread.ForMessage(msg); ok {
+		prefix = fmt.Sprintf("[🧵 %s] %s", ircSafe(thread.DisplayTitle(link)), prefix)
+	}
+
+	lines := []string{}
+	for _, text := range strings.Split(msg.Content.Text, "\n") {
+		if text = strings.TrimSpace(text); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	for _, attachment := range msg.Content.Attachments {
+		lines = append(lines, fmt.Sprintf("📎 %s: %s", attachment.Type, attachment.URL))
+	}
+
+	for _, ch := range channels {
+		// 名前やスレッドタイトルが長い場合は、本文を送れるよう接頭辞を切り詰める
+		payload := payloadLimit(nick(), username, ch.ChannelID)
+		chPrefix := truncateBytes(prefix, payload-minTextLength)
+		limit := payload - len(chPrefix)
+		for _, text := range lines {
+			for _, part := range splitMessage(ircSafe(text), limit) {
+				send(fmt.Sprintf("PRIVMSG %s :%s%s", ch.ChannelID, chPrefix, part))
+			}
+		}
+	}
+}
+
+// 参加するチャンネル（ブリッジに登録された IRC チャンネル）
+func channels() []string {
+	var list []string
+	for _, b := range bridge.All() {
+		for _, ch := range b.Channels {
+			if ch.Platform == model.PlatformIRC {
+				list = append(list, ch.ChannelID)
+			}
+		}
+	}
+	return list
+}
+
+// IRC のチャンネル名は大文字・小文字を区別しないため小文字で扱う
+func normalizeChannel(name string) string {
+	return strings.ToLower(name)
+}
+
+// 改行・NUL は IRC のコマンドを壊すため空白に置き換える
+func ircSafe(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ", "\x00", " ").Replace(s)
+}
+
+func nick() string {
+	connMu.Lock()
+	defer connMu.Unlock()
+	return currentNick
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,102 @@
+package irc
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeChannel(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "#linkgate", want: "#linkgate"},
+		{name: "#LinkGate", want: "#linkgate"},
+		{name: "&Local", want: "&local"},
+		{name: "#日本語", want: "#日本語"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeChannel(tt.name); got != tt.want {
+			t.Errorf("normalizeChannel(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// IRC サーバーのスタンドインに接続し、登録と PING への応答を確認する
+func TestRun(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	prevServer, prevTLS, prevSASL, prevPass := server, useTLS, saslUser, serverPassword
+	server, useTLS, saslUser, serverPassword = listener.Addr().String(), false, "", ""
+	defer func() { server, useTLS, saslUser, serverPassword = prevServer, prevTLS, prevSASL, prevPass }()
+
+	received := make(chan []string, 1)
+	go func() {
+		c, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.SetDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(c)
+
+		var lines []string
+		readLine := func() string {
+			raw, _ := reader.ReadString('\n')
+			raw = strings.TrimRight(raw, "\r\n")
+			lines = append(lines, raw)
+			return raw
+		}
+		// NICK / USER
+		readLine()
+		readLine()
+		// ニックネームが使用中の場合は「_」を付けて再試行する
+		c.Write([]byte(":irc.example.com 433 * " + nickname + " :Nickname is already in use\r\n"))
+		readLine()
+		c.Write([]byte(":irc.example.com 001 " + nickname + "_ :Welcome\r\n"))
+		c.Write([]byte("PING :check\r\n"))
+		readLine()
+		received <- lines
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- run() }()
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("IRC サーバーのスタンドインへの送信がタイムアウトしました")
+	}
+
+	want := []string{
+		"NICK " + nickname,
+		"USER " + username + " 0 * :" + realname,
+		"NICK " + nickname + "_",
+		"PONG :check",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("sent lines = %q, want %q", lines, want)
+	}
+	if got := nick(); got != nickname+"_" {
+		t.Errorf("nick() = %q, want %q", got, nickname+"_")
+	}
+
+	// サーバーが切断したら run はエラーを返す
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("run() returned nil after disconnect")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("切断後も run が終了しません")
+	}
+}
@@ -0,0 +1,157 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// IRC の1行の最大バイト数（CRLF を含む）
+const maxLineLength = 512
+
+// ホスト名の最大長（中継時にサーバーが付与するプレフィックスの見積もりに使用）
+const maxHostLength = 63
+
+// line は IRC のメッセージ1行です
+type line struct {
+	// IRCv3 のメッセージタグ
+	Tags map[string]string
+	// 送信元（nick!user@host またはサーバー名）
+	Prefix string
+	// コマンドまたは3桁の数値応答
+	Command string
+	// パラメータ（最後の要素は「:」以降のトレーリングパラメータ）
+	Params []string
+}
+
+// parseLine は受信した1行を解析します。
+func parseLine(raw string) line {
+	raw = strings.TrimRight(raw, "\r\n")
+	var l line
+
+	if strings.HasPrefix(raw, "@") {
+		var tags string
+		tags, raw, _ = strings.Cut(raw[1:], " ")
+		l.Tags = map[string]string{}
+		for _, tag := range strings.Split(tags, ";") {
+			key, value, _ := strings.Cut(tag, "=")
+			l.Tags[key] = value
+		}
+	}
+	if strings.HasPrefix(raw, ":") {
+		l.Prefix, raw, _ = strings.Cut(raw[1:], " ")
+	}
+
+	for raw != "" {
+		raw = strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(raw, ":") {
+			l.Params = append(l.Params, raw[1:])
+			break
+		}
+		var param string
+		param, raw, _ = strings.Cut(raw, " ")
+		if param == "" {
+			continue
+		}
+		if l.Command == "" {
+			l.Command = strings.ToUpper(param)
+		} else {
+			l.Params = append(l.Params, param)
+		}
+	}
+	return l
+}
+
+// Nick は送信元のニックネームを返します。
+func (l line) Nick() string {
+	nick, _, _ := strings.Cut(l.Prefix, "!")
+	return nick
+}
+
+// param は i 番目のパラメータを返します（存在しない場合は空文字）。
+func (l line) param(i int) string {
+	if i < len(l.Params) {
+		return l.Params[i]
+	}
+	return ""
+}
+
+// stripFormatting は太字・色などの IRC の書式制御文字を取り除きます。
+func stripFormatting(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case 0x02, 0x0f, 0x11, 0x16, 0x1d, 0x1e, 0x1f:
+			// 太字・リセット・等幅・反転・斜体・取り消し線・下線
+		case 0x03:
+			// 色指定（\x03 前景色[,背景色]）
+			i += skipDigits(s[i+1:], 2)
+			if i+2 < len(s) && s[i+1] == ',' && isDigit(s[i+2]) {
+				i++
+				i += skipDigits(s[i+1:], 2)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func skipDigits(s string, max int) int {
+	n := 0
+	for n < len(s) && n < max && isDigit(s[n]) {
+		n++
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// splitMessage は本文を IRC の1行に収まるバイト数ごとに分割します。
+// 分割は UTF-8 の文字境界で行い、可能な限り空白の位置で区切ります。
+// limit が1文字分より小さい場合も、1回の分割で少なくとも1文字は進めます。
+func splitMessage(text string, limit int) []string {
+	limit = max(limit, 1)
+
+	var parts []string
+	for len(text) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			// 先頭の1文字が limit より大きい場合はその1文字だけを送る
+			_, cut = utf8.DecodeRuneInString(text)
+		} else if i := strings.LastIndexByte(text[:cut], ' '); i > limit/2 {
+			cut = i
+		}
+		parts = append(parts, text[:cut])
+		text = strings.TrimLeft(text[cut:], " ")
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// truncateBytes は UTF-8 の文字境界で s を limit バイト以内に切り詰めます。
+func truncateBytes(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	cut := max(limit, 0)
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// payloadLimit は PRIVMSG で送信できる本文の最大バイト数を返します。
+// サーバーは中継時に「:nick!user@host 」を付与するため、その分も差し引きます。
+func payloadLimit(nick, user, target string) int {
+	prefix := 1 + len(nick) + 1 + len(user) + 1 + maxHostLength + 1
+	command := len("PRIVMSG ") + len(target) + len(" :")
+	return maxLineLength - len("\r\n") - prefix - command
+}
@@ -0,0 +1,125 @@
+package irc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{name: "上限以内", text: "hello", limit: 10, want: []string{"hello"}},
+		{name: "空文字", text: "", limit: 10, want: nil},
+		{name: "空白で区切る", text: "hello world foo", limit: 12, want: []string{"hello world", "foo"}},
+		{name: "空白がない", text: "abcdefghij", limit: 4, want: []string{"abcd", "efgh", "ij"}},
+		{name: "文字境界で区切る", text: "あいう", limit: 7, want: []string{"あい", "う"}},
+		{name: "上限が1文字より小さい", text: "あいう", limit: 2, want: []string{"あ", "い", "う"}},
+		{name: "上限が0", text: "ab", limit: 0, want: []string{"a", "b"}},
+		{name: "上限が負", text: "あa", limit: -5, want: []string{"あ", "a"}},
+		{name: "絵文字", text: "😀😀", limit: 3, want: []string{"😀", "😀"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.text, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+			}
+			for _, part := range got {
+				if !utf8.ValidString(part) {
+					t.Errorf("part %q is not valid UTF-8", part)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageLongText(t *testing.T) {
+	text := strings.Repeat("日本語のメッセージ ", 100)
+	for _, limit := range []int{1, 2, 3, 50, 400} {
+		parts := splitMessage(text, limit)
+		if got := strings.Join(parts, ""); strings.ReplaceAll(got, " ", "") != strings.ReplaceAll(text, " ", "") {
+			t.Errorf("limit %d: 分割後の本文が一致しません", limit)
+		}
+		for _, part := range parts {
+			if len(part) > max(limit, utf8.UTFMax) {
+				t.Errorf("limit %d: part %q exceeds limit", limit, part)
+			}
+		}
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	tests := []struct {
+		s     string
+		limit int
+		want  string
+	}{
+		{s: "hello", limit: 10, want: "hello"},
+		{s: "hello", limit: 3, want: "hel"},
+		{s: "あいう", limit: 4, want: "あ"},
+		{s: "あいう", limit: 2, want: ""},
+		{s: "hello", limit: -1, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := truncateBytes(tt.s, tt.limit); got != tt.want {
+			t.Errorf("truncateBytes(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want line
+	}{
+		{
+			raw:  "PING :irc.example.com\r\n",
+			want: line{Command: "PING", Params: []string{"irc.example.com"}},
+		},
+		{
+			raw:  ":alice!a@host PRIVMSG #Chan :hello world\r\n",
+			want: line{Prefix: "alice!a@host", Command: "PRIVMSG", Params: []string{"#Chan", "hello world"}},
+		},
+		{
+			raw:  "@msgid=abc;time=2024-01-01 :bob!b@host privmsg #chan :hi",
+			want: line{Tags: map[string]string{"msgid": "abc", "time": "2024-01-01"}, Prefix: "bob!b@host", Command: "PRIVMSG", Params: []string{"#chan", "hi"}},
+		},
+		{
+			raw:  ":server 433 * linkgate :Nickname is already in use",
+			want: line{Prefix: "server", Command: "433", Params: []string{"*", "linkgate", "Nickname is already in use"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := parseLine(tt.raw); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseLine(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestStripFormatting(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "plain", want: "plain"},
+		{s: "\x02bold\x02 text", want: "bold text"},
+		{s: "\x0304red\x03 and \x0312,01blue", want: "red and blue"},
+		{s: "\x031,2x", want: "x"},
+		{s: "\x1ditalic\x0f", want: "italic"},
+	}
+
+	for _, tt := range tests {
+		if got := stripFormatting(tt.s); got != tt.want {
+			t.Errorf("stripFormatting(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
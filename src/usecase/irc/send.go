@@ -0,0 +1,83 @@
+package irc
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 送信待ちにできる最大行数（超えた分は破棄する）
+const outboxSize = 500
+
+var (
+	// 連続して送信できる行数
+	floodBurst = envInt("IRC_FLOOD_BURST", 5)
+	// 連続送信後に1行送信するごとの待ち時間（ミリ秒）
+	floodInterval = time.Duration(envInt("IRC_FLOOD_INTERVAL_MS", 2000)) * time.Millisecond
+)
+
+// 転送メッセージの送信待ち行
+var outbox = make(chan string, outboxSize)
+
+// send は転送メッセージを送信待ちに追加します。
+// サーバーの Excess Flood による切断を避けるため、実際の送信は runSender が一定間隔で行います。
+func send(raw string) {
+	select {
+	case outbox <- raw:
+	default:
+		log.Println("IRC の送信待ちが上限に達したためメッセージを破棄しました")
+	}
+}
+
+// runSender は送信待ちの行をトークンバケット方式で間隔を空けて送信します。
+// floodBurst 行までは続けて送信し、それ以降は floodInterval ごとに1行ずつ送信します。
+func runSender() {
+	tokens := floodBurst
+	refilled := time.Now()
+
+	for raw := range outbox {
+		// 経過時間に応じてトークンを補充する
+		if n := int(time.Since(refilled) / floodInterval); n > 0 {
+			tokens = min(floodBurst, tokens+n)
+			refilled = refilled.Add(time.Duration(n) * floodInterval)
+		}
+		if tokens == floodBurst {
+			refilled = time.Now()
+		}
+		if tokens == 0 {
+			time.Sleep(time.Until(refilled.Add(floodInterval)))
+			refilled = refilled.Add(floodInterval)
+			tokens = 1
+		}
+		tokens--
+
+		// 切断中は再接続を待ってから送信する
+		for !writeNow(raw) {
+			time.Sleep(5 * time.Second)
+		}
+	}
+}
+
+// writeNow は1行をすぐに送信します。接続していない場合は false を返します。
+func writeNow(raw string) bool {
+	connMu.Lock()
+	defer connMu.Unlock()
+	if conn == nil {
+		return false
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	if _, err := conn.Write([]byte(raw + "\r\n")); err != nil {
+		log.Printf("IRC への送信に失敗しました: %v", err)
+		return false
+	}
+	return true
+}
+
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
@@ -0,0 +1,234 @@
+package line
+
+import (
+	"fmt"
+	"fuagfuga-2025-LinkGate/src/model"
+	"strings"
+
+	"github.com/line/line-bot-sdk-go/v7/linebot"
+)
+
+// LINE の altText に設定できる最大文字数
+const maxAltTextLength = 400
+
+// 1メッセージに表示する添付画像サムネイルの最大数
+const maxThumbnails = 3
+
+// CreateFlexMessage は転送メッセージを送信者のアイコン・名前・プラットフォームバッジ付きの
+// Flex Message に変換します。通知やFlex非対応端末向けに altText にはプレーンテキストを設定します。
+// LINE にはスレッドがないため、スレッド内のメッセージは threadTitle を先頭に表示します。
+func CreateFlexMessage(msg model.Message, threadTitle string) *linebot.FlexMessage {
+	body := createBody(msg.Content)
+	if threadTitle != "" {
+		body.Contents = append([]linebot.FlexComponent{
+			&linebot.TextComponent{
+				Type:  linebot.FlexComponentTypeText,
+				Text:  "🧵 " + threadTitle,
+				Size:  linebot.FlexTextSizeTypeXs,
+				Color: "#888888",
+				Wrap:  true,
+			},
+		}, body.Contents...)
+	}
+
+	bubble := &linebot.BubbleContainer{
+		Type:   linebot.FlexContainerTypeBubble,
+		Size:   linebot.FlexBubbleSizeTypeKilo,
+		Header: createHeader(msg.User),
+		Body:   body,
+	}
+
+	return linebot.NewFlexMessage(createAltText(msg, threadTitle), bubble)
+}
+
+// ヘッダー: アイコン + 送信者名 + プラットフォームバッジ
+func createHeader(user model.User) *linebot.BoxComponent {
+	contents := []linebot.FlexComponent{}
+
+	// LINE は https の画像しか表示できないため、それ以外のアイコンは省略する
+	if strings.HasPrefix(user.IconUrl, "https://") {
+		contents = append(contents, &linebot.BoxComponent{
+			Type:         linebot.FlexComponentTypeBox,
+			Layout:       linebot.FlexBoxLayoutTypeVertical,
+			Width:        "36px",
+			Height:       "36px",
+			CornerRadius: linebot.FlexComponentCornerRadiusTypeXxl,
+			Contents: []linebot.FlexComponent{
+				&linebot.ImageComponent{
+					Type:        linebot.FlexComponentTypeImage,
+					URL:         user.IconUrl,
+					Size:        linebot.FlexImageSizeTypeFull,
+					AspectRatio: linebot.FlexImageAspectRatioType1to1,
+					AspectMode:  linebot.FlexImageAspectModeTypeCover,
+				},
+			},
+		})
+	}
+
+	name := user.Name
+	if name == "" {
+		name = "unknown"
+	}
+
+	contents = append(contents, &linebot.BoxComponent{
+		Type:           linebot.FlexComponentTypeBox,
+		Layout:         linebot.FlexBoxLayoutTypeVertical,
+		JustifyContent: linebot.FlexComponentJustifyContentTypeCenter,
+		Contents: []linebot.FlexComponent{
+			&linebot.TextComponent{
+				Type:   linebot.FlexComponentTypeText,
+				Text:   name,
+				Weight: linebot.FlexTextWeightTypeBold,
+				Size:   linebot.FlexTextSizeTypeSm,
+			},
+			createPlatformBadge(user.Platform),
+		},
+	})
+
+	return &linebot.BoxComponent{
+		Type:       linebot.FlexComponentTypeBox,
+		Layout:     linebot.FlexBoxLayoutTypeHorizontal,
+		Spacing:    linebot.FlexComponentSpacingTypeMd,
+		PaddingAll: linebot.FlexComponentPaddingTypeMd,
+		Contents:   contents,
+	}
+}
+
+// プラットフォーム名をブランドカラーのバッジとして表示する
+func createPlatformBadge(platform model.Platform) *linebot.BoxComponent {
+	return &linebot.BoxComponent{
+		Type:            linebot.FlexComponentTypeBox,
+		Layout:          linebot.FlexBoxLayoutTypeVertical,
+		Width:           "64px",
+		CornerRadius:    linebot.FlexComponentCornerRadiusTypeMd,
+		BackgroundColor: platformColor(platform),
+		PaddingTop:      "2px",
+		PaddingBottom:   "2px",
+		Contents: []linebot.FlexComponent{
+			&linebot.TextComponent{
+				Type:   linebot.FlexComponentTypeText,
+				Text:   string(platform),
+				Size:   linebot.FlexTextSizeTypeXxs,
+				Color:  "#FFFFFF",
+				Align:  linebot.FlexComponentAlignTypeCenter,
+				Weight: linebot.FlexTextWeightTypeBold,
+			},
+		},
+	}
+}
+
+// ボディ: 本文 + 添付ファイル
+func createBody(content model.Content) *linebot.BoxComponent {
+	contents := []linebot.FlexComponent{}
+
+	// Flex の text は空文字を許容しないため、本文がある場合のみ追加する
+	if content.Text != "" {
+		contents = append(contents, &linebot.TextComponent{
+			Type: linebot.FlexComponentTypeText,
+			Text: content.Text,
+			Wrap: true,
+			Size: linebot.FlexTextSizeTypeSm,
+		})
+	}
+
+	thumbnails := []linebot.FlexComponent{}
+	for _, attachment := range content.Attachments {
+		if !strings.HasPrefix(attachment.URL, "https://") {
+			continue
+		}
+
+		// 画像はサムネイルとして並べ、それ以外はリンクとして表示する
+		if attachment.Type == "image" && len(thumbnails) < maxThumbnails {
+			thumbnails = append(thumbnails, &linebot.ImageComponent{
+				Type:        linebot.FlexComponentTypeImage,
+				URL:         attachment.URL,
+				Size:        linebot.FlexImageSizeTypeFull,
+				AspectRatio: linebot.FlexImageAspectRatioType1to1,
+				AspectMode:  linebot.FlexImageAspectModeTypeCover,
+				Action:      linebot.NewURIAction("画像を開く", attachment.URL),
+			})
+			continue
+		}
+
+		contents = append(contents, &linebot.TextComponent{
+			Type:       linebot.FlexComponentTypeText,
+			Text:       fmt.Sprintf("📎 %s", attachment.Type),
+			Size:       linebot.FlexTextSizeTypeXs,
+			Color:      "#1E88E5",
+			Decoration: linebot.FlexTextDecorationTypeUnderline,
+			Action:     linebot.NewURIAction("添付ファイルを開く", attachment.URL),
+		})
+	}
+
+	if len(thumbnails) > 0 {
+		contents = append(contents, &linebot.BoxComponent{
+			Type:     linebot.FlexComponentTypeBox,
+			Layout:   linebot.FlexBoxLayoutTypeHorizontal,
+			Spacing:  linebot.FlexComponentSpacingTypeXs,
+			Margin:   linebot.FlexComponentMarginTypeMd,
+			Contents: thumbnails,
+		})
+	}
+
+	// 本文も添付もない場合でも body が空にならないようにする
+	if len(contents) == 0 {
+		contents = append(contents, &linebot.TextComponent{
+			Type:  linebot.FlexComponentTypeText,
+			Text:  "(本文なし)",
+			Size:  linebot.FlexTextSizeTypeSm,
+			Color: "#999999",
+		})
+	}
+
+	return &linebot.BoxComponent{
+		Type:       linebot.FlexComponentTypeBox,
+		Layout:     linebot.FlexBoxLayoutTypeVertical,
+		Spacing:    linebot.FlexComponentSpacingTypeSm,
+		PaddingAll: linebot.FlexComponentPaddingTypeMd,
+		Contents:   contents,
+	}
+}
+
+// 通知・トーク一覧に表示される代替テキストを作成する
+func createAltText(msg model.Message, threadTitle string) string {
+	altText := fmt.Sprintf("from: %sさん\n\n%s\n\n(Platform: %s)", msg.User.Name, msg.Content.Text, msg.User.Platform)
+	if threadTitle != "" {
+		altText = fmt.Sprintf("[🧵 %s] %s", threadTitle, altText)
+	}
+
+	runes := []rune(altText)
+	if len(runes) > maxAltTextLength {
+		altText = string(runes[:maxAltTextLength-1]) + "…"
+	}
+	return altText
+}
+
+// プラットフォームごとのバッジカラー
+func platformColor(platform model.Platform) string {
+	switch platform {
+	case model.PlatformDiscord:
+		return "#5865F2" // Discordブランドカラー（紫）
+	case model.PlatformLINE:
+		return "#06C755" // LINEブランドカラー（グリーン）
+	case model.PlatformSlack:
+		return "#4A154B" // Slackブランドカラー（オーベルジーヌ）
+	case model.PlatformTelegram:
+		return "#26A5E4" // Telegramブランドカラー（ブルー）
+	case model.PlatformMatrix:
+		return "#0DBD8B" // Matrix（Element）カラー（グリーン）
+	case model.PlatformIRC:
+		return "#6B7280" // IRCはスレートグレー
+	case model.PlatformMattermost:
+		return "#1E325C" // Mattermostブランドカラー（ネイビー）
+	case model.PlatformEmail:
+		return "#EA4335" // メールはレッド
+	case model.PlatformWebhook:
+		return "#F59E0B" // Webhook連携はオレンジ
+	case model.PlatformAPI:
+		return "#6366F1" // Web APIはインディゴ
+	case model.PlatformWeb:
+		return "#0EA5E9" // Webチャットはスカイブルー
+	default:
+		return "#888888" // その他はグレー
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"github.com/line/line-bot-sdk-go/v7/linebot"
@@ -30,6 +33,11 @@ func createBot() error {
 }
 
 func CreateLINEMessage(msg model.Message) {
+	// LINEグループがメッセージの転送先（同じブリッジ、または転送先の指定）に含まれるときのみ送信する
+	if !slices.Contains(bridge.Destinations(msg, model.PlatformLINE), model.Channel{Platform: model.PlatformLINE, ChannelID: groupID}) {
+		return
+	}
+
 	err := createBot()
 
 	if err != nil {
@@ -37,13 +45,15 @@ func CreateLINEMessage(msg model.Message) {
 		return
 	}
 
-	// メッセージ送信者、内容、送信元プラットフォームを取得
-	userName := msg.User.Name
-	text := msg.Content.Text
-	platform := msg.User.Platform
+	// スレッド内のメッセージであればスレッドタイトルを付与する
+	threadTitle := ""
+	if link, ok := thread.ForMessage(msg); ok {
+		threadTitle = thread.DisplayTitle(link)
+	}
 
-	lineText := linebot.NewTextMessage(fmt.Sprintf("from: %sさん\n\n%s\n\n(Platform: %s)", userName, text, platform))
-	_, err = bot.PushMessage(groupID, lineText).Do()
+	// 送信者情報付きの Flex Message を作成
+	flexMessage := CreateFlexMessage(msg, threadTitle)
+	_, err = bot.PushMessage(groupID, flexMessage).Do()
 
 	if err != nil {
 		if apiErr, ok := err.(*linebot.APIError); ok {
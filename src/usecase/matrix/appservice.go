@@ -0,0 +1,98 @@
+package matrix
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes はホームサーバーから呼び出される Application Service API のエンドポイントを登録します。
+// 古いホームサーバー向けに /_matrix/app/v1 を付けないパスも登録します。
+func RegisterRoutes(r *gin.Engine) {
+	for _, prefix := range []string{"/_matrix/app/v1", ""} {
+		g := r.Group(prefix, authenticate)
+		g.PUT("/transactions/:txnId", HandleTransaction)
+		g.GET("/users/:userId", HandleUserQuery)
+		g.GET("/rooms/:roomAlias", HandleRoomQuery)
+	}
+	r.POST("/_matrix/app/v1/ping", authenticate, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+}
+
+// ホームサーバーからのリクエストを hs_token で認証する
+func authenticate(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		// 古いホームサーバーはクエリパラメータでトークンを送信する
+		token = c.Query("access_token")
+	}
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"errcode": "M_UNAUTHORIZED", "error": "トークンがありません"})
+		return
+	}
+	if !Enabled() || subtle.ConstantTimeCompare([]byte(token), []byte(hsToken)) != 1 {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"errcode": "M_FORBIDDEN", "error": "トークンが正しくありません"})
+		return
+	}
+	c.Next()
+}
+
+// HandleTransaction はホームサーバーから送信されたイベントをまとめて処理します。
+// 同じトランザクションが再送されることがありますが、メッセージはイベントIDで upsert するため二重登録されません。
+func HandleTransaction(c *gin.Context) {
+	var txn struct {
+		Events []Event `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&txn); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errcode": "M_NOT_JSON", "error": err.Error()})
+		return
+	}
+
+	for _, event := range txn.Events {
+		HandleEvent(event)
+	}
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// HandleUserQuery は仮想ユーザーの名前空間のユーザーが存在するかの問い合わせに応答します。
+// 仮想ユーザーは転送時に登録するため、ここでは名前空間に含まれるかのみを返します。
+func HandleUserQuery(c *gin.Context) {
+	if isVirtualUser(c.Param("userId")) {
+		c.JSON(http.StatusOK, gin.H{})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"errcode": "M_NOT_FOUND", "error": "ユーザーが見つかりません"})
+}
+
+// HandleRoomQuery はルームエイリアスの問い合わせに応答します（エイリアスは管理しないため常に 404）。
+func HandleRoomQuery(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"errcode": "M_NOT_FOUND", "error": "ルームが見つかりません"})
+}
+
+// Registration はホームサーバーに登録する Application Service の設定を YAML 形式で返します。
+// url にはホームサーバーから LinkGate へ接続できるURLを指定します。
+func Registration(id, url string) string {
+	userRegex := "@" + regexp.QuoteMeta(userPrefix) + ".*:" + regexp.QuoteMeta(serverName)
+	botRegex := "@" + regexp.QuoteMeta(botLocalpart) + ":" + regexp.QuoteMeta(serverName)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %s\n", strconv.Quote(id))
+	fmt.Fprintf(&b, "url: %s\n", strconv.Quote(url))
+	fmt.Fprintf(&b, "as_token: %s\n", strconv.Quote(asToken))
+	fmt.Fprintf(&b, "hs_token: %s\n", strconv.Quote(hsToken))
+	fmt.Fprintf(&b, "sender_localpart: %s\n", strconv.Quote(botLocalpart))
+	b.WriteString("rate_limited: false\n")
+	b.WriteString("namespaces:\n")
+	b.WriteString("  users:\n")
+	fmt.Fprintf(&b, "    - exclusive: true\n      regex: %s\n", strconv.Quote(userRegex))
+	fmt.Fprintf(&b, "    - exclusive: true\n      regex: %s\n", strconv.Quote(botRegex))
+	b.WriteString("  aliases: []\n")
+	b.WriteString("  rooms: []\n")
+	return b.String()
+}
@@ -0,0 +1,306 @@
+package matrix
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"github.com/gin-gonic/gin"
+)
+
+// テスト用のブリッジ設定を読み込ませる
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "matrix")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "bridges.json")
+	config := `[
+		{"id": "general", "channels": [
+			{"platform": "Matrix", "channelId": "!room:example.com"},
+			{"platform": "Matrix", "channelId": "!private:example.com"},
+			{"platform": "Discord", "channelId": "d-general"}
+		]}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv("BRIDGE_CONFIG_PATH", path)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// Client-Server API の呼び出し
+type apiCall struct {
+	method string
+	path   string
+	// user_id クエリ（仮想ユーザーとしての操作）
+	asUser string
+	body   map[string]interface{}
+}
+
+// Client-Server API のスタブ
+type clientAPIStub struct {
+	mu    sync.Mutex
+	calls []apiCall
+	// 招待されるまで参加できないルーム
+	inviteOnly map[string]bool
+	invited    map[string]bool
+}
+
+// method と path の接頭辞が一致する呼び出しを返す
+func (s *clientAPIStub) called(method, prefix string) []apiCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []apiCall
+	for _, c := range s.calls {
+		if c.method == method && strings.HasPrefix(c.path, prefix) {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Client-Server API のスタブを起動し、Matrix 連携の設定を差し替える
+func newClientAPIStub(t *testing.T) *clientAPIStub {
+	t.Helper()
+	stub := &clientAPIStub{inviteOnly: map[string]bool{}, invited: map[string]bool{}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer as-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"errcode": "M_UNKNOWN_TOKEN"})
+			return
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		call := apiCall{method: r.Method, path: r.URL.Path, asUser: r.URL.Query().Get("user_id"), body: body}
+
+		stub.mu.Lock()
+		stub.calls = append(stub.calls, call)
+		sent := len(stub.calls)
+		stub.mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/profile/"):
+			json.NewEncoder(w).Encode(map[string]string{"displayname": "Alice", "avatar_url": "mxc://example.com/alice"})
+		case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/_matrix/client/v3/join/"):
+			room := strings.TrimPrefix(r.URL.Path, "/_matrix/client/v3/join/")
+			stub.mu.Lock()
+			forbidden := stub.inviteOnly[room] && !stub.invited[room]
+			stub.mu.Unlock()
+			if forbidden {
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{"errcode": "M_FORBIDDEN"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"room_id": room})
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/invite"):
+			room := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/_matrix/client/v3/rooms/"), "/invite")
+			stub.mu.Lock()
+			stub.invited[room] = true
+			stub.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]string{})
+		case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/send/m.room.message/"):
+			json.NewEncoder(w).Encode(map[string]string{"event_id": "$sent-" + strconv.Itoa(sent)})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	prev := []string{homeserverURL, publicHomeserverURL, serverName, asToken, hsToken}
+	homeserverURL, publicHomeserverURL, serverName, asToken, hsToken = server.URL, "https://matrix.example.com", "example.com", "as-token", "hs-token"
+	t.Cleanup(func() {
+		homeserverURL, publicHomeserverURL, serverName, asToken, hsToken = prev[0], prev[1], prev[2], prev[3], prev[4]
+	})
+	return stub
+}
+
+// 受信したメッセージを保存せずに記録する
+func captureSaved(t *testing.T) *[]model.Message {
+	t.Helper()
+	saved := &[]model.Message{}
+	prev := saveMessage
+	saveMessage = func(message model.Message) { *saved = append(*saved, message) }
+	t.Cleanup(func() { saveMessage = prev })
+	return saved
+}
+
+func TestHandleTransactionAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newClientAPIStub(t)
+	saved := captureSaved(t)
+
+	r := gin.New()
+	RegisterRoutes(r)
+
+	body := `{"events": [{"type": "m.room.message", "event_id": "$1", "room_id": "!room:example.com",
+		"sender": "@alice:example.com", "origin_server_ts": 1735689600000, "content": {"msgtype": "m.text", "body": "hello"}}]}`
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		wantStatus int
+		wantSaved  int
+	}{
+		{name: "トークンなし", path: "/_matrix/app/v1/transactions/1", wantStatus: http.StatusUnauthorized},
+		{name: "トークンが異なる", path: "/_matrix/app/v1/transactions/2", header: "Bearer as-token", wantStatus: http.StatusForbidden},
+		{name: "クエリのトークンが異なる", path: "/transactions/3?access_token=wrong", wantStatus: http.StatusForbidden},
+		{name: "hs_token", path: "/_matrix/app/v1/transactions/4", header: "Bearer hs-token", wantStatus: http.StatusOK, wantSaved: 1},
+		{name: "古いホームサーバーのクエリのトークン", path: "/transactions/5?access_token=hs-token", wantStatus: http.StatusOK, wantSaved: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*saved = nil
+			req := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body: %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if len(*saved) != tt.wantSaved {
+				t.Errorf("saved %d messages, want %d", len(*saved), tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestHandleTransactionEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newClientAPIStub(t)
+
+	r := gin.New()
+	RegisterRoutes(r)
+
+	tests := []struct {
+		name  string
+		event string
+		// 保存されるメッセージ（nil の場合は保存されない）
+		want *model.Message
+	}{
+		{
+			name: "テキスト",
+			event: `{"type": "m.room.message", "event_id": "$text", "room_id": "!room:example.com", "sender": "@alice:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.text", "body": "hello"}}`,
+			want: &model.Message{
+				BridgeID: "general", ChannelID: "!room:example.com", ExternalID: "$text",
+				User:    model.User{UserID: "@alice:example.com", Platform: model.PlatformMatrix, Name: "Alice", IconUrl: "https://matrix.example.com/_matrix/media/v3/download/example.com/alice"},
+				Content: model.Content{Text: "hello"},
+			},
+		},
+		{
+			name: "返信の引用を取り除く",
+			event: `{"type": "m.room.message", "event_id": "$reply", "room_id": "!room:example.com", "sender": "@alice:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.text", "body": "> <@bob:example.com> 元のメッセージ\n\n了解",
+				"m.relates_to": {"m.in_reply_to": {"event_id": "$text"}}}}`,
+			want: &model.Message{
+				BridgeID: "general", ChannelID: "!room:example.com", ExternalID: "$reply",
+				User:    model.User{UserID: "@alice:example.com", Platform: model.PlatformMatrix, Name: "Alice", IconUrl: "https://matrix.example.com/_matrix/media/v3/download/example.com/alice"},
+				Content: model.Content{Text: "了解"},
+			},
+		},
+		{
+			name: "画像",
+			event: `{"type": "m.room.message", "event_id": "$image", "room_id": "!room:example.com", "sender": "@alice:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.image", "body": "cat.png", "url": "mxc://example.com/cat"}}`,
+			want: &model.Message{
+				BridgeID: "general", ChannelID: "!room:example.com", ExternalID: "$image",
+				User: model.User{UserID: "@alice:example.com", Platform: model.PlatformMatrix, Name: "Alice", IconUrl: "https://matrix.example.com/_matrix/media/v3/download/example.com/alice"},
+				Content: model.Content{Attachments: []model.Attachment{
+					{Type: "image", URL: "https://matrix.example.com/_matrix/media/v3/download/example.com/cat"},
+				}},
+			},
+		},
+		{
+			name: "編集は保存しない",
+			event: `{"type": "m.room.message", "event_id": "$edit", "room_id": "!room:example.com", "sender": "@alice:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.text", "body": "* hello!",
+				"m.new_content": {"msgtype": "m.text", "body": "hello!"}, "m.relates_to": {"rel_type": "m.replace", "event_id": "$text"}}}`,
+		},
+		{
+			name: "仮想ユーザーのメッセージは保存しない",
+			event: `{"type": "m.room.message", "event_id": "$puppet", "room_id": "!room:example.com", "sender": "@linkgate_discord_1:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.text", "body": "転送"}}`,
+		},
+		{
+			name: "Bot のメッセージは保存しない",
+			event: `{"type": "m.room.message", "event_id": "$bot", "room_id": "!room:example.com", "sender": "@linkgate:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.notice", "body": "🧵 スレッド"}}`,
+		},
+		{
+			name: "ブリッジに含まれないルームは保存しない",
+			event: `{"type": "m.room.message", "event_id": "$other", "room_id": "!unknown:example.com", "sender": "@alice:example.com",
+				"origin_server_ts": 1735689600000, "content": {"msgtype": "m.text", "body": "hello"}}`,
+		},
+		{
+			name: "メッセージ以外のイベントは保存しない",
+			event: `{"type": "m.reaction", "event_id": "$reaction", "room_id": "!room:example.com", "sender": "@alice:example.com",
+				"origin_server_ts": 1735689600000, "content": {}}`,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := captureSaved(t)
+
+			body := `{"events": [` + tt.event + `]}`
+			req := httptest.NewRequest(http.MethodPut, "/_matrix/app/v1/transactions/"+strconv.Itoa(i), strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer hs-token")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200 (body: %s)", w.Code, w.Body.String())
+			}
+
+			if tt.want == nil {
+				if len(*saved) != 0 {
+					t.Fatalf("saved = %+v, want none", *saved)
+				}
+				return
+			}
+			if len(*saved) != 1 {
+				t.Fatalf("saved %d messages, want 1", len(*saved))
+			}
+			got := (*saved)[0]
+			if got.BridgeID != tt.want.BridgeID || got.ChannelID != tt.want.ChannelID || got.ExternalID != tt.want.ExternalID {
+				t.Errorf("bridge/channel/external = %s/%s/%s, want %s/%s/%s",
+					got.BridgeID, got.ChannelID, got.ExternalID, tt.want.BridgeID, tt.want.ChannelID, tt.want.ExternalID)
+			}
+			if got.User.UserID != tt.want.User.UserID || got.User.Platform != tt.want.User.Platform ||
+				got.User.Name != tt.want.User.Name || got.User.IconUrl != tt.want.User.IconUrl {
+				t.Errorf("user = %+v, want %+v", got.User, tt.want.User)
+			}
+			if got.Content.Text != tt.want.Content.Text {
+				t.Errorf("content.text = %q, want %q", got.Content.Text, tt.want.Content.Text)
+			}
+			if len(got.Content.Attachments) != len(tt.want.Content.Attachments) {
+				t.Fatalf("attachments = %+v, want %+v", got.Content.Attachments, tt.want.Content.Attachments)
+			}
+			for j := range got.Content.Attachments {
+				if got.Content.Attachments[j] != tt.want.Content.Attachments[j] {
+					t.Errorf("attachments[%d] = %+v, want %+v", j, got.Content.Attachments[j], tt.want.Content.Attachments[j])
+				}
+			}
+			if !got.CreatedAt.Equal(time.UnixMilli(1735689600000)) {
+				t.Errorf("createdAt = %v, want %v", got.CreatedAt, time.UnixMilli(1735689600000))
+			}
+		})
+	}
+}
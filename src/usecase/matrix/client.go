@@ -0,0 +1,118 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// APIError は Client-Server API から返されたエラーです
+type APIError struct {
+	StatusCode int
+	ErrCode    string `json:"errcode"`
+	Message    string `json:"error"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Matrix API エラー (status: %d): %s %s", e.StatusCode, e.ErrCode, e.Message)
+}
+
+// isErrCode は err が指定した errcode の APIError かを返します。
+func isErrCode(err error, code string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.ErrCode == code
+}
+
+// call は Application Service のトークンで Client-Server API を呼び出します。
+// asUser を指定した場合は、そのユーザー（仮想ユーザー）として操作します。
+func call(ctx context.Context, method, path, asUser string, params interface{}, out interface{}) error {
+	var body io.Reader
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint(path, asUser), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+asToken)
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return do(req, out)
+}
+
+// upload はメディアをアップロードし、mxc:// 形式のURIを返します。
+func upload(ctx context.Context, data io.Reader, contentType, filename string) (string, error) {
+	path := "/_matrix/media/v3/upload"
+	if filename != "" {
+		path += "?filename=" + url.QueryEscape(filename)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint(path, ""), data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+asToken)
+	req.Header.Set("Content-Type", contentType)
+
+	var result struct {
+		ContentURI string `json:"content_uri"`
+	}
+	if err := do(req, &result); err != nil {
+		return "", err
+	}
+	return result.ContentURI, nil
+}
+
+func do(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// ホームサーバーのURLを組み立てる
+func endpoint(path, asUser string) string {
+	u := strings.TrimRight(homeserverURL, "/") + path
+	if asUser != "" {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		u += sep + "user_id=" + url.QueryEscape(asUser)
+	}
+	return u
+}
+
+// MediaURL は mxc:// 形式のURIを他のプラットフォームから参照できる https のURLに変換します。
+func MediaURL(mxc string) string {
+	rest, ok := strings.CutPrefix(mxc, "mxc://")
+	if !ok {
+		return ""
+	}
+	return strings.TrimRight(publicHomeserverURL, "/") + "/_matrix/media/v3/download/" + rest
+}
@@ -0,0 +1,453 @@
+package matrix
+
+// このパッケージは Matrix の Application Service として動作し、ホームサーバーとの間でメッセージを中継します。
+// ホームサーバーから /_matrix/app/v1/transactions でイベントを受信してDBに保存し、
+// 他のプラットフォームのメッセージは送信者ごとの仮想ユーザー（@linkgate_discord_123:example.com など）として投稿します。
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/delivery"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// スレッドタイトルとして使用する親メッセージの最大文字数
+const maxThreadTitleLength = 40
+
+var (
+	// LinkGate から接続するホームサーバーのURL（例: http://synapse:8008）
+	homeserverURL = os.Getenv("MATRIX_HOMESERVER_URL")
+	// 他のプラットフォームからメディアを参照するための公開URL（未設定の場合は MATRIX_HOMESERVER_URL）
+	publicHomeserverURL = defaultString(os.Getenv("MATRIX_PUBLIC_HOMESERVER_URL"), homeserverURL)
+	// ホームサーバーのサーバー名（ユーザーIDの「:」以降）
+	serverName = os.Getenv("MATRIX_SERVER_NAME")
+	// LinkGate がホームサーバーへアクセスするためのトークン（登録ファイルの as_token）
+	asToken = os.Getenv("MATRIX_AS_TOKEN")
+	// ホームサーバーが LinkGate へアクセスするためのトークン（登録ファイルの hs_token）
+	hsToken = os.Getenv("MATRIX_HS_TOKEN")
+	// Bot ユーザーの localpart
+	botLocalpart = defaultString(os.Getenv("MATRIX_BOT_LOCALPART"), "linkgate")
+	// 仮想ユーザーの localpart の接頭辞
+	userPrefix = defaultString(os.Getenv("MATRIX_USER_PREFIX"), "linkgate_")
+)
+
+var mongoCollection *mongo.Collection
+
+// 受信したメッセージの保存先（テストで差し替える）
+var saveMessage = insertMessage
+
+// Event はホームサーバーから送信されるルームイベントです
+type Event struct {
+	Type           string          `json:"type"`
+	EventID        string          `json:"event_id"`
+	RoomID         string          `json:"room_id"`
+	Sender         string          `json:"sender"`
+	StateKey       *string         `json:"state_key,omitempty"`
+	OriginServerTS int64           `json:"origin_server_ts"`
+	Content        json.RawMessage `json:"content"`
+}
+
+// m.room.message の content
+type messageContent struct {
+	MsgType    string          `json:"msgtype"`
+	Body       string          `json:"body"`
+	URL        string          `json:"url,omitempty"`
+	NewContent *messageContent `json:"m.new_content,omitempty"`
+	RelatesTo  *relatesTo      `json:"m.relates_to,omitempty"`
+}
+
+type relatesTo struct {
+	RelType       string     `json:"rel_type,omitempty"`
+	EventID       string     `json:"event_id,omitempty"`
+	IsFallingBack bool       `json:"is_falling_back,omitempty"`
+	InReplyTo     *inReplyTo `json:"m.in_reply_to,omitempty"`
+}
+
+type inReplyTo struct {
+	EventID string `json:"event_id"`
+}
+
+// Enabled は Matrix 連携が設定されているかを返します。
+func Enabled() bool {
+	return homeserverURL != "" && serverName != "" && asToken != "" && hsToken != ""
+}
+
+// InitializeMatrixBridge は Matrix 連携を開始します。
+func InitializeMatrixBridge(collection *mongo.Collection) {
+	if !Enabled() {
+		log.Println("MATRIX_HOMESERVER_URL / MATRIX_SERVER_NAME / MATRIX_AS_TOKEN / MATRIX_HS_TOKEN が設定されていないため Matrix 連携は無効です")
+		return
+	}
+	mongoCollection = collection
+
+	// Bot ユーザーの表示名を設定する（ホームサーバーに接続できるかの確認も兼ねる）
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	path := "/_matrix/client/v3/profile/" + url.PathEscape(botUserID()) + "/displayname"
+	if err := call(ctx, http.MethodPut, path, "", map[string]string{"displayname": "LinkGate"}, nil); err != nil {
+		log.Printf("Matrix ホームサーバーへの接続に失敗しました: %v", err)
+		return
+	}
+	log.Printf("🔍 Matrix application service started (%s)", botUserID())
+}
+
+// HandleEvent はホームサーバーから受信したイベントを処理します。
+func HandleEvent(event Event) {
+	switch event.Type {
+	case "m.room.member":
+		handleMembership(event)
+	case "m.room.message":
+		handleMessage(event)
+	}
+}
+
+// Bot がルームに招待されたら参加する
+func handleMembership(event Event) {
+	if event.StateKey == nil || *event.StateKey != botUserID() {
+		return
+	}
+	var content struct {
+		Membership string `json:"membership"`
+	}
+	if err := json.Unmarshal(event.Content, &content); err != nil || content.Membership != "invite" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := call(ctx, http.MethodPost, "/_matrix/client/v3/join/"+url.PathEscape(event.RoomID), "", map[string]string{}, nil); err != nil {
+		log.Printf("Matrix ルームへの参加に失敗しました (room: %s): %v", event.RoomID, err)
+		return
+	}
+	log.Printf("Matrix ルームに参加しました (room: %s, invited by %s)", event.RoomID, event.Sender)
+}
+
+func handleMessage(event Event) {
+	// LinkGate 自身が投稿したメッセージは転送しない（ループ防止）
+	if event.Sender == botUserID() || isVirtualUser(event.Sender) {
+		return
+	}
+
+	b, ok := bridge.Find(model.PlatformMatrix, event.RoomID)
+	if !ok {
+		return
+	}
+
+	var content messageContent
+	if err := json.Unmarshal(event.Content, &content); err != nil {
+		log.Printf("Matrix メッセージの解析に失敗しました: %v", err)
+		return
+	}
+	// 編集（m.replace）は新しいメッセージとして転送しない
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.replace" {
+		return
+	}
+
+	SaveMatrixMessageToMongoDB(event, content, b.ID)
+}
+
+// SaveMatrixMessageToMongoDB は Matrix のメッセージを model.Message として保存します。
+func SaveMatrixMessageToMongoDB(event Event, content messageContent, bridgeID string) {
+	message, ok := newMessage(event, content, bridgeID)
+	if !ok {
+		return
+	}
+
+	// スレッド内のメッセージであれば対応関係を記録する
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.thread" {
+		root := content.RelatesTo.EventID
+		ref := model.ThreadRef{Platform: model.PlatformMatrix, ChannelID: event.RoomID, ThreadID: root}
+		if _, err := thread.Ensure(bridgeID, threadTitle(event.RoomID, root), ref); err != nil {
+			log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+		}
+		message.ThreadID = root
+	}
+
+	saveMessage(message)
+}
+
+// Matrix のメッセージイベントを model.Message に変換する（転送しない種類のメッセージの場合は false）
+func newMessage(event Event, content messageContent, bridgeID string) (model.Message, bool) {
+	var message model.Message
+
+	switch content.MsgType {
+	case "m.text", "m.notice":
+		message.Content.Text = stripReplyFallback(content.Body)
+	case "m.emote":
+		message.Content.Text = "* " + content.Body
+	case "m.image", "m.video", "m.audio", "m.file":
+		attachmentType := strings.TrimPrefix(content.MsgType, "m.")
+		message.Content.Attachments = []model.Attachment{{Type: attachmentType, URL: MediaURL(content.URL)}}
+	default:
+		return model.Message{}, false
+	}
+
+	name, iconURL := profile(event.Sender)
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = event.Sender
+	message.User.Platform = model.PlatformMatrix
+	message.User.Name = name
+	message.User.IconUrl = iconURL
+	message.Content.ID = primitive.NewObjectID()
+	message.BridgeID = bridgeID
+	message.ChannelID = event.RoomID
+	message.ExternalID = event.EventID
+	message.CreatedAt = time.UnixMilli(event.OriginServerTS)
+	return message, true
+}
+
+// メッセージを MongoDB に保存する
+func insertMessage(message model.Message) {
+	if mongoCollection == nil {
+		log.Println("MongoDB collection is not initialized")
+		return
+	}
+
+	// MongoDB にドキュメントを挿入（トランザクションの再送で二重登録しないよう upsert する）
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.M{"user.platform": model.PlatformMatrix, "externalId": message.ExternalID}
+	if _, err := mongoCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": message}, options.Update().SetUpsert(true)); err != nil {
+		log.Printf("Matrixメッセージのデータ登録に失敗しました: %v", err)
+		return
+	}
+
+	log.Printf("Matrix message saved: %s from %s", message.Content.Text, message.User.Name)
+}
+
+// CreateMatrixMessage はMongoDBに新規追加されたメッセージを、送信者の仮想ユーザーとして同じブリッジのMatrixルームへ転送します。
+func CreateMatrixMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	channels := bridge.Destinations(msg, model.PlatformMatrix)
+	if len(channels) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	link, inThread := thread.ForMessage(msg)
+
+	for _, ch := range channels {
+		userID, err := ensurePuppet(ctx, msg.User, ch.ChannelID)
+		if err != nil {
+			log.Printf("Matrix 仮想ユーザーの準備に失敗しました (room: %s): %v", ch.ChannelID, err)
+			continue
+		}
+
+		var relation *relatesTo
+		if inThread {
+			if root := matrixThread(ctx, link, ch.ChannelID); root != "" {
+				relation = &relatesTo{RelType: "m.thread", EventID: root, IsFallingBack: true, InReplyTo: &inReplyTo{EventID: root}}
+			}
+		}
+
+		if msg.Content.Text != "" {
+			content := messageContent{MsgType: "m.text", Body: msg.Content.Text, RelatesTo: relation}
+			if eventID, err := sendEvent(ctx, ch.ChannelID, userID, content); err != nil {
+				log.Printf("Matrixへのメッセージ送信に失敗しました (room: %s): %v", ch.ChannelID, err)
+			} else {
+				delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformMatrix, ChannelID: ch.ChannelID, ExternalID: eventID})
+			}
+		}
+		for _, attachment := range msg.Content.Attachments {
+			content := attachmentContent(ctx, attachment)
+			content.RelatesTo = relation
+			if eventID, err := sendEvent(ctx, ch.ChannelID, userID, content); err != nil {
+				log.Printf("Matrixへのメッセージ送信に失敗しました (room: %s): %v", ch.ChannelID, err)
+			} else {
+				delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformMatrix, ChannelID: ch.ChannelID, ExternalID: eventID, Attachment: true})
+			}
+		}
+		log.Printf("Matrix送信成功 (room: %s, user: %s)", ch.ChannelID, userID)
+	}
+}
+
+// EditMatrixMessage は編集されたメッセージの本文を、送信者の仮想ユーザーとして転送先の Matrix メッセージに反映します。
+func EditMatrixMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	userID := puppetUserID(msg.User)
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformMatrix) {
+		if d.Attachment {
+			continue
+		}
+		// 編集に対応していないクライアントには「* 本文」と表示される
+		content := messageContent{
+			MsgType:    "m.text",
+			Body:       "* " + msg.Content.Text,
+			NewContent: &messageContent{MsgType: "m.text", Body: msg.Content.Text},
+			RelatesTo:  &relatesTo{RelType: "m.replace", EventID: d.ExternalID},
+		}
+		if _, err := sendEvent(ctx, d.ChannelID, userID, content); err != nil {
+			log.Printf("Matrixメッセージの編集に失敗しました (room: %s, event: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Matrixメッセージを編集しました (room: %s, event: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// DeleteMatrixMessage は削除されたメッセージを、送信者の仮想ユーザーとして転送先の Matrix から取り消します（redact）。
+func DeleteMatrixMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	userID := puppetUserID(msg.User)
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformMatrix) {
+		txnID := primitive.NewObjectID().Hex()
+		path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/redact/%s/%s", url.PathEscape(d.ChannelID), url.PathEscape(d.ExternalID), txnID)
+		if err := call(ctx, http.MethodPut, path, userID, map[string]string{}, nil); err != nil {
+			log.Printf("Matrixメッセージの取り消しに失敗しました (room: %s, event: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Matrixメッセージを取り消しました (room: %s, event: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// ルームにメッセージイベントを送信し、イベントIDを返す
+func sendEvent(ctx context.Context, roomID, asUser string, content messageContent) (string, error) {
+	var result struct {
+		EventID string `json:"event_id"`
+	}
+	txnID := primitive.NewObjectID().Hex()
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(roomID), txnID)
+	err := call(ctx, http.MethodPut, path, asUser, content, &result)
+	return result.EventID, err
+}
+
+// matrixThread は対応関係に記録されたルーム内のスレッド（ルートイベントID）を返します。
+// まだスレッドがない場合は、Bot がタイトルをルートメッセージとして投稿してスレッドを作成します。
+func matrixThread(ctx context.Context, link model.ThreadLink, roomID string) string {
+	if ref, ok := thread.Counterpart(link, model.PlatformMatrix, roomID); ok {
+		return ref.ThreadID
+	}
+
+	var result struct {
+		EventID string `json:"event_id"`
+	}
+	txnID := primitive.NewObjectID().Hex()
+	path := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(roomID), txnID)
+	content := messageContent{MsgType: "m.notice", Body: "🧵 " + thread.DisplayTitle(link)}
+	if err := call(ctx, http.MethodPut, path, "", content, &result); err != nil {
+		log.Printf("Matrixスレッドの作成に失敗しました (room: %s): %v", roomID, err)
+		return ""
+	}
+
+	ref := model.ThreadRef{Platform: model.PlatformMatrix, ChannelID: roomID, ThreadID: result.EventID}
+	if err := thread.AddThread(link.ID, ref); err != nil {
+		log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+	}
+	log.Printf("Matrixスレッドを作成しました (room: %s, event: %s)", roomID, result.EventID)
+	return result.EventID
+}
+
+// 添付ファイルをホームサーバーにアップロードしてメッセージにする（失敗した場合はリンクとして送信）
+func attachmentContent(ctx context.Context, attachment model.Attachment) messageContent {
+	msgType := "m.file"
+	switch attachment.Type {
+	case "image", "video", "audio":
+		msgType = "m." + attachment.Type
+	}
+
+	mxc, filename, err := uploadFromURL(ctx, attachment.URL)
+	if err != nil {
+		log.Printf("Matrix への添付ファイルのアップロードに失敗しました: %v", err)
+		return messageContent{MsgType: "m.text", Body: "📎 " + attachment.URL}
+	}
+	return messageContent{MsgType: msgType, Body: filename, URL: mxc}
+}
+
+// 送信者のプロフィール（表示名・アイコン）を取得する
+func profile(userID string) (string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var result struct {
+		DisplayName string `json:"displayname"`
+		AvatarURL   string `json:"avatar_url"`
+	}
+	if err := call(ctx, http.MethodGet, "/_matrix/client/v3/profile/"+url.PathEscape(userID), "", nil, &result); err != nil || result.DisplayName == "" {
+		return userID, MediaURL(result.AvatarURL)
+	}
+	return result.DisplayName, MediaURL(result.AvatarURL)
+}
+
+// threadTitle は保存済みのルートメッセージの本文からスレッドのタイトルを作成します。
+func threadTitle(roomID, rootEventID string) string {
+	if mongoCollection == nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var root model.Message
+	filter := bson.M{"user.platform": model.PlatformMatrix, "channelId": roomID, "externalId": rootEventID}
+	if err := mongoCollection.FindOne(ctx, filter).Decode(&root); err != nil {
+		return ""
+	}
+
+	runes := []rune(root.Content.Text)
+	if len(runes) > maxThreadTitleLength {
+		return string(runes[:maxThreadTitleLength]) + "…"
+	}
+	return string(runes)
+}
+
+// 返信時に本文の先頭に付与される引用（「> 」で始まる行）を取り除く
+func stripReplyFallback(body string) string {
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], "> ") {
+		i++
+	}
+	if i == 0 {
+		return body
+	}
+	return strings.TrimLeft(strings.Join(lines[i:], "\n"), "\n")
+}
+
+func botUserID() string {
+	return "@" + botLocalpart + ":" + serverName
+}
+
+// 仮想ユーザー（LinkGate が管理するユーザー）かを返す
+func isVirtualUser(userID string) bool {
+	return strings.HasPrefix(userID, "@"+userPrefix) && strings.HasSuffix(userID, ":"+serverName)
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,253 @@
+package matrix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 仮想ユーザーの保存先
+const puppetCollectionName = "matrix_puppets"
+
+// アップロードする添付ファイル・アイコンの最大サイズ
+const maxUploadSize = 20 << 20
+
+// puppet は他のプラットフォームの送信者に対応する仮想ユーザーです
+type puppet struct {
+	// Matrix のユーザーID
+	UserID string `bson:"_id"`
+	// 設定済みの表示名
+	DisplayName string `bson:"displayName"`
+	// アバターの元になったアイコンURL
+	IconURL string `bson:"iconUrl"`
+	// 参加済みのルーム
+	Rooms []string `bson:"rooms"`
+	// 更新日時
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+// 仮想ユーザーの読み込み・保存（テストで差し替える）
+var (
+	findPuppet = findStoredPuppet
+	savePuppet = saveStoredPuppet
+)
+
+// ensurePuppet は送信者に対応する仮想ユーザーを登録し、プロフィールを最新にしてルームに参加させます。
+func ensurePuppet(ctx context.Context, user model.User, roomID string) (string, error) {
+	userID := puppetUserID(user)
+
+	p, found, err := findPuppet(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		if err := register(ctx, userID); err != nil {
+			return "", err
+		}
+		p = puppet{UserID: userID}
+	}
+
+	update := bson.M{"updatedAt": time.Now()}
+
+	displayName := fmt.Sprintf("%s (%s)", user.Name, user.Platform)
+	if p.DisplayName != displayName {
+		path := "/_matrix/client/v3/profile/" + url.PathEscape(userID) + "/displayname"
+		if err := call(ctx, http.MethodPut, path, userID, map[string]string{"displayname": displayName}, nil); err != nil {
+			return "", err
+		}
+		update["displayName"] = displayName
+	}
+
+	// アバターは失敗しても投稿は続ける
+	if user.IconUrl != "" && p.IconURL != user.IconUrl {
+		if mxc, _, err := uploadFromURL(ctx, user.IconUrl); err == nil {
+			path := "/_matrix/client/v3/profile/" + url.PathEscape(userID) + "/avatar_url"
+			if err := call(ctx, http.MethodPut, path, userID, map[string]string{"avatar_url": mxc}, nil); err == nil {
+				update["iconUrl"] = user.IconUrl
+			}
+		}
+	}
+
+	if !contains(p.Rooms, roomID) {
+		if err := joinRoom(ctx, roomID, userID); err != nil {
+			return "", err
+		}
+	}
+
+	return userID, savePuppet(ctx, userID, update, roomID)
+}
+
+// 保存済みの仮想ユーザーを返す（未登録の場合は false）
+func findStoredPuppet(ctx context.Context, userID string) (puppet, bool, error) {
+	var p puppet
+	err := mongoCollection.Database().Collection(puppetCollectionName).FindOne(ctx, bson.M{"_id": userID}).Decode(&p)
+	if err == mongo.ErrNoDocuments {
+		return puppet{}, false, nil
+	}
+	return p, err == nil, err
+}
+
+// 仮想ユーザーの更新内容と参加したルームを保存する
+func saveStoredPuppet(ctx context.Context, userID string, update bson.M, roomID string) error {
+	_, err := mongoCollection.Database().Collection(puppetCollectionName).UpdateOne(ctx, bson.M{"_id": userID},
+		bson.M{"$set": update, "$addToSet": bson.M{"rooms": roomID}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// 仮想ユーザーをホームサーバーに登録する（登録済みの場合は何もしない）
+func register(ctx context.Context, userID string) error {
+	localpart := strings.TrimPrefix(strings.SplitN(userID, ":", 2)[0], "@")
+	params := map[string]interface{}{
+		"type":     "m.login.application_service",
+		"username": localpart,
+	}
+	err := call(ctx, http.MethodPost, "/_matrix/client/v3/register", "", params, nil)
+	if err != nil && !isErrCode(err, "M_USER_IN_USE") {
+		return err
+	}
+	return nil
+}
+
+// 仮想ユーザーをルームに参加させる。招待制のルームでは Bot が招待してから参加する
+func joinRoom(ctx context.Context, roomID, userID string) error {
+	joinPath := "/_matrix/client/v3/join/" + url.PathEscape(roomID)
+	err := call(ctx, http.MethodPost, joinPath, userID, map[string]string{}, nil)
+	if err == nil || !isErrCode(err, "M_FORBIDDEN") {
+		return err
+	}
+
+	invitePath := "/_matrix/client/v3/rooms/" + url.PathEscape(roomID) + "/invite"
+	if err := call(ctx, http.MethodPost, invitePath, "", map[string]string{"user_id": userID}, nil); err != nil {
+		return err
+	}
+	return call(ctx, http.MethodPost, joinPath, userID, map[string]string{}, nil)
+}
+
+// puppetUserID は送信者に対応する仮想ユーザーのIDを返します（例: @linkgate_discord_123:example.com）。
+func puppetUserID(user model.User) string {
+	localpart := userPrefix + escapeLocalpart(strings.ToLower(string(user.Platform))+"_"+user.UserID)
+	return "@" + localpart + ":" + serverName
+}
+
+// Matrix のユーザーIDに使えない文字をエスケープする。
+// 大文字は「_」+ 小文字、「_」は「__」、その他の文字は「=」+ 16進数に変換する（仕様で推奨されているマッピング）。
+func escapeLocalpart(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			b.WriteByte('_')
+			b.WriteByte(c + ('a' - 'A'))
+		case c == '_':
+			b.WriteString("__")
+		case (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '.' || c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "=%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// 添付ファイル・アイコンの取得に使用するクライアント
+// 他のプラットフォームから渡された任意のURLを取得するため、内部ネットワークへはアクセスしない
+var fetchClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, Control: rejectPrivateAddress}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+}
+
+// 接続先がループバック・プライベート・リンクローカルなどのアドレスであれば拒否する
+// （名前解決後のアドレスで判定するため、DNS で内部アドレスを返すホスト名も拒否される）
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("内部ネットワークのアドレスへはアクセスできません: %s", address)
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// URLのファイルを取得してホームサーバーにアップロードする
+// maxUploadSize を超えるファイルは途中で切り詰めずにエラーにする
+func uploadFromURL(ctx context.Context, fileURL string) (string, string, error) {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", "", fmt.Errorf("対応していないURLです: %s", fileURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("ファイルの取得に失敗しました: status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxUploadSize {
+		return "", "", fmt.Errorf("ファイルサイズが上限を超えています: %d bytes", resp.ContentLength)
+	}
+
+	// Content-Length がない場合もあるため、上限を1バイト超えて読めたらエラーにする
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxUploadSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if len(data) > maxUploadSize {
+		return "", "", fmt.Errorf("ファイルサイズが上限を超えています: %d bytes 以上", len(data))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	filename := "file"
+	if path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+		filename = path.Base(u.Path)
+	}
+
+	mxc, err := upload(ctx, bytes.NewReader(data), contentType, filename)
+	return mxc, filename, err
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
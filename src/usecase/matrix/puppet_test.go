@@ -0,0 +1,292 @@
+package matrix
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "93.184.216.34", want: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "10.0.0.1", want: false},
+		{ip: "172.16.5.4", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "224.0.0.1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+// ホームサーバーのスタブを起動し、アップロードされたデータを返すチャネルを返す
+func newHomeserverStub(t *testing.T) <-chan []byte {
+	t.Helper()
+	uploads := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_matrix/media/v3/upload" || r.Header.Get("Authorization") != "Bearer as-token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"errcode": "M_FORBIDDEN"})
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		uploads <- data
+		json.NewEncoder(w).Encode(map[string]string{"content_uri": "mxc://example.com/abc"})
+	}))
+	t.Cleanup(server.Close)
+
+	prevURL, prevToken := homeserverURL, asToken
+	homeserverURL, asToken = server.URL, "as-token"
+	t.Cleanup(func() { homeserverURL, asToken = prevURL, prevToken })
+	return uploads
+}
+
+func TestUploadFromURL(t *testing.T) {
+	uploads := newHomeserverStub(t)
+
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/cat.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png-bytes"))
+		case "/large.bin":
+			w.Header().Set("Content-Length", "999999999")
+			w.WriteHeader(http.StatusOK)
+		case "/chunked.bin":
+			// Content-Length を付けずに上限を超えるデータを返す
+			flusher := w.(http.Flusher)
+			chunk := make([]byte, 1<<20)
+			for i := 0; i <= maxUploadSize>>20; i++ {
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer files.Close()
+
+	// テスト用のサーバーはループバックアドレスのため、アドレスの制限のないクライアントを使う
+	prevClient := fetchClient
+	fetchClient = files.Client()
+	defer func() { fetchClient = prevClient }()
+
+	tests := []struct {
+		name         string
+		url          string
+		wantErr      bool
+		wantFilename string
+		wantData     string
+	}{
+		{name: "画像", url: files.URL + "/images/cat.png", wantFilename: "cat.png", wantData: "png-bytes"},
+		{name: "Content-Length が上限超過", url: files.URL + "/large.bin", wantErr: true},
+		{name: "本文が上限超過", url: files.URL + "/chunked.bin", wantErr: true},
+		{name: "存在しないファイル", url: files.URL + "/missing", wantErr: true},
+		{name: "http 以外のスキーム", url: "file:///etc/passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mxc, filename, err := uploadFromURL(context.Background(), tt.url)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("uploadFromURL(%s) succeeded, want error", tt.url)
+				}
+				select {
+				case <-uploads:
+					t.Error("エラー時にアップロードされました")
+				default:
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("uploadFromURL(%s) error: %v", tt.url, err)
+			}
+			if mxc != "mxc://example.com/abc" || filename != tt.wantFilename {
+				t.Errorf("uploadFromURL() = (%q, %q), want (mxc://example.com/abc, %q)", mxc, filename, tt.wantFilename)
+			}
+			if got := string(<-uploads); got != tt.wantData {
+				t.Errorf("uploaded %q, want %q", got, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestUploadFromURLRejectsPrivateAddress(t *testing.T) {
+	newHomeserverStub(t)
+
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+
+	_, _, err := uploadFromURL(context.Background(), internal.URL+"/metadata")
+	if err == nil || !strings.Contains(err.Error(), "内部ネットワーク") {
+		t.Fatalf("uploadFromURL(loopback) error = %v, want private address error", err)
+	}
+}
+
+// 仮想ユーザーをメモリに保存する
+func fakePuppets(t *testing.T) map[string]*puppet {
+	t.Helper()
+	stored := map[string]*puppet{}
+	prevFind, prevSave := findPuppet, savePuppet
+	findPuppet = func(ctx context.Context, userID string) (puppet, bool, error) {
+		p, ok := stored[userID]
+		if !ok {
+			return puppet{}, false, nil
+		}
+		return *p, true, nil
+	}
+	savePuppet = func(ctx context.Context, userID string, update bson.M, roomID string) error {
+		p, ok := stored[userID]
+		if !ok {
+			p = &puppet{UserID: userID}
+			stored[userID] = p
+		}
+		if name, ok := update["displayName"].(string); ok {
+			p.DisplayName = name
+		}
+		if icon, ok := update["iconUrl"].(string); ok {
+			p.IconURL = icon
+		}
+		if !contains(p.Rooms, roomID) {
+			p.Rooms = append(p.Rooms, roomID)
+		}
+		return nil
+	}
+	t.Cleanup(func() { findPuppet, savePuppet = prevFind, prevSave })
+	return stored
+}
+
+func TestCreateMatrixMessagePuppet(t *testing.T) {
+	stub := newClientAPIStub(t)
+	stored := fakePuppets(t)
+	// 招待制のルームでは Bot が招待してから参加する
+	stub.inviteOnly["!private:example.com"] = true
+
+	msg := model.Message{
+		BridgeID:  "general",
+		ChannelID: "d-general",
+		User:      model.User{UserID: "123", Platform: model.PlatformDiscord, Name: "Carol"},
+		Content:   model.Content{Text: "hello"},
+	}
+	CreateMatrixMessage(msg)
+
+	const puppetID = "@linkgate_discord__123:example.com"
+	if len(stored) != 1 || stored[puppetID] == nil {
+		t.Fatalf("puppets = %v, want %s", stored, puppetID)
+	}
+
+	registers := stub.called(http.MethodPost, "/_matrix/client/v3/register")
+	if len(registers) != 1 || registers[0].body["username"] != "linkgate_discord__123" ||
+		registers[0].body["type"] != "m.login.application_service" {
+		t.Errorf("register calls = %+v, want one for linkgate_discord__123", registers)
+	}
+
+	names := stub.called(http.MethodPut, "/_matrix/client/v3/profile/"+puppetID+"/displayname")
+	if len(names) != 1 || names[0].asUser != puppetID || names[0].body["displayname"] != "Carol (Discord)" {
+		t.Errorf("displayname calls = %+v, want one as %s", names, puppetID)
+	}
+
+	joins := stub.called(http.MethodPost, "/_matrix/client/v3/join/")
+	wantJoins := []string{
+		"/_matrix/client/v3/join/!room:example.com",
+		"/_matrix/client/v3/join/!private:example.com",
+		"/_matrix/client/v3/join/!private:example.com",
+	}
+	if len(joins) != len(wantJoins) {
+		t.Fatalf("join calls = %+v, want %v", joins, wantJoins)
+	}
+	for i, c := range joins {
+		if c.path != wantJoins[i] || c.asUser != puppetID {
+			t.Errorf("join[%d] = %s as %s, want %s as %s", i, c.path, c.asUser, wantJoins[i], puppetID)
+		}
+	}
+	invites := stub.called(http.MethodPost, "/_matrix/client/v3/rooms/!private:example.com/invite")
+	if len(invites) != 1 || invites[0].asUser != "" || invites[0].body["user_id"] != puppetID {
+		t.Errorf("invite calls = %+v, want one by the bot for %s", invites, puppetID)
+	}
+
+	sends := stub.called(http.MethodPut, "/_matrix/client/v3/rooms/")
+	var rooms []string
+	for _, c := range sends {
+		if !strings.Contains(c.path, "/send/m.room.message/") {
+			continue
+		}
+		if c.asUser != puppetID || c.body["msgtype"] != "m.text" || c.body["body"] != "hello" {
+			t.Errorf("send = %+v, want m.text hello as %s", c, puppetID)
+		}
+		rooms = append(rooms, strings.Split(strings.TrimPrefix(c.path, "/_matrix/client/v3/rooms/"), "/")[0])
+	}
+	if strings.Join(rooms, ",") != "!room:example.com,!private:example.com" {
+		t.Errorf("sent to %v, want both rooms", rooms)
+	}
+
+	// 2回目は登録・表示名の設定・参加をしない
+	stub.mu.Lock()
+	stub.calls = nil
+	stub.mu.Unlock()
+	CreateMatrixMessage(msg)
+
+	for _, prefix := range []string{"/_matrix/client/v3/register", "/_matrix/client/v3/join/"} {
+		if calls := stub.called(http.MethodPost, prefix); len(calls) != 0 {
+			t.Errorf("%s calls = %+v, want none", prefix, calls)
+		}
+	}
+	if calls := stub.called(http.MethodPut, "/_matrix/client/v3/profile/"); len(calls) != 0 {
+		t.Errorf("profile calls = %+v, want none", calls)
+	}
+
+	// 表示名が変わった場合のみ設定し直す
+	msg.User.Name = "Carol B"
+	CreateMatrixMessage(msg)
+	names = stub.called(http.MethodPut, "/_matrix/client/v3/profile/"+puppetID+"/displayname")
+	if len(names) != 1 || names[0].body["displayname"] != "Carol B (Discord)" {
+		t.Errorf("displayname calls = %+v, want one with Carol B (Discord)", names)
+	}
+}
+
+func TestCreateMatrixMessageSkipsOriginRoom(t *testing.T) {
+	stub := newClientAPIStub(t)
+	fakePuppets(t)
+
+	msg := model.Message{
+		BridgeID:  "general",
+		ChannelID: "!room:example.com",
+		User:      model.User{UserID: "@alice:example.com", Platform: model.PlatformMatrix, Name: "Alice"},
+		Content:   model.Content{Text: "hello"},
+	}
+	CreateMatrixMessage(msg)
+
+	for _, c := range stub.called(http.MethodPut, "/_matrix/client/v3/rooms/") {
+		if strings.HasPrefix(c.path, "/_matrix/client/v3/rooms/!room:example.com/") {
+			t.Errorf("投稿元のルームに送信しました: %+v", c)
+		}
+	}
+}
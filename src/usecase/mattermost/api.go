@@ -0,0 +1,141 @@
+package mattermost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// Mattermost サーバーのURL（例: https://mattermost.example.com）
+	serverURL = strings.TrimRight(os.Getenv("MATTERMOST_URL"), "/")
+	// Bot アカウントのアクセストークン
+	accessToken = os.Getenv("MATTERMOST_TOKEN")
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Post は Mattermost の投稿です
+type Post struct {
+	ID        string                 `json:"id,omitempty"`
+	CreateAt  int64                  `json:"create_at,omitempty"`
+	UserID    string                 `json:"user_id,omitempty"`
+	ChannelID string                 `json:"channel_id"`
+	RootID    string                 `json:"root_id,omitempty"`
+	Message   string                 `json:"message"`
+	Type      string                 `json:"type,omitempty"`
+	FileIDs   []string               `json:"file_ids,omitempty"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+}
+
+// User は Mattermost のユーザーです
+type User struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Nickname  string `json:"nickname"`
+}
+
+// FileInfo は添付ファイルの情報です
+type FileInfo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+}
+
+// call は REST API（/api/v4）を呼び出し、レスポンスを out にデコードします。
+func call(ctx context.Context, method, path string, params interface{}, out interface{}) error {
+	var body io.Reader
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, serverURL+"/api/v4"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			ID      string `json:"id"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("Mattermost API エラー (%s %s, status: %d): %s %s", method, path, resp.StatusCode, apiErr.ID, apiErr.Message)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// download はファイルやプロフィール画像を取得します。
+// Mattermost のファイルは認証が必要なため、LinkGate 経由で他のプラットフォームへ配信します。
+func download(ctx context.Context, path string) (io.ReadCloser, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, serverURL+"/api/v4"+path, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", errors.New("Mattermost のファイルの取得に失敗しました: " + resp.Status)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+func getUser(ctx context.Context, userID string) (User, error) {
+	var user User
+	err := call(ctx, http.MethodGet, "/users/"+userID, nil, &user)
+	return user, err
+}
+
+func getFileInfo(ctx context.Context, fileID string) (FileInfo, error) {
+	var info FileInfo
+	err := call(ctx, http.MethodGet, "/files/"+fileID+"/info", nil, &info)
+	return info, err
+}
+
+func createPost(ctx context.Context, post Post) (Post, error) {
+	var created Post
+	err := call(ctx, http.MethodPost, "/posts", post, &created)
+	return created, err
+}
+
+// websocketURL は WebSocket API のURLを返します。
+func websocketURL() string {
+	u := serverURL
+	if strings.HasPrefix(u, "https://") {
+		u = "wss://" + strings.TrimPrefix(u, "https://")
+	} else {
+		u = "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	return u + "/api/v4/websocket"
+}
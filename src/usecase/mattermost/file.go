@@ -0,0 +1,78 @@
+package mattermost
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/usecase/signedurl"
+	"github.com/gin-gonic/gin"
+)
+
+// Mattermost のIDは英小文字と数字の26文字
+var idPattern = regexp.MustCompile(`^[a-z0-9]{26}$`)
+
+// 署名付きURLの種類
+const (
+	fileKind      = "mattermost-file"
+	userImageKind = "mattermost-user-image"
+)
+
+// fileURL は添付ファイルを LinkGate 経由で配信する署名付きURLを返します。
+func fileURL(fileID string) string {
+	return fmt.Sprintf("%s/mattermost/files/%s?%s", publicURL, fileID, signedurl.Query(fileKind, fileID))
+}
+
+// userImageURL はプロフィール画像を LinkGate 経由で配信する署名付きURLを返します。
+func userImageURL(userID string) string {
+	return fmt.Sprintf("%s/mattermost/users/%s/image?%s", publicURL, userID, signedurl.Query(userImageKind, userID))
+}
+
+// HandleFile は Mattermost の添付ファイルを取得してそのまま返します。
+func HandleFile(c *gin.Context) {
+	serve(c, fileKind, c.Param("fileId"), "/files/"+c.Param("fileId"))
+}
+
+// HandleUserImage は Mattermost のユーザーのプロフィール画像を取得してそのまま返します。
+func HandleUserImage(c *gin.Context) {
+	serve(c, userImageKind, c.Param("userId"), "/users/"+c.Param("userId")+"/image")
+}
+
+// Mattermost のファイルは認証が必要なため、Bot のトークンで取得して中継する
+// 任意のファイルを取得できないよう、転送時に発行した署名付きURLのみ受け付ける
+func serve(c *gin.Context, kind, id, path string) {
+	if !Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mattermost 連携が設定されていません"})
+		return
+	}
+	if !idPattern.MatchString(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "IDの形式が正しくありません"})
+		return
+	}
+	if !signedurl.Verify(kind, id, c.Query("exp"), c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "URLの署名が無効か、有効期限が切れています"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	body, contentType, err := download(ctx, path)
+	if err != nil {
+		log.Printf("Mattermostファイルの取得に失敗しました: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "ファイルが見つかりません", "details": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("Content-Type", contentType)
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		log.Printf("Mattermostファイルの送信に失敗しました: %v", err)
+	}
+}
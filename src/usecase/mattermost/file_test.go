@@ -0,0 +1,93 @@
+package mattermost
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mattermost サーバーのスタブを起動し、serverURL を差し替える
+func newServerStub(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v4/files/" + testFileID:
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("file-bytes"))
+		case "/api/v4/users/" + testUserID + "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("icon-bytes"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	prevServer, prevToken, prevPublic := serverURL, accessToken, publicURL
+	serverURL, accessToken, publicURL = server.URL, "test-token", "https://linkgate.example.com"
+	t.Cleanup(func() { serverURL, accessToken, publicURL = prevServer, prevToken, prevPublic })
+}
+
+const (
+	testFileID  = "abcdefghijklmnopqrstuvwxyz"
+	testUserID  = "0123456789abcdefghijklmnop"
+	otherFileID = "zyxwvutsrqponmlkjihgfedcba"
+)
+
+// 署名付きURLからパスとクエリを取り出す
+func requestPath(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.RequestURI()
+}
+
+func TestServe(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newServerStub(t)
+
+	otherSigned := requestPath(t, fileURL(otherFileID))
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "添付ファイル", path: requestPath(t, fileURL(testFileID)), wantStatus: http.StatusOK, wantBody: "file-bytes"},
+		{name: "プロフィール画像", path: requestPath(t, userImageURL(testUserID)), wantStatus: http.StatusOK, wantBody: "icon-bytes"},
+		{name: "署名なしの添付ファイル", path: "/mattermost/files/" + testFileID, wantStatus: http.StatusForbidden},
+		{name: "署名なしのプロフィール画像", path: "/mattermost/users/" + testUserID + "/image", wantStatus: http.StatusForbidden},
+		{name: "別のファイルの署名", path: "/mattermost/files/" + testFileID + otherSigned[strings.Index(otherSigned, "?"):], wantStatus: http.StatusForbidden},
+		{name: "添付ファイルの署名でプロフィール画像を取得", path: "/mattermost/users/" + testFileID + "/image" + requestPath(t, fileURL(testFileID))[len("/mattermost/files/"+testFileID):], wantStatus: http.StatusForbidden},
+		{name: "不正なID", path: "/mattermost/files/ABC", wantStatus: http.StatusBadRequest},
+		{name: "サーバーにないファイル", path: otherSigned, wantStatus: http.StatusNotFound},
+	}
+
+	r := gin.New()
+	r.GET("/mattermost/files/:fileId", HandleFile)
+	r.GET("/mattermost/users/:userId/image", HandleUserImage)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
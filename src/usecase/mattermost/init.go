@@ -0,0 +1,410 @@
+package mattermost
+
+// このパッケージは Mattermost とのメッセージの送受信を担当します。
+// WebSocket API の posted イベントでブリッジに登録されたチャンネルの投稿を受信してDBに保存し、
+// 他のプラットフォームのメッセージは REST API で送信者の名前とアイコンを上書きして投稿します。
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/delivery"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"github.com/gorilla/websocket"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 再接続時の待機時間
+const (
+	websocketInitialBackoff = 1 * time.Second
+	websocketMaxBackoff     = 1 * time.Minute
+)
+
+// 無通信時に Ping を送る間隔と、切断とみなすまでの時間
+const (
+	websocketPingInterval = 30 * time.Second
+	websocketReadTimeout  = 90 * time.Second
+)
+
+// スレッドタイトルとして使用する親メッセージの最大文字数
+const maxThreadTitleLength = 40
+
+// 添付ファイルを配信する LinkGate の公開URL（例: https://linkgate.example.com）
+var publicURL = strings.TrimRight(os.Getenv("LINKGATE_PUBLIC_URL"), "/")
+
+var mongoCollection *mongo.Collection
+
+// Bot アカウントのユーザーID（自分の投稿を転送しないために使用）
+var botUserID string
+
+// WebSocket のリクエスト番号
+var seq int64
+
+// WebSocket API のイベント
+type websocketEvent struct {
+	Event string                     `json:"event"`
+	Data  map[string]json.RawMessage `json:"data"`
+}
+
+// Enabled は Mattermost 連携が設定されているかを返します。
+func Enabled() bool {
+	return serverURL != "" && accessToken != ""
+}
+
+// InitializeMattermostBot は Mattermost の WebSocket API に接続し、切断された場合は再接続を繰り返します。
+func InitializeMattermostBot(collection *mongo.Collection) {
+	if !Enabled() {
+		log.Println("MATTERMOST_URL / MATTERMOST_TOKEN が設定されていないため Mattermost 連携は無効です")
+		return
+	}
+	mongoCollection = collection
+
+	backoff := websocketInitialBackoff
+	for {
+		connected, err := run()
+		if connected {
+			// 一度でも接続できていれば待機時間をリセット
+			backoff = websocketInitialBackoff
+		}
+
+		log.Printf("Mattermost の接続が終了しました: %v (%v後に再接続します)", err, backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > websocketMaxBackoff {
+			backoff = websocketMaxBackoff
+		}
+	}
+}
+
+// run は WebSocket API に接続し、切断されるまでイベントを受信します。
+func run() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	me, err := getUser(ctx, "me")
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	botUserID = me.ID
+
+	header := http.Header{"Authorization": []string{"Bearer " + accessToken}}
+	ws, _, err := websocket.DefaultDialer.Dial(websocketURL(), header)
+	if err != nil {
+		return false, err
+	}
+	defer ws.Close()
+
+	// Authorization ヘッダーを受け付けない構成に備えて認証チャレンジも送信する
+	challenge := map[string]interface{}{
+		"seq":    atomic.AddInt64(&seq, 1),
+		"action": "authentication_challenge",
+		"data":   map[string]string{"token": accessToken},
+	}
+	if err := ws.WriteJSON(challenge); err != nil {
+		return false, err
+	}
+	log.Printf("🔍 Mattermost bot connected (%s as @%s)", serverURL, me.Username)
+
+	// 応答がない場合は読み込みのタイムアウトで切断を検知する
+	ws.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(websocketPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second))
+			}
+		}
+	}()
+
+	for {
+		var event websocketEvent
+		if err := ws.ReadJSON(&event); err != nil {
+			return true, err
+		}
+		ws.SetReadDeadline(time.Now().Add(websocketReadTimeout))
+
+		if event.Event == "posted" {
+			handlePosted(event)
+		}
+	}
+}
+
+// posted イベントの投稿を処理する
+func handlePosted(event websocketEvent) {
+	// data.post は JSON 文字列として送られてくる
+	var raw string
+	if err := json.Unmarshal(event.Data["post"], &raw); err != nil {
+		return
+	}
+	var post Post
+	if err := json.Unmarshal([]byte(raw), &post); err != nil {
+		log.Printf("Mattermost の投稿の解析に失敗しました: %v", err)
+		return
+	}
+
+	// 自分の投稿（転送したメッセージ）とシステムメッセージは転送しない
+	if post.UserID == botUserID || post.Type != "" {
+		return
+	}
+
+	b, ok := bridge.Find(model.PlatformMattermost, post.ChannelID)
+	if !ok {
+		return
+	}
+
+	SaveMattermostMessageToMongoDB(post, b.ID)
+}
+
+// SaveMattermostMessageToMongoDB は Mattermost の投稿を model.Message として保存します。
+func SaveMattermostMessageToMongoDB(post Post, bridgeID string) {
+	if mongoCollection == nil {
+		log.Println("MongoDB collection is not initialized")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := getUser(ctx, post.UserID)
+	if err != nil {
+		log.Printf("Mattermost のユーザー情報の取得に失敗しました: %v", err)
+		user = User{ID: post.UserID, Username: post.UserID}
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = post.UserID
+	message.User.Platform = model.PlatformMattermost
+	message.User.Name = displayName(user)
+	if publicURL != "" {
+		message.User.IconUrl = userImageURL(post.UserID)
+	}
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = post.Message
+	message.Content.Attachments = fileAttachments(ctx, post.FileIDs)
+	message.BridgeID = bridgeID
+	message.ChannelID = post.ChannelID
+	message.ExternalID = post.ID
+	message.CreatedAt = time.UnixMilli(post.CreateAt)
+
+	// スレッドへの返信であれば対応関係を記録する
+	if post.RootID != "" {
+		ref := model.ThreadRef{Platform: model.PlatformMattermost, ChannelID: post.ChannelID, ThreadID: post.RootID}
+		if _, err := thread.Ensure(bridgeID, threadTitle(ctx, post.ChannelID, post.RootID), ref); err != nil {
+			log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+		}
+		message.ThreadID = post.RootID
+	}
+
+	// MongoDB にドキュメントを挿入（再接続時の重複を避けるため upsert する）
+	filter := bson.M{"user.platform": model.PlatformMattermost, "externalId": message.ExternalID}
+	if _, err := mongoCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": message}, options.Update().SetUpsert(true)); err != nil {
+		log.Printf("Mattermostメッセージのデータ登録に失敗しました: %v", err)
+		return
+	}
+
+	log.Printf("Mattermost message saved: %s from %s", message.Content.Text, message.User.Name)
+}
+
+// CreateMattermostMessage はMongoDBに新規追加されたメッセージを、同じブリッジのMattermostチャンネルへ転送します。
+// 投稿者名とアイコンは override_username / override_icon_url で送信者のものに置き換えます。
+func CreateMattermostMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	channels := bridge.Destinations(msg, model.PlatformMattermost)
+	if len(channels) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	link, inThread := thread.ForMessage(msg)
+
+	for _, ch := range channels {
+		post := Post{
+			ChannelID: ch.ChannelID,
+			Message:   createMattermostText(msg),
+			Props: map[string]interface{}{
+				"override_username": fmt.Sprintf("%s (%s)", msg.User.Name, msg.User.Platform),
+				"from_linkgate":     "true",
+			},
+		}
+		if msg.User.IconUrl != "" {
+			post.Props["override_icon_url"] = msg.User.IconUrl
+		}
+		if inThread {
+			post.RootID = mattermostThread(ctx, link, ch.ChannelID)
+		}
+
+		created, err := createPost(ctx, post)
+		if err != nil {
+			log.Printf("Mattermostへのメッセージ送信に失敗しました (channel: %s): %v", ch.ChannelID, err)
+			continue
+		}
+		log.Printf("Mattermost送信成功 (channel: %s)", ch.ChannelID)
+		delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformMattermost, ChannelID: ch.ChannelID, ThreadID: post.RootID, ExternalID: created.ID})
+	}
+}
+
+// EditMattermostMessage は編集されたメッセージの本文を、転送先の Mattermost の投稿に反映します。
+func EditMattermostMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	params := map[string]string{"message": createMattermostText(msg)}
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformMattermost) {
+		if err := call(ctx, http.MethodPut, "/posts/"+d.ExternalID+"/patch", params, nil); err != nil {
+			log.Printf("Mattermostの投稿の編集に失敗しました (channel: %s, post: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Mattermostの投稿を編集しました (channel: %s, post: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// DeleteMattermostMessage は削除されたメッセージを、転送先の Mattermost から削除します。
+func DeleteMattermostMessage(msg model.Message) {
+	if !Enabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformMattermost) {
+		if err := call(ctx, http.MethodDelete, "/posts/"+d.ExternalID, nil, nil); err != nil {
+			log.Printf("Mattermostの投稿の削除に失敗しました (channel: %s, post: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Mattermostの投稿を削除しました (channel: %s, post: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// 本文と添付ファイル（画像はインライン表示、それ以外はリンク）を Markdown にする
+func createMattermostText(msg model.Message) string {
+	lines := []string{}
+	if msg.Content.Text != "" {
+		lines = append(lines, msg.Content.Text)
+	}
+	for _, attachment := range msg.Content.Attachments {
+		if attachment.Type == "image" {
+			lines = append(lines, fmt.Sprintf("![image](%s)", attachment.URL))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("[📎 %s](%s)", attachment.Type, attachment.URL))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mattermostThread は対応関係に記録されたチャンネル内のスレッド（ルート投稿のID）を返します。
+// まだスレッドがない場合は、タイトルをルート投稿として投稿してスレッドを作成します。
+func mattermostThread(ctx context.Context, link model.ThreadLink, channelID string) string {
+	if ref, ok := thread.Counterpart(link, model.PlatformMattermost, channelID); ok {
+		return ref.ThreadID
+	}
+
+	root, err := createPost(ctx, Post{
+		ChannelID: channelID,
+		Message:   "🧵 **" + thread.DisplayTitle(link) + "**",
+		Props:     map[string]interface{}{"override_username": "LinkGate", "from_linkgate": "true"},
+	})
+	if err != nil {
+		log.Printf("Mattermostスレッドの作成に失敗しました (channel: %s): %v", channelID, err)
+		return ""
+	}
+
+	ref := model.ThreadRef{Platform: model.PlatformMattermost, ChannelID: channelID, ThreadID: root.ID}
+	if err := thread.AddThread(link.ID, ref); err != nil {
+		log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+	}
+	log.Printf("Mattermostスレッドを作成しました (channel: %s, post: %s)", channelID, root.ID)
+	return root.ID
+}
+
+// threadTitle は保存済みのルート投稿の本文からスレッドのタイトルを作成します。
+func threadTitle(ctx context.Context, channelID, rootID string) string {
+	var root model.Message
+	filter := bson.M{"user.platform": model.PlatformMattermost, "channelId": channelID, "externalId": rootID}
+	if err := mongoCollection.FindOne(ctx, filter).Decode(&root); err != nil {
+		return ""
+	}
+
+	runes := []rune(root.Content.Text)
+	if len(runes) > maxThreadTitleLength {
+		return string(runes[:maxThreadTitleLength]) + "…"
+	}
+	return string(runes)
+}
+
+// 添付ファイルを LinkGate 経由で配信するURLに変換する
+func fileAttachments(ctx context.Context, fileIDs []string) []model.Attachment {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	if publicURL == "" {
+		log.Println("LINKGATE_PUBLIC_URL が設定されていないため Mattermost の添付ファイルは転送されません")
+		return nil
+	}
+
+	attachments := []model.Attachment{}
+	for _, id := range fileIDs {
+		attachmentType := "file"
+		if info, err := getFileInfo(ctx, id); err == nil {
+			switch {
+			case strings.HasPrefix(info.MimeType, "image/"):
+				attachmentType = "image"
+			case strings.HasPrefix(info.MimeType, "video/"):
+				attachmentType = "video"
+			case strings.HasPrefix(info.MimeType, "audio/"):
+				attachmentType = "audio"
+			}
+		}
+		attachments = append(attachments, model.Attachment{
+			Type: attachmentType,
+			URL:  fileURL(id),
+		})
+	}
+	return attachments
+}
+
+// 表示名: ニックネーム > 姓名 > ユーザー名
+func displayName(user User) string {
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	return user.Username
+}
@@ -0,0 +1,221 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 接続を維持するためのハートビートの間隔
+const heartbeatInterval = 15 * time.Second
+
+// 切断された場合にクライアントが再接続するまでの時間（ミリ秒）
+const retryMillis = 3000
+
+// WebSocket の書き込みの期限
+const writeWait = 10 * time.Second
+
+// WebSocket の接続を許可するオリジン（カンマ区切り。* で全て許可）。
+// 未設定の場合は LinkGate と同じオリジンからの接続のみ許可します。
+var allowedOrigins = os.Getenv("STREAM_ALLOWED_ORIGINS")
+
+var upgrader = websocket.Upgrader{CheckOrigin: checkOrigin}
+
+// HandleSSE はメッセージのイベントを Server-Sent Events で配信します。
+// ?bridgeId= でブリッジを絞り込み、Last-Event-ID ヘッダー（または ?lastEventId=）で続きから受信できます。
+func HandleSSE(c *gin.Context) {
+	bridgeID, ok := bridgeFilter(c)
+	if !ok {
+		return
+	}
+	ServeSSE(c, bridgeID)
+}
+
+// ServeSSE はブリッジのイベントを Server-Sent Events で配信します（bridgeID が空の場合は全てのブリッジ）。
+func ServeSSE(c *gin.Context, bridgeID string) {
+	s, missed, resumed := Subscribe(bridgeID, lastEventID(c))
+	defer Unsubscribe(s)
+	streamSSE(c, s, missed, resumed)
+}
+
+// ServeChannelSSE はチャンネルに表示されるメッセージのイベントを Server-Sent Events で配信します。
+func ServeChannelSSE(c *gin.Context, ch model.Channel) {
+	s, missed, resumed := SubscribeChannel(ch, lastEventID(c))
+	defer Unsubscribe(s)
+	streamSSE(c, s, missed, resumed)
+}
+
+// Last-Event-ID ヘッダー（または ?lastEventId=）の値を返す
+func lastEventID(c *gin.Context) string {
+	if id := c.GetHeader("Last-Event-ID"); id != "" {
+		return id
+	}
+	return c.Query("lastEventId")
+}
+
+// 再送するイベントを書き込み、以降のイベントを切断されるまで配信する
+func streamSSE(c *gin.Context, s *Subscriber, missed []Event, resumed bool) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// リバースプロキシ（nginx）でバッファリングされないようにする
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+	if !resumed {
+		writeSSE(w, Event{Type: EventReset})
+	}
+	for _, event := range missed {
+		writeSSE(w, event)
+	}
+	w.Flush()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-s.C:
+			if !ok {
+				return
+			}
+			writeSSE(w, event)
+			w.Flush()
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			w.Flush()
+		}
+	}
+}
+
+// 1件のイベントを SSE の形式で書き込む
+func writeSSE(w io.Writer, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("イベントのJSONエンコードに失敗しました: %v", err)
+		return
+	}
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
+
+// HandleWebSocket はメッセージのイベントを WebSocket で配信します。
+// ?bridgeId= でブリッジを絞り込み、?lastEventId= で続きから受信できます。
+// 各イベントは {"id", "type", "bridgeId", "message"} の JSON テキストメッセージとして送信します。
+func HandleWebSocket(c *gin.Context) {
+	bridgeID, ok := bridgeFilter(c)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade が失敗した場合はエラーレスポンスが返されている
+		log.Printf("WebSocket への切り替えに失敗しました: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	s, missed, resumed := Subscribe(bridgeID, c.Query("lastEventId"))
+	defer Unsubscribe(s)
+
+	// クライアントからのメッセージは読み捨て、切断と Pong のみ検知する
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	})
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event Event) bool {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteJSON(event) == nil
+	}
+
+	if !resumed && !send(Event{Type: EventReset}) {
+		return
+	}
+	for _, event := range missed {
+		if !send(event) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-s.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(writeWait))
+				return
+			}
+			if !send(event) {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ?bridgeId= のブリッジを返す（存在しない場合はレスポンスを返して false）
+func bridgeFilter(c *gin.Context) (string, bool) {
+	id := c.Query("bridgeId")
+	if id == "" {
+		return "", true
+	}
+	b, ok := bridge.Get(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "ブリッジが見つかりません"})
+		return "", false
+	}
+	return b.ID, true
+}
+
+// WebSocket の接続元オリジンを確認する
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if allowedOrigins == "" {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range strings.Split(allowedOrigins, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package realtime
+
+// このパッケージは change stream で受け取ったメッセージの追加・編集・削除を、
+// SSE や WebSocket で接続しているクライアントへリアルタイムに配信します。
+//
+// 直近のイベントはメモリに保持し、再接続したクライアントが Last-Event-ID を指定した場合は
+// その後のイベントを再送します。保持していない ID の場合は reset イベントを送り、
+// GET /messages で取得し直すよう促します。
+
+import (
+	"slices"
+	"sync"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+)
+
+// イベントの種類
+const (
+	EventMessageCreated = "message.created"
+	EventMessageUpdated = "message.updated"
+	EventMessageDeleted = "message.deleted"
+	// Last-Event-ID のイベントを保持していないため、再送できないことを通知する
+	EventReset = "reset"
+)
+
+// 再送のために保持するイベントの数
+const historySize = 1000
+
+// 配信が追いつかないクライアントのために溜めておくイベントの数（超えた場合は切断する）
+const subscriberBuffer = 64
+
+// Event は配信するイベントです
+type Event struct {
+	// イベントID（change stream の再開トークン）
+	ID string `json:"id,omitempty"`
+	// イベントの種類
+	Type string `json:"type"`
+	// ブリッジID
+	BridgeID string `json:"bridgeId,omitempty"`
+	// メッセージ（削除の場合は本文を含みません。reset イベントには含まれません）
+	Message *model.Message `json:"message,omitempty"`
+
+	// メッセージが投稿・転送されるブリッジ（転送先に指定された他のブリッジを含む）
+	bridgeIDs []string
+}
+
+// Subscriber はイベントを受信するクライアントです
+type Subscriber struct {
+	// イベントを受信するチャンネル（配信が追いつかない場合は閉じられます）
+	C chan Event
+	// 受信するブリッジ（空の場合は全て）
+	bridgeID string
+	// 受信するチャンネル（Web チャットの場合のみ。転送先に含まれるメッセージのみ受信する）
+	channel *model.Channel
+}
+
+var (
+	mu          sync.Mutex
+	history     []Event
+	subscribers = map[*Subscriber]struct{}{}
+)
+
+// Publish はメッセージのイベントを接続中のクライアントへ配信します。
+func Publish(id, eventType string, msg model.Message) {
+	// 削除されたメッセージの本文は配信しない
+	if eventType == EventMessageDeleted {
+		msg.Content = model.Content{ID: msg.Content.ID}
+	}
+
+	event := Event{ID: id, Type: eventType, BridgeID: msg.BridgeID, Message: &msg, bridgeIDs: bridge.BridgeIDs(msg)}
+	if b, ok := bridge.Get(msg.BridgeID); ok {
+		event.BridgeID = b.ID
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	history = append(history, event)
+	if len(history) > historySize {
+		history = history[len(history)-historySize:]
+	}
+
+	for s := range subscribers {
+		if !s.accepts(event) {
+			continue
+		}
+		select {
+		case s.C <- event:
+		default:
+			// 配信が追いつかないクライアントは切断し、Last-Event-ID で再接続してもらう
+			close(s.C)
+			delete(subscribers, s)
+		}
+	}
+}
+
+// Subscribe はイベントの受信を開始します。
+// lastEventID を指定した場合は、その後のイベントを返します。保持していない ID の場合は false を返します。
+func Subscribe(bridgeID, lastEventID string) (*Subscriber, []Event, bool) {
+	return subscribe(&Subscriber{C: make(chan Event, subscriberBuffer), bridgeID: bridgeID}, lastEventID)
+}
+
+// SubscribeChannel はチャンネルに表示されるメッセージ（投稿元または転送先がチャンネルのもの）のイベントの受信を開始します。
+// Web チャットのように、ブリッジではなくチャンネル単位で受信する場合に使用します。
+func SubscribeChannel(ch model.Channel, lastEventID string) (*Subscriber, []Event, bool) {
+	return subscribe(&Subscriber{C: make(chan Event, subscriberBuffer), channel: &ch}, lastEventID)
+}
+
+func subscribe(s *Subscriber, lastEventID string) (*Subscriber, []Event, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	subscribers[s] = struct{}{}
+	if lastEventID == "" {
+		return s, nil, true
+	}
+
+	for i, event := range history {
+		if event.ID != lastEventID {
+			continue
+		}
+		missed := []Event{}
+		for _, e := range history[i+1:] {
+			if s.accepts(e) {
+				missed = append(missed, e)
+			}
+		}
+		return s, missed, true
+	}
+	return s, nil, false
+}
+
+// Unsubscribe はイベントの受信を終了します。
+func Unsubscribe(s *Subscriber) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := subscribers[s]; ok {
+		close(s.C)
+		delete(subscribers, s)
+	}
+}
+
+func (s *Subscriber) accepts(event Event) bool {
+	if s.channel != nil {
+		return event.Message != nil && bridge.Reaches(*event.Message, *s.channel)
+	}
+	return s.bridgeID == "" || slices.Contains(event.bridgeIDs, s.bridgeID)
+}
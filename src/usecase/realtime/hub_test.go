@@ -0,0 +1,125 @@
+package realtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fuagfuga-2025-LinkGate/src/model"
+)
+
+// テスト用のブリッジ設定を読み込ませる
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "realtime")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "bridges.json")
+	config := `[
+		{"id": "general", "channels": [
+			{"platform": "Discord", "channelId": "d-general"},
+			{"platform": "Web", "channelId": "w-general"}
+		]},
+		{"id": "staff", "channels": [
+			{"platform": "IRC", "channelId": "#staff"},
+			{"platform": "Web", "channelId": "w-staff"}
+		]}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv("BRIDGE_CONFIG_PATH", path)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 受信したイベントのIDを返す（受信済みのもののみ）
+func received(s *Subscriber) []string {
+	var ids []string
+	for {
+		select {
+		case e := <-s.C:
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestPublishTargets(t *testing.T) {
+	all, _, _ := Subscribe("", "")
+	general, _, _ := Subscribe("general", "")
+	staff, _, _ := Subscribe("staff", "")
+	generalWeb, _, _ := SubscribeChannel(model.Channel{Platform: model.PlatformWeb, ChannelID: "w-general"}, "")
+	staffWeb, _, _ := SubscribeChannel(model.Channel{Platform: model.PlatformWeb, ChannelID: "w-staff"}, "")
+	for _, s := range []*Subscriber{all, general, staff, generalWeb, staffWeb} {
+		defer Unsubscribe(s)
+	}
+
+	fromDiscord := model.Message{BridgeID: "general", ChannelID: "d-general", User: model.User{Platform: model.PlatformDiscord}}
+	// 1: 投稿先のブリッジのみ
+	Publish("1", EventMessageCreated, fromDiscord)
+	// 2: 別のブリッジの Web チャットのみに転送
+	toStaffWeb := fromDiscord
+	toStaffWeb.Targets = []model.Target{{BridgeID: "staff", Platform: model.PlatformWeb}}
+	Publish("2", EventMessageCreated, toStaffWeb)
+	// 3: 別のブリッジの IRC のみに転送
+	toStaffIRC := fromDiscord
+	toStaffIRC.Targets = []model.Target{{BridgeID: "staff", Platform: model.PlatformIRC}}
+	Publish("3", EventMessageCreated, toStaffIRC)
+	// 4: 削除（本文を含まなくても転送先で絞り込む）
+	Publish("4", EventMessageDeleted, toStaffWeb)
+
+	tests := []struct {
+		name string
+		s    *Subscriber
+		want []string
+	}{
+		{name: "全てのブリッジ", s: all, want: []string{"1", "2", "3", "4"}},
+		{name: "投稿先のブリッジ", s: general, want: []string{"1", "2", "3", "4"}},
+		{name: "転送先に指定されたブリッジ", s: staff, want: []string{"2", "3", "4"}},
+		{name: "投稿先のブリッジの Web チャット", s: generalWeb, want: []string{"1"}},
+		{name: "転送先のブリッジの Web チャット", s: staffWeb, want: []string{"2", "4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := received(tt.s)
+			if len(got) != len(tt.want) {
+				t.Fatalf("received %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("received %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeChannelReplay(t *testing.T) {
+	fromDiscord := model.Message{BridgeID: "general", ChannelID: "d-general", User: model.User{Platform: model.PlatformDiscord}}
+	toStaffWeb := fromDiscord
+	toStaffWeb.Targets = []model.Target{{BridgeID: "staff", Platform: model.PlatformWeb}}
+
+	Publish("replay-1", EventMessageCreated, fromDiscord)
+	Publish("replay-2", EventMessageCreated, fromDiscord)
+	Publish("replay-3", EventMessageCreated, toStaffWeb)
+
+	s, missed, ok := SubscribeChannel(model.Channel{Platform: model.PlatformWeb, ChannelID: "w-staff"}, "replay-1")
+	defer Unsubscribe(s)
+	if !ok {
+		t.Fatal("SubscribeChannel() ok = false, want true")
+	}
+	if len(missed) != 1 || missed[0].ID != "replay-3" {
+		t.Errorf("missed = %v, want [replay-3]", missed)
+	}
+
+	s2, _, ok := SubscribeChannel(model.Channel{Platform: model.PlatformWeb, ChannelID: "w-staff"}, "unknown")
+	defer Unsubscribe(s2)
+	if ok {
+		t.Error("SubscribeChannel() with unknown Last-Event-ID ok = true, want false")
+	}
+}
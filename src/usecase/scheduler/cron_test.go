@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "0 9 * * MON-FRI"},
+		{expr: "*/15 0-6/2 1,15 jan-jun ?"},
+		{expr: "5/15 * * * 7"},
+		{expr: "@daily"},
+		{expr: "  @Hourly  "},
+		{expr: "* * * *", wantErr: true},
+		{expr: "* * * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "10-5 * * * *", wantErr: true},
+		{expr: "* * * foo *", wantErr: true},
+		{expr: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("タイムゾーンを読み込めません: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		expr  string
+		after time.Time
+		loc   *time.Location
+		want  time.Time
+	}{
+		{
+			name:  "毎分は次の分",
+			expr:  "* * * * *",
+			after: time.Date(2025, 1, 1, 10, 0, 30, 0, time.UTC),
+			loc:   time.UTC,
+			want:  time.Date(2025, 1, 1, 10, 1, 0, 0, time.UTC),
+		},
+		{
+			name:  "一致する日時ちょうどの場合は次回",
+			expr:  "0 9 * * *",
+			after: time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+			loc:   time.UTC,
+			want:  time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "15分ごと",
+			expr:  "*/15 * * * *",
+			after: time.Date(2025, 1, 1, 10, 16, 0, 0, time.UTC),
+			loc:   time.UTC,
+			want:  time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:  "平日のみ（金曜の後は月曜）",
+			expr:  "0 9 * * MON-FRI",
+			after: time.Date(2025, 1, 3, 10, 0, 0, 0, time.UTC), // 金曜日
+			loc:   time.UTC,
+			want:  time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "曜日の 7 は日曜日",
+			expr:  "0 0 * * 7",
+			after: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), // 水曜日
+			loc:   time.UTC,
+			want:  time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "日と曜日の両方を指定した場合はどちらかに一致",
+			expr:  "0 0 13 * FRI",
+			after: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			loc:   time.UTC,
+			want:  time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "月末をまたぐ",
+			expr:  "0 0 31 * *",
+			after: time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+			loc:   time.UTC,
+			want:  time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "うるう日",
+			expr:  "0 0 29 2 *",
+			after: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			loc:   time.UTC,
+			want:  time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "タイムゾーンで判定",
+			expr:  "0 9 * * *",
+			after: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), // 日本時間 9:00
+			loc:   tokyo,
+			want:  time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", tt.expr, err)
+			}
+			got, ok := spec.next(tt.after, tt.loc)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("next(%v) = %v, %v, want %v", tt.after, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNextNoMatch(t *testing.T) {
+	// 2月30日は存在しない
+	spec, err := parseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parseCron error = %v", err)
+	}
+	if got, ok := spec.next(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.UTC); ok {
+		t.Errorf("next() = %v, want no match", got)
+	}
+}
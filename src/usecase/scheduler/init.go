@@ -0,0 +1,83 @@
+package scheduler
+
+// このパッケージは予約投稿（1回のみ・cron 式による繰り返し）を管理します。
+// 予約投稿は MongoDB に保存し、複数のインスタンスで動かしている場合も選出されたリーダーの1台だけが
+// 投稿日時になった予約投稿を model.Message として保存します（転送は change stream で行われます）。
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// 投稿日時になった予約投稿を確認する間隔
+const pollInterval = 10 * time.Second
+
+// cron 式を解釈する既定のタイムゾーン（未設定の場合はサーバーのタイムゾーン）
+var defaultTimezone = os.Getenv("SCHEDULER_TIMEZONE")
+
+var (
+	ErrNotInitialized = errors.New("予約投稿の保存先が初期化されていません")
+	ErrNotFound       = errors.New("予約投稿が見つかりません")
+	ErrInvalid        = errors.New("予約投稿の指定が正しくありません")
+	ErrInvalidState   = errors.New("現在の状態では変更できません")
+)
+
+var (
+	// 予約投稿の保存先
+	collection *mongo.Collection
+	// リーダー選出に使用するロックの保存先
+	lockCollection *mongo.Collection
+	// メッセージの保存先
+	messageCollection *mongo.Collection
+)
+
+// Init は予約投稿・ロック・メッセージの保存先を設定し、検索用のインデックスを作成します。
+func Init(coll, locks, messages *mongo.Collection) error {
+	collection = coll
+	lockCollection = locks
+	messageCollection = messages
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextRunAt", Value: 1}}},
+		{Keys: bson.D{{Key: "integrationId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return err
+}
+
+// Run は一定間隔でリーダーの選出と、投稿日時になった予約投稿の実行を行います。
+func Run() {
+	if collection == nil || lockCollection == nil {
+		log.Println("予約投稿の保存先が初期化されていないため、予約投稿を実行しません")
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		if !elect() {
+			continue
+		}
+		if err := runDue(); err != nil {
+			log.Printf("予約投稿の実行に失敗しました: %v", err)
+		}
+	}
+}
+
+// 予約投稿のタイムゾーンを返す
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		name = defaultTimezone
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
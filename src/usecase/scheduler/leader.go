@@ -0,0 +1,67 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// リーダーのロックのID
+const leaderLockID = "scheduler"
+
+// リーダーの有効期間。リーダーが停止した場合は、この時間が過ぎると他のインスタンスがリーダーになります
+const leaseDuration = 30 * time.Second
+
+// このインスタンスの識別子
+var instanceID = newInstanceID()
+
+// このインスタンスがリーダーか
+var leader bool
+
+// elect はリーダーのロックを取得・延長し、このインスタンスがリーダーかを返します。
+// ロックは期限切れか自分が持っている場合のみ更新でき、他のインスタンスが持っている場合は
+// upsert が _id の重複エラーになるため、同時に2台がリーダーになることはありません。
+func elect() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"_id": leaderLockID,
+		"$or": bson.A{
+			bson.M{"owner": instanceID},
+			bson.M{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": instanceID, "expiresAt": now.Add(leaseDuration)}}
+	_, err := lockCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+
+	elected := err == nil
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Printf("予約投稿のリーダー選出に失敗しました: %v", err)
+	}
+	if elected != leader {
+		if elected {
+			log.Printf("予約投稿のリーダーになりました (instance: %s)", instanceID)
+		} else {
+			log.Printf("予約投稿のリーダーではなくなりました (instance: %s)", instanceID)
+		}
+		leader = elected
+	}
+	return elected
+}
+
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/apikey"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 1回のみの予約投稿の投稿に失敗した場合に再試行する間隔
+// （投稿中にインスタンスが停止した場合も、この時間が過ぎると他のインスタンスが投稿します）
+const retryInterval = time.Minute
+
+// 1回のみの予約投稿を試行する回数（全て失敗した場合は completed にし、lastError にエラーを残します）
+const maxAttempts = 5
+
+var (
+	errIntegrationDisabled = errors.New("連携が無効化されています")
+	errTargetNotAllowed    = errors.New("投稿先以外のブリッジへの転送には、予約投稿を登録したAPIキーに admin スコープが必要です")
+)
+
+// 予約投稿を登録したAPIキーを取得する（テストで差し替える）
+var getAPIKey = apikey.Get
+
+// runDue は投稿日時になった予約投稿を実行します。
+func runDue() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"status": model.ScheduleActive, "nextRunAt": bson.M{"$lte": now}}
+	cur, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"nextRunAt": 1}))
+	if err != nil {
+		return err
+	}
+	var schedules []model.Schedule
+	if err := cur.All(ctx, &schedules); err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		next, ok := claim(schedule, now)
+		if !ok {
+			continue
+		}
+		runErr := post(schedule)
+		if runErr != nil {
+			log.Printf("予約投稿に失敗しました (schedule: %s): %v", schedule.ID, runErr)
+		} else {
+			log.Printf("予約投稿成功 (schedule: %s)", schedule.ID)
+		}
+		recordRun(schedule, next, now, runErr)
+	}
+	return nil
+}
+
+// claim は予約投稿の次回の投稿日時を進め、今回の実行権を得たかを返します（進めた次回の投稿日時も返します）。
+// 停止していた間に過ぎた繰り返しの投稿日時はまとめて1回だけ投稿し、次回は現在時刻から計算します。
+// 1回のみの予約投稿はこの時点では完了にせず、投稿に失敗した場合に備えて次回の投稿日時を retryInterval 後にします。
+func claim(schedule model.Schedule, now time.Time) (*time.Time, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, update, next := claimUpdate(schedule, now)
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("予約投稿の更新に失敗しました (schedule: %s): %v", schedule.ID, err)
+		return nil, false
+	}
+	return next, result.ModifiedCount == 1
+}
+
+// 実行権を得るための更新の条件と内容、更新後の次回の投稿日時を返す
+func claimUpdate(schedule model.Schedule, now time.Time) (bson.M, bson.M, *time.Time) {
+	// 一覧の取得後に一時停止・取り消し・実行された場合は更新しない
+	filter := bson.M{"_id": schedule.ID, "status": model.ScheduleActive, "nextRunAt": schedule.NextRunAt}
+
+	next := now.Add(retryInterval)
+	if schedule.Cron != "" {
+		t, err := nextRun(schedule, now)
+		if err != nil {
+			log.Printf("予約投稿の次回の投稿日時を計算できません (schedule: %s): %v", schedule.ID, err)
+			update := bson.M{"$set": bson.M{"status": model.ScheduleCompleted}, "$unset": bson.M{"nextRunAt": ""}}
+			return filter, update, nil
+		}
+		next = t
+	}
+	return filter, bson.M{"$set": bson.M{"nextRunAt": next}}, &next
+}
+
+// post は予約投稿を連携（API プラットフォーム）の投稿としてメッセージに保存します。
+func post(schedule model.Schedule) error {
+	integration, err := webhook.Get(schedule.IntegrationID)
+	if err != nil {
+		return err
+	}
+	if integration.Disabled {
+		return errIntegrationDisabled
+	}
+	user, err := webhook.APIUser(integration, "")
+	if err != nil {
+		return err
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User = user
+	message.Content = schedule.Content
+	message.Content.ID = primitive.NewObjectID()
+	message.ChannelID = integration.ID
+	if b, ok := bridge.Find(model.PlatformWebhook, integration.ID); ok {
+		message.BridgeID = b.ID
+	}
+	for _, id := range schedule.BridgeIDs {
+		message.Targets = append(message.Targets, model.Target{BridgeID: id})
+	}
+	message.CreatedAt = time.Now()
+	if err := checkTargets(schedule, message); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = messageCollection.InsertOne(ctx, message)
+	return err
+}
+
+// 投稿先以外のブリッジへの転送は、登録したAPIキーが現在も admin スコープを持っている場合のみ許可する
+// （登録後にキーが失効した場合や、連携が別のブリッジに移った場合に備えて実行のたびに確認する）
+func checkTargets(schedule model.Schedule, message model.Message) error {
+	home, _ := bridge.Get(message.BridgeID)
+	for _, id := range schedule.BridgeIDs {
+		b, ok := bridge.Get(id)
+		if !ok {
+			return fmt.Errorf("転送先のブリッジが見つかりません: %s", id)
+		}
+		if b.ID == home.ID {
+			continue
+		}
+		key, err := getAPIKey(schedule.APIKeyID)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errTargetNotAllowed, err)
+		}
+		if key.RevokedAt != nil || !key.HasScope(model.ScopeAdmin) {
+			return errTargetNotAllowed
+		}
+	}
+	return nil
+}
+
+// 実行の結果を記録する
+func recordRun(schedule model.Schedule, next *time.Time, now time.Time, runErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter, update := runUpdate(schedule, next, now, runErr)
+	if _, err := collection.UpdateOne(ctx, filter, update); err != nil {
+		log.Printf("予約投稿の更新に失敗しました (schedule: %s): %v", schedule.ID, err)
+	}
+}
+
+// 実行の結果を記録する更新の条件と内容を返す
+// 1回のみの予約投稿は投稿に成功した場合に完了とし、失敗した場合は maxAttempts 回まで再試行する
+func runUpdate(schedule model.Schedule, next *time.Time, now time.Time, runErr error) (bson.M, bson.M) {
+	filter := bson.M{"_id": schedule.ID}
+	set := bson.M{"lastError": ""}
+	update := bson.M{"$set": set}
+	if runErr == nil {
+		set["lastRunAt"] = now
+		update["$inc"] = bson.M{"runCount": 1}
+	} else {
+		set["lastError"] = runErr.Error()
+	}
+	if schedule.Cron != "" {
+		return filter, update
+	}
+
+	// 投稿中に取り消された場合や、再開して投稿日時が変わった場合は更新しない
+	filter["nextRunAt"] = next
+	switch {
+	case runErr == nil:
+		set["status"] = model.ScheduleCompleted
+		update["$unset"] = bson.M{"nextRunAt": "", "failures": ""}
+	case schedule.Failures+1 >= maxAttempts:
+		set["status"] = model.ScheduleCompleted
+		set["failures"] = schedule.Failures + 1
+		update["$unset"] = bson.M{"nextRunAt": ""}
+	default:
+		set["failures"] = schedule.Failures + 1
+	}
+	return filter, update
+}
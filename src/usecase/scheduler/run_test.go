@@ -0,0 +1,201 @@
+package scheduler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/apikey"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// テスト用のブリッジ設定を読み込ませる
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "scheduler")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "bridges.json")
+	config := `[
+		{"id": "general", "channels": [{"platform": "Webhook", "channelId": "integration"}]},
+		{"id": "staff", "channels": [{"platform": "Discord", "channelId": "d-staff"}]}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv("BRIDGE_CONFIG_PATH", path)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestClaimUpdate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	due := now.Add(-time.Minute)
+
+	t.Run("1回のみは完了にせず再試行に備える", func(t *testing.T) {
+		schedule := model.Schedule{ID: "once", NextRunAt: &due}
+		filter, update, next := claimUpdate(schedule, now)
+
+		wantFilter := bson.M{"_id": "once", "status": model.ScheduleActive, "nextRunAt": &due}
+		if !reflect.DeepEqual(filter, wantFilter) {
+			t.Errorf("filter = %v, want %v", filter, wantFilter)
+		}
+		wantNext := now.Add(retryInterval)
+		if next == nil || !next.Equal(wantNext) {
+			t.Fatalf("next = %v, want %v", next, wantNext)
+		}
+		wantUpdate := bson.M{"$set": bson.M{"nextRunAt": *next}}
+		if !reflect.DeepEqual(update, wantUpdate) {
+			t.Errorf("update = %v, want %v", update, wantUpdate)
+		}
+	})
+
+	t.Run("繰り返しは次回の投稿日時に進める", func(t *testing.T) {
+		schedule := model.Schedule{ID: "daily", Cron: "0 9 * * *", Timezone: "UTC", NextRunAt: &due}
+		_, update, next := claimUpdate(schedule, now)
+
+		wantNext := now.AddDate(0, 0, 1)
+		if next == nil || !next.Equal(wantNext) {
+			t.Fatalf("next = %v, want %v", next, wantNext)
+		}
+		wantUpdate := bson.M{"$set": bson.M{"nextRunAt": *next}}
+		if !reflect.DeepEqual(update, wantUpdate) {
+			t.Errorf("update = %v, want %v", update, wantUpdate)
+		}
+	})
+
+	t.Run("次回の投稿日時がない繰り返しは完了", func(t *testing.T) {
+		schedule := model.Schedule{ID: "never", Cron: "0 0 30 2 *", Timezone: "UTC", NextRunAt: &due}
+		_, update, next := claimUpdate(schedule, now)
+
+		if next != nil {
+			t.Errorf("next = %v, want nil", next)
+		}
+		wantUpdate := bson.M{"$set": bson.M{"status": model.ScheduleCompleted}, "$unset": bson.M{"nextRunAt": ""}}
+		if !reflect.DeepEqual(update, wantUpdate) {
+			t.Errorf("update = %v, want %v", update, wantUpdate)
+		}
+	})
+}
+
+func TestRunUpdate(t *testing.T) {
+	now := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	next := now.Add(retryInterval)
+	runErr := errors.New("投稿に失敗しました")
+
+	tests := []struct {
+		name       string
+		schedule   model.Schedule
+		runErr     error
+		wantFilter bson.M
+		wantUpdate bson.M
+	}{
+		{
+			name:       "1回のみ 成功",
+			schedule:   model.Schedule{ID: "s", Failures: 2},
+			wantFilter: bson.M{"_id": "s", "nextRunAt": &next},
+			wantUpdate: bson.M{
+				"$set":   bson.M{"lastError": "", "lastRunAt": now, "status": model.ScheduleCompleted},
+				"$inc":   bson.M{"runCount": 1},
+				"$unset": bson.M{"nextRunAt": "", "failures": ""},
+			},
+		},
+		{
+			name:       "1回のみ 失敗は再試行",
+			schedule:   model.Schedule{ID: "s", Failures: 1},
+			runErr:     runErr,
+			wantFilter: bson.M{"_id": "s", "nextRunAt": &next},
+			wantUpdate: bson.M{"$set": bson.M{"lastError": runErr.Error(), "failures": 2}},
+		},
+		{
+			name:       "1回のみ 試行回数の上限",
+			schedule:   model.Schedule{ID: "s", Failures: maxAttempts - 1},
+			runErr:     runErr,
+			wantFilter: bson.M{"_id": "s", "nextRunAt": &next},
+			wantUpdate: bson.M{
+				"$set":   bson.M{"lastError": runErr.Error(), "failures": maxAttempts, "status": model.ScheduleCompleted},
+				"$unset": bson.M{"nextRunAt": ""},
+			},
+		},
+		{
+			name:       "繰り返し 成功",
+			schedule:   model.Schedule{ID: "s", Cron: "* * * * *"},
+			wantFilter: bson.M{"_id": "s"},
+			wantUpdate: bson.M{"$set": bson.M{"lastError": "", "lastRunAt": now}, "$inc": bson.M{"runCount": 1}},
+		},
+		{
+			name:       "繰り返し 失敗",
+			schedule:   model.Schedule{ID: "s", Cron: "* * * * *"},
+			runErr:     runErr,
+			wantFilter: bson.M{"_id": "s"},
+			wantUpdate: bson.M{"$set": bson.M{"lastError": runErr.Error()}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, update := runUpdate(tt.schedule, &next, now, tt.runErr)
+			if !reflect.DeepEqual(filter, tt.wantFilter) {
+				t.Errorf("filter = %v, want %v", filter, tt.wantFilter)
+			}
+			if !reflect.DeepEqual(update, tt.wantUpdate) {
+				t.Errorf("update = %v, want %v", update, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func TestCheckTargets(t *testing.T) {
+	revokedAt := time.Now()
+	keys := map[string]model.APIKey{
+		"admin":   {ID: "admin", Scopes: []string{model.ScopeAdmin}},
+		"writer":  {ID: "writer", Scopes: []string{model.ScopeMessagesWrite}},
+		"revoked": {ID: "revoked", Scopes: []string{model.ScopeAdmin}, RevokedAt: &revokedAt},
+	}
+	prev := getAPIKey
+	getAPIKey = func(id string) (model.APIKey, error) {
+		key, ok := keys[id]
+		if !ok {
+			return model.APIKey{}, apikey.ErrNotFound
+		}
+		return key, nil
+	}
+	t.Cleanup(func() { getAPIKey = prev })
+
+	message := model.Message{BridgeID: "general"}
+	tests := []struct {
+		name      string
+		schedule  model.Schedule
+		wantErr   error
+		wantOther bool
+	}{
+		{name: "転送先の指定なし", schedule: model.Schedule{APIKeyID: "writer"}},
+		{name: "投稿先のブリッジ", schedule: model.Schedule{APIKeyID: "writer", BridgeIDs: []string{"general"}}},
+		{name: "admin のキーは他のブリッジに転送できる", schedule: model.Schedule{APIKeyID: "admin", BridgeIDs: []string{"general", "staff"}}},
+		{name: "admin 以外のキー", schedule: model.Schedule{APIKeyID: "writer", BridgeIDs: []string{"staff"}}, wantErr: errTargetNotAllowed},
+		{name: "失効したキー", schedule: model.Schedule{APIKeyID: "revoked", BridgeIDs: []string{"staff"}}, wantErr: errTargetNotAllowed},
+		{name: "削除されたキー", schedule: model.Schedule{APIKeyID: "deleted", BridgeIDs: []string{"staff"}}, wantErr: errTargetNotAllowed},
+		{name: "キーが記録されていない", schedule: model.Schedule{BridgeIDs: []string{"staff"}}, wantErr: errTargetNotAllowed},
+		{name: "存在しないブリッジ", schedule: model.Schedule{APIKeyID: "admin", BridgeIDs: []string{"unknown"}}, wantOther: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTargets(tt.schedule, message)
+			switch {
+			case tt.wantOther:
+				if err == nil || errors.Is(err, errTargetNotAllowed) {
+					t.Errorf("checkTargets() error = %v, want other error", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("checkTargets() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
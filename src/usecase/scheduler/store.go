@@ -0,0 +1,186 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Create は予約投稿を登録します。At（1回のみ）か Cron（繰り返し）のどちらかを指定してください。
+func Create(schedule model.Schedule) (model.Schedule, error) {
+	if collection == nil {
+		return model.Schedule{}, ErrNotInitialized
+	}
+
+	now := time.Now()
+	switch {
+	case schedule.At != nil && schedule.Cron != "":
+		return model.Schedule{}, fmt.Errorf("%w: at と cron は同時に指定できません", ErrInvalid)
+	case schedule.At != nil:
+		if !schedule.At.After(now) {
+			return model.Schedule{}, fmt.Errorf("%w: at には未来の日時を指定してください", ErrInvalid)
+		}
+		if schedule.Timezone != "" {
+			return model.Schedule{}, fmt.Errorf("%w: timezone は cron と合わせて指定してください", ErrInvalid)
+		}
+		at := schedule.At.UTC()
+		schedule.At = &at
+		schedule.NextRunAt = &at
+	case schedule.Cron != "":
+		next, err := nextRun(schedule, now)
+		if err != nil {
+			return model.Schedule{}, err
+		}
+		schedule.NextRunAt = &next
+	default:
+		return model.Schedule{}, fmt.Errorf("%w: at か cron のどちらかを指定してください", ErrInvalid)
+	}
+
+	schedule.ID = primitive.NewObjectID().Hex()
+	schedule.Content.ID = primitive.NewObjectID()
+	schedule.Status = model.ScheduleActive
+	schedule.RunCount = 0
+	schedule.LastRunAt = nil
+	schedule.LastError = ""
+	schedule.Failures = 0
+	schedule.CreatedAt = now
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := collection.InsertOne(ctx, schedule); err != nil {
+		return model.Schedule{}, err
+	}
+	return schedule, nil
+}
+
+// Get は予約投稿を返します。
+func Get(id string) (model.Schedule, error) {
+	if collection == nil {
+		return model.Schedule{}, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var schedule model.Schedule
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		return model.Schedule{}, ErrNotFound
+	}
+	return schedule, err
+}
+
+// List は予約投稿を新しい順に返します。integrationID・status を指定した場合は絞り込みます。
+func List(integrationID, status string) ([]model.Schedule, error) {
+	if collection == nil {
+		return nil, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if integrationID != "" {
+		filter["integrationId"] = integrationID
+	}
+	if status != "" {
+		filter["status"] = status
+	}
+	cur, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	schedules := []model.Schedule{}
+	err = cur.All(ctx, &schedules)
+	return schedules, err
+}
+
+// Pause は実行待ちの予約投稿を一時停止します。
+func Pause(id string) (model.Schedule, error) {
+	return transition(id, []string{model.ScheduleActive}, bson.M{"$set": bson.M{"status": model.SchedulePaused}})
+}
+
+// Resume は一時停止中の予約投稿を再開します。
+// 繰り返しの場合は現在時刻から次回の投稿日時を計算し、1回のみで投稿日時を過ぎている場合はすぐに投稿します（再試行の回数は数え直します）。
+func Resume(id string) (model.Schedule, error) {
+	schedule, err := Get(id)
+	if err != nil {
+		return model.Schedule{}, err
+	}
+	next := schedule.At
+	if schedule.Cron != "" {
+		t, err := nextRun(schedule, time.Now())
+		if err != nil {
+			return model.Schedule{}, err
+		}
+		next = &t
+	}
+	update := bson.M{"$set": bson.M{"status": model.ScheduleActive, "nextRunAt": next, "failures": 0}}
+	return transition(id, []string{model.SchedulePaused}, update)
+}
+
+// Cancel は予約投稿を取り消します。取り消した予約投稿は再開できません。
+func Cancel(id string) (model.Schedule, error) {
+	update := bson.M{"$set": bson.M{"status": model.ScheduleCanceled}, "$unset": bson.M{"nextRunAt": ""}}
+	return transition(id, []string{model.ScheduleActive, model.SchedulePaused}, update)
+}
+
+// CancelByIntegration は連携の予約投稿をすべて取り消します。
+func CancelByIntegration(integrationID string) error {
+	if collection == nil {
+		return ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"integrationId": integrationID, "status": bson.M{"$in": bson.A{model.ScheduleActive, model.SchedulePaused}}}
+	update := bson.M{"$set": bson.M{"status": model.ScheduleCanceled}, "$unset": bson.M{"nextRunAt": ""}}
+	_, err := collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// 状態が from のいずれかの場合のみ更新し、更新後の予約投稿を返す
+func transition(id string, from []string, update bson.M) (model.Schedule, error) {
+	if collection == nil {
+		return model.Schedule{}, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var schedule model.Schedule
+	filter := bson.M{"_id": id, "status": bson.M{"$in": from}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&schedule)
+	if err == mongo.ErrNoDocuments {
+		if _, err := Get(id); err != nil {
+			return model.Schedule{}, err
+		}
+		return model.Schedule{}, ErrInvalidState
+	}
+	return schedule, err
+}
+
+// 繰り返しの予約投稿の after より後の投稿日時を返す
+func nextRun(schedule model.Schedule, after time.Time) (time.Time, error) {
+	spec, err := parseCron(schedule.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	loc, err := location(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: タイムゾーンが正しくありません: %v", ErrInvalid, err)
+	}
+	next, ok := spec.next(after, loc)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: cron 式に一致する日時がありません", ErrInvalid)
+	}
+	return next.UTC(), nil
+}
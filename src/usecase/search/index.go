@@ -0,0 +1,82 @@
+package search
+
+// このパッケージはメッセージの全文検索を担当します。
+// MongoDB のテキストインデックスは日本語を分かち書きしないため、本文を英数字の単語と
+// 日本語の1文字・2文字（N-gram）に分割したトークンを searchText に保存し、テキストインデックスで検索します。
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// メッセージの保存先
+var collection *mongo.Collection
+
+// Init はテキストインデックスを作成し、検索用トークンのないメッセージのトークンをバックグラウンドで作成します。
+func Init(coll *mongo.Collection) error {
+	collection = coll
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "content.text", Value: "text"}, {Key: "searchText", Value: "text"}},
+		// 語幹の処理やストップワードの除外をしない
+		Options: options.Index().SetName("search_text").SetDefaultLanguage("none"),
+	})
+	if err != nil {
+		return err
+	}
+
+	go backfill()
+	return nil
+}
+
+// Index はメッセージの検索用トークンを保存します。メッセージの登録時と本文の編集時に呼び出します。
+func Index(msg model.Message) {
+	if collection == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": msg.ID}, bson.M{"$set": bson.M{"searchText": SearchText(msg.Content.Text)}}); err != nil {
+		log.Printf("検索用トークンの保存に失敗しました (message: %s): %v", msg.ID.Hex(), err)
+	}
+}
+
+// SearchText は本文から検索用トークンを空白区切りにした文字列を作成します。
+func SearchText(text string) string {
+	return strings.Join(Tokens(text), " ")
+}
+
+// 検索用トークンのないメッセージ（検索機能の追加前のメッセージなど）のトークンを作成する
+func backfill() {
+	ctx := context.Background()
+
+	cur, err := collection.Find(ctx, bson.M{"searchText": bson.M{"$exists": false}}, options.Find().SetProjection(bson.M{"content.text": 1}))
+	if err != nil {
+		log.Printf("検索用トークンの作成に失敗しました: %v", err)
+		return
+	}
+	defer cur.Close(ctx)
+
+	count := 0
+	for cur.Next(ctx) {
+		var msg model.Message
+		if err := cur.Decode(&msg); err != nil {
+			continue
+		}
+		Index(msg)
+		count++
+	}
+	if count > 0 {
+		log.Printf("%d件のメッセージの検索用トークンを作成しました", count)
+	}
+}
@@ -0,0 +1,193 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ハイライトの前後に表示する文字数
+const snippetContext = 40
+
+// isCJK は分かち書きされない文字（漢字・ひらがな・カタカナ・ハングル）かを返します。
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 'ー'
+}
+
+// 文字列を「英数字の単語」と「分かち書きされない文字の並び」に分割する
+func runs(text string) (words []string, cjk []string) {
+	var word, kanji []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+		if len(kanji) > 0 {
+			cjk = append(cjk, string(kanji))
+			kanji = kanji[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			kanji = append(kanji, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(kanji) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words, cjk
+}
+
+// Tokens は検索用のトークンを返します。
+// 英数字は単語ごと、日本語などの分かち書きされない文字は1文字（ユニグラム）と2文字（バイグラム）に分割します。
+func Tokens(text string) []string {
+	words, cjk := runs(text)
+
+	seen := map[string]bool{}
+	tokens := []string{}
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, word := range words {
+		add(word)
+	}
+	for _, run := range cjk {
+		chars := []rune(run)
+		for i := range chars {
+			add(string(chars[i]))
+			if i+1 < len(chars) {
+				add(string(chars[i : i+2]))
+			}
+		}
+	}
+	return tokens
+}
+
+// queryTokens は検索語に含まれるトークンを返します。
+// 分かち書きされない文字の並びは、1文字の場合はユニグラム、2文字以上の場合はバイグラムにします。
+func queryTokens(term string) []string {
+	words, cjk := runs(term)
+	tokens := append([]string{}, words...)
+	for _, run := range cjk {
+		chars := []rune(run)
+		if len(chars) == 1 {
+			tokens = append(tokens, run)
+			continue
+		}
+		for i := 0; i+1 < len(chars); i++ {
+			tokens = append(tokens, string(chars[i:i+2]))
+		}
+	}
+	return tokens
+}
+
+// Terms は検索文字列を空白で区切った検索語を返します。
+func Terms(query string) []string {
+	return strings.Fields(query)
+}
+
+// TextSearch は MongoDB の $text 検索に指定する文字列を返します。
+// 全てのトークンをフレーズ（"..."）にすることで、全てを含むメッセージのみを検索します。
+func TextSearch(terms []string) string {
+	phrases := []string{}
+	for _, term := range terms {
+		for _, token := range queryTokens(term) {
+			phrases = append(phrases, `"`+strings.ReplaceAll(token, `"`, "")+`"`)
+		}
+	}
+	return strings.Join(phrases, " ")
+}
+
+// Match は本文が全ての検索語を含むかを返します（大文字・小文字は区別しません）。
+// バイグラムでの検索は語順を考慮しないため、検索結果をこの関数で絞り込みます。
+func Match(text string, terms []string) bool {
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if !strings.Contains(lower, strings.ToLower(term)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Highlight は最初に一致した箇所の前後を切り出し、一致した部分を <mark> で囲んだ HTML を返します。
+func Highlight(text string, terms []string) string {
+	chars := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	// ToLower で文字数が変わる場合は位置を対応付けられないため、ハイライトしない
+	if len(lower) != len(chars) {
+		return html.EscapeString(truncate(text, snippetContext*2))
+	}
+
+	// 一致した範囲（文字単位）
+	marked := make([]bool, len(chars))
+	first := -1
+	for _, term := range terms {
+		t := []rune(strings.ToLower(term))
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != string(t) {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return html.EscapeString(truncate(text, snippetContext*2))
+	}
+
+	start := max(first-snippetContext, 0)
+	end := min(first+snippetContext*2, len(chars))
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	inMark := false
+	for i := start; i < end; i++ {
+		if marked[i] != inMark {
+			if marked[i] {
+				b.WriteString("<mark>")
+			} else {
+				b.WriteString("</mark>")
+			}
+			inMark = marked[i]
+		}
+		b.WriteString(html.EscapeString(string(chars[i])))
+	}
+	if inMark {
+		b.WriteString("</mark>")
+	}
+	if end < len(chars) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func truncate(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n]) + "…"
+}
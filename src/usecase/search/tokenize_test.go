@@ -0,0 +1,109 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "空文字", text: "", want: []string{}},
+		{name: "英単語は小文字にする", text: "Hello, World!", want: []string{"hello", "world"}},
+		{name: "重複は除く", text: "go Go GO", want: []string{"go"}},
+		{name: "漢字はユニグラムとバイグラム", text: "東京都", want: []string{"東", "東京", "京", "京都", "都"}},
+		{name: "英数字と漢字の境界", text: "abc漢字", want: []string{"abc", "漢", "漢字", "字"}},
+		{
+			name: "英語と日本語の混在",
+			text: "Hello 世界ですね World",
+			want: []string{"hello", "world", "世", "世界", "界", "界で", "で", "です", "す", "すね", "ね"},
+		},
+		{name: "長音符", text: "データ", want: []string{"デ", "デー", "ー", "ータ", "タ"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokens(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		terms []string
+		want  string
+	}{
+		{name: "英単語", terms: []string{"Go", "Lang"}, want: `"go" "lang"`},
+		{name: "1文字の漢字", terms: []string{"東"}, want: `"東"`},
+		{name: "3文字の漢字はバイグラム", terms: []string{"東京都"}, want: `"東京" "京都"`},
+		{name: "引用符は区切りとして扱う", terms: []string{`a"b`}, want: `"a" "b"`},
+		{name: "記号のみ", terms: []string{"!!!"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TextSearch(tt.terms); got != tt.want {
+				t.Errorf("TextSearch(%q) = %q, want %q", tt.terms, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		text  string
+		terms []string
+		want  bool
+	}{
+		{text: "Hello World", terms: []string{"world", "HELLO"}, want: true},
+		{text: "Hello World", terms: []string{"world", "xyz"}, want: false},
+		{text: "東京都に行く", terms: []string{"京都"}, want: true},
+		// バイグラムは一致しても語順が違うものは除く
+		{text: "都京東", terms: []string{"東京"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := Match(tt.text, tt.terms); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.text, tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("a", 50) + "target" + strings.Repeat("b", 100)
+
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{name: "大文字・小文字を区別しない", text: "Hello World", terms: []string{"world"}, want: "Hello <mark>World</mark>"},
+		{name: "HTML をエスケープする", text: "<b>Go</b> is fun", terms: []string{"go"}, want: "&lt;b&gt;<mark>Go</mark>&lt;/b&gt; is fun"},
+		{name: "隣接する検索語はまとめて囲む", text: "東京都庁", terms: []string{"東京", "都"}, want: "<mark>東京都</mark>庁"},
+		{name: "複数箇所", text: "go and go", terms: []string{"go"}, want: "<mark>go</mark> and <mark>go</mark>"},
+		{name: "一致しない", text: "abc", terms: []string{"x"}, want: "abc"},
+		{
+			name:  "前後を切り出す",
+			text:  long,
+			terms: []string{"TARGET"},
+			want:  "…" + strings.Repeat("a", 40) + "<mark>target</mark>" + strings.Repeat("b", 74) + "…",
+		},
+		{name: "ASCII 以外の大文字", text: "İstanbul <x>", terms: []string{"STAN"}, want: "İ<mark>stan</mark>bul &lt;x&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.text, tt.terms); got != tt.want {
+				t.Errorf("Highlight(%q, %q) = %q, want %q", tt.text, tt.terms, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package signedurl
+
+// このパッケージは LinkGate 経由で配信するファイルURLの署名を扱います。
+// Telegram や Mattermost のファイルは LinkGate が代理で取得して配信するため、
+// 署名のないURLを受け付けると任意のファイルIDを取得できるプロキシになってしまいます。
+// 転送時に有効期限付きの署名をURLに付け、配信時に検証します。
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 署名付きURLの有効期間
+// 他のプラットフォームに転送したメッセージの画像が表示され続けるよう長めに設定する
+const Lifetime = 30 * 24 * time.Hour
+
+// 署名に使用するシークレット。未設定の場合は起動ごとにランダムに生成します
+// （再起動すると発行済みのURLは無効になるため、本番環境では設定してください）。
+var secret = secretFromEnv()
+
+// Query は kind と id に対する有効期限付きの署名をクエリ文字列で返します（例: exp=...&sig=...）。
+// kind はURLの種類（"telegram-file" など）で、別の種類のURLに署名を流用できないようにします。
+func Query(kind, id string) string {
+	exp := strconv.FormatInt(time.Now().Add(Lifetime).Unix(), 10)
+	values := url.Values{}
+	values.Set("exp", exp)
+	values.Set("sig", sign(kind, id, exp))
+	return values.Encode()
+}
+
+// Verify は Query で発行した署名を検証します。期限切れの場合も false を返します。
+func Verify(kind, id, exp, sig string) bool {
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(sign(kind, id, exp)))
+}
+
+func sign(kind, id, exp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(kind + "\x00" + id + "\x00" + exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func secretFromEnv() []byte {
+	if secret := os.Getenv("LINKGATE_URL_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
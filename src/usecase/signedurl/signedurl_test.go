@@ -0,0 +1,42 @@
+package signedurl
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	query, err := url.ParseQuery(Query("telegram-file", "file-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, sig := query.Get("exp"), query.Get("sig")
+	expired := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+
+	tests := []struct {
+		name string
+		kind string
+		id   string
+		exp  string
+		sig  string
+		want bool
+	}{
+		{name: "有効な署名", kind: "telegram-file", id: "file-1", exp: exp, sig: sig, want: true},
+		{name: "別のID", kind: "telegram-file", id: "file-2", exp: exp, sig: sig, want: false},
+		{name: "別の種類", kind: "mattermost-file", id: "file-1", exp: exp, sig: sig, want: false},
+		{name: "有効期限の改ざん", kind: "telegram-file", id: "file-1", exp: exp + "0", sig: sig, want: false},
+		{name: "期限切れ", kind: "telegram-file", id: "file-1", exp: expired, sig: sign("telegram-file", "file-1", expired), want: false},
+		{name: "署名なし", kind: "telegram-file", id: "file-1", exp: exp, sig: "", want: false},
+		{name: "不正な有効期限", kind: "telegram-file", id: "file-1", exp: "abc", sig: sig, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.kind, tt.id, tt.exp, tt.sig); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// conversations.history / conversations.replies の1ページあたりの取得件数
+const historyPageSize = 200
+
+// BackfillOptions は履歴取り込みの条件です
+type BackfillOptions struct {
+	// ワークスペースID（空の場合は SLACK_BOT_TOKEN のワークスペース）
+	TeamID string
+	// 取り込むチャンネルID
+	ChannelID string
+	// 取り込む期間（ゼロ値の場合は制限なし）
+	Oldest time.Time
+	Latest time.Time
+	// スレッドの返信も取り込むか
+	IncludeThreads bool
+}
+
+// MessageFilter は GET /slack/messages の検索条件です
+type MessageFilter struct {
+	// チャンネルID
+	ChannelID string
+	// 期間（ゼロ値の場合は制限なし）
+	Since time.Time
+	Until time.Time
+}
+
+// isUserMessage はユーザーの通常投稿として扱うサブタイプかを判定します。
+// 編集・削除・参加通知などのサブタイプは保存しません。
+func isUserMessage(subType string) bool {
+	switch subType {
+	case "", "thread_broadcast", "file_share":
+		return true
+	default:
+		return false
+	}
+}
+
+// ユーザー名の優先順位: DisplayName > RealName > Name > ユーザーID
+func slackUserName(user *slack.User, fallback string) string {
+	if user.Profile.DisplayName != "" {
+		return user.Profile.DisplayName
+	}
+	if user.Profile.RealName != "" {
+		return user.Profile.RealName
+	}
+	if user.Name != "" {
+		return user.Name
+	}
+	return fallback
+}
+
+// Backfill は conversations.history でチャンネルの過去ログを取得し、model.Message として保存します。
+// 取り込んだメッセージは他プラットフォームへは転送されません。
+// 既に保存済みのメッセージ（同じ ts）は保存しないため、何度実行しても重複しません。戻り値は新しく保存した件数です。
+func (h *SlackHandler) Backfill(ctx context.Context, opts BackfillOptions) (int, error) {
+	api := h.clientForTeam(opts.TeamID)
+	if api == nil {
+		return 0, fmt.Errorf("ワークスペース %s のトークンが見つかりません", opts.TeamID)
+	}
+
+	users := map[string]*slack.User{}
+	saved := 0
+	cursor := ""
+	for {
+		resp, err := api.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{
+			ChannelID: opts.ChannelID,
+			Cursor:    cursor,
+			Oldest:    formatSlackTimestamp(opts.Oldest),
+			Latest:    formatSlackTimestamp(opts.Latest),
+			Limit:     historyPageSize,
+			Inclusive: true,
+		})
+		if err != nil {
+			return saved, fmt.Errorf("conversations.history の取得に失敗しました: %w", err)
+		}
+
+		for _, msg := range resp.Messages {
+			if h.saveHistoryMessage(ctx, api, users, opts, msg) {
+				saved++
+			}
+
+			// スレッドの親メッセージであれば返信も取り込む
+			if opts.IncludeThreads && msg.ReplyCount > 0 {
+				n, err := h.backfillReplies(ctx, api, users, opts, msg.Timestamp)
+				saved += n
+				if err != nil {
+					return saved, err
+				}
+			}
+		}
+
+		cursor = resp.ResponseMetaData.NextCursor
+		if !resp.HasMore || cursor == "" {
+			break
+		}
+	}
+
+	log.Printf("Slack履歴の取り込みが完了しました (channel: %s, %d件)", opts.ChannelID, saved)
+	return saved, nil
+}
+
+// スレッドの返信を取り込む
+func (h *SlackHandler) backfillReplies(ctx context.Context, api *slack.Client, users map[string]*slack.User, opts BackfillOptions, threadTS string) (int, error) {
+	saved := 0
+	cursor := ""
+	for {
+		msgs, hasMore, nextCursor, err := api.GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{
+			ChannelID: opts.ChannelID,
+			Timestamp: threadTS,
+			Cursor:    cursor,
+			Limit:     historyPageSize,
+		})
+		if err != nil {
+			return saved, fmt.Errorf("conversations.replies の取得に失敗しました: %w", err)
+		}
+
+		for _, msg := range msgs {
+			// 親メッセージは history 側で保存済み
+			if msg.Timestamp == threadTS {
+				continue
+			}
+			if h.saveHistoryMessage(ctx, api, users, opts, msg) {
+				saved++
+			}
+		}
+
+		cursor = nextCursor
+		if !hasMore || cursor == "" {
+			return saved, nil
+		}
+	}
+}
+
+// 取得した履歴メッセージを1件保存し、新しく保存したかを返す
+func (h *SlackHandler) saveHistoryMessage(ctx context.Context, api *slack.Client, users map[string]*slack.User, opts BackfillOptions, msg slack.Message) bool {
+	if msg.BotID != "" || msg.User == "" || !isUserMessage(msg.SubType) {
+		return false
+	}
+
+	// 同じユーザーの情報は一度だけ取得する
+	user, ok := users[msg.User]
+	if !ok {
+		var err error
+		user, err = api.GetUserInfoContext(ctx, msg.User)
+		if err != nil {
+			log.Printf("Failed to get user info: %v", err)
+		}
+		users[msg.User] = user
+	}
+
+	var message model.Message
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = msg.User
+	message.User.Platform = model.PlatformSlack
+	message.User.Name = msg.User
+	if user != nil {
+		message.User.Name = slackUserName(user, msg.User)
+		message.User.IconUrl = user.Profile.Image72
+	}
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = msg.Text
+	for _, file := range msg.Files {
+		message.Content.Attachments = append(message.Content.Attachments, model.Attachment{
+			Type: strings.SplitN(file.Mimetype, "/", 2)[0],
+			URL:  file.Permalink,
+		})
+	}
+	message.ChannelID = opts.ChannelID
+	message.WorkspaceID = opts.TeamID
+	message.ExternalID = msg.Timestamp
+	if msg.ThreadTimestamp != "" && msg.ThreadTimestamp != msg.Timestamp {
+		message.ThreadID = msg.ThreadTimestamp
+	}
+	if b, ok := bridge.Find(model.PlatformSlack, opts.ChannelID); ok {
+		message.BridgeID = b.ID
+	}
+	message.Imported = true
+	message.CreatedAt = parseSlackTimestamp(msg.Timestamp)
+
+	// ts が同じメッセージが保存済みの場合は何もしない
+	filter := bson.M{
+		"user.platform": model.PlatformSlack,
+		"channelId":     opts.ChannelID,
+		"externalId":    msg.Timestamp,
+	}
+	update := bson.M{"$setOnInsert": message}
+	result, err := h.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		log.Printf("Slack履歴メッセージの保存に失敗しました (ts: %s): %v", msg.Timestamp, err)
+		return false
+	}
+	// 保存済みのメッセージに一致した場合は件数に含めない
+	return result.UpsertedCount == 1
+}
+
+// GetSlackMessages は Slack から投稿されたメッセージを条件に応じて取得します。
+func (h *SlackHandler) GetSlackMessages(filter MessageFilter) ([]model.Message, error) {
+	// 新しいコンテキストを作成（タイムアウトを設定）
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	query := bson.M{"user.platform": model.PlatformSlack}
+	if filter.ChannelID != "" {
+		query["channelId"] = filter.ChannelID
+	}
+	createdAt := bson.M{}
+	if !filter.Since.IsZero() {
+		createdAt["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		createdAt["$lt"] = filter.Until
+	}
+	if len(createdAt) > 0 {
+		query["createdAt"] = createdAt
+	}
+
+	cursor, err := h.collection.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	messages := []model.Message{}
+	if err = cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Slack の ts（"1234567890.123456"）を time.Time に変換する
+func parseSlackTimestamp(ts string) time.Time {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	us, _ := strconv.ParseInt(frac, 10, 64)
+	return time.Unix(s, us*int64(time.Microsecond))
+}
+
+// time.Time を Slack の ts 形式に変換する（ゼロ値の場合は空文字）
+func formatSlackTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
@@ -0,0 +1,200 @@
+package slack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/slack-go/slack"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// インストール時に要求する Bot のスコープ（SLACK_OAUTH_SCOPES で上書きできます）
+const defaultOAuthScopes = "channels:history,channels:read,groups:history,groups:read,chat:write,chat:write.customize,users:read"
+
+// CSRF 対策の state を保存する Cookie 名
+const oauthStateCookie = "slack_oauth_state"
+
+// インストール情報が見つからなかったチームを再確認するまでの時間
+const missCacheDuration = time.Minute
+
+// Installation は OAuth でインストールされたワークスペースの情報です
+type Installation struct {
+	// チームID
+	TeamID string `bson:"_id" json:"teamId"`
+	// チーム名
+	TeamName string `bson:"teamName" json:"teamName"`
+	// アプリID
+	AppID string `bson:"appId" json:"appId"`
+	// Bot ユーザーID
+	BotUserID string `bson:"botUserId" json:"botUserId"`
+	// Bot トークン
+	BotToken string `bson:"botToken" json:"-"`
+	// 付与されたスコープ
+	Scope string `bson:"scope" json:"scope"`
+	// インストール日時
+	InstalledAt time.Time `bson:"installedAt" json:"installedAt"`
+}
+
+// HandleInstall は Slack の OAuth 認可画面へリダイレクトします。
+func (h *SlackHandler) HandleInstall(c *gin.Context) {
+	clientID := os.Getenv("SLACK_CLIENT_ID")
+	if clientID == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Slack の OAuth が設定されていません"})
+		return
+	}
+
+	state, err := generateState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "state の生成に失敗しました", "details": err.Error()})
+		return
+	}
+	c.SetCookie(oauthStateCookie, state, 600, "/slack", "", c.Request.TLS != nil, true)
+
+	scopes := os.Getenv("SLACK_OAUTH_SCOPES")
+	if scopes == "" {
+		scopes = defaultOAuthScopes
+	}
+
+	query := url.Values{
+		"client_id":    {clientID},
+		"scope":        {scopes},
+		"redirect_uri": {os.Getenv("SLACK_REDIRECT_URL")},
+		"state":        {state},
+	}
+	c.Redirect(http.StatusFound, "https://slack.com/oauth/v2/authorize?"+query.Encode())
+}
+
+// HandleOAuthRedirect は認可後のリダイレクトを受け取り、Bot トークンを MongoDB に保存します。
+func (h *SlackHandler) HandleOAuthRedirect(c *gin.Context) {
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "インストールがキャンセルされました", "details": errParam})
+		return
+	}
+
+	// state を検証
+	state, err := c.Cookie(oauthStateCookie)
+	if err != nil || state == "" || state != c.Query("state") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state が一致しません"})
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, "/slack", "", c.Request.TLS != nil, true)
+
+	resp, err := slack.GetOAuthV2Response(
+		&http.Client{Timeout: 10 * time.Second},
+		os.Getenv("SLACK_CLIENT_ID"),
+		os.Getenv("SLACK_CLIENT_SECRET"),
+		c.Query("code"),
+		os.Getenv("SLACK_REDIRECT_URL"),
+	)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "トークンの取得に失敗しました", "details": err.Error()})
+		return
+	}
+
+	installation := Installation{
+		TeamID:      resp.Team.ID,
+		TeamName:    resp.Team.Name,
+		AppID:       resp.AppID,
+		BotUserID:   resp.BotUserID,
+		BotToken:    resp.AccessToken,
+		Scope:       resp.Scope,
+		InstalledAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = h.installations.ReplaceOne(ctx, bson.M{"_id": installation.TeamID}, installation, options.Replace().SetUpsert(true))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "インストール情報の保存に失敗しました", "details": err.Error()})
+		return
+	}
+
+	// 古いクライアントのキャッシュを破棄
+	h.mu.Lock()
+	delete(h.clients, installation.TeamID)
+	delete(h.misses, installation.TeamID)
+	h.mu.Unlock()
+
+	log.Printf("Slack ワークスペースがインストールされました: %s (%s)", installation.TeamName, installation.TeamID)
+	c.JSON(http.StatusOK, gin.H{"message": "Slack ワークスペースのインストールが完了しました", "team": installation})
+}
+
+// clientForTeam はチームIDに対応する Slack クライアントを返します。
+// チームIDが空か SLACK_BOT_TOKEN のワークスペースの場合は SLACK_BOT_TOKEN のクライアントを返します。
+// インストール情報がない場合は他のワークスペースのトークンで送信しないよう nil を返し、
+// 見つからなかったことを一定時間キャッシュします。
+func (h *SlackHandler) clientForTeam(teamID string) *slack.Client {
+	if teamID == "" || teamID == h.defaultTeamID {
+		return h.api
+	}
+
+	h.mu.RLock()
+	api, ok := h.clients[teamID]
+	missUntil, missed := h.misses[teamID]
+	h.mu.RUnlock()
+	if ok {
+		return api
+	}
+	if missed && time.Now().Before(missUntil) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var installation Installation
+	err := h.installations.FindOne(ctx, bson.M{"_id": teamID}).Decode(&installation)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Slackインストール情報の取得に失敗しました: %v", err)
+		}
+		h.mu.Lock()
+		h.misses[teamID] = time.Now().Add(missCacheDuration)
+		h.mu.Unlock()
+		return nil
+	}
+
+	api = slack.New(installation.BotToken)
+	h.mu.Lock()
+	h.clients[teamID] = api
+	delete(h.misses, teamID)
+	h.mu.Unlock()
+	return api
+}
+
+// deleteInstallation はアンインストールされたワークスペースのトークンを削除します。
+func (h *SlackHandler) deleteInstallation(teamID string) {
+	if teamID == "" {
+		return
+	}
+
+	h.mu.Lock()
+	delete(h.clients, teamID)
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := h.installations.DeleteOne(ctx, bson.M{"_id": teamID}); err != nil {
+		log.Printf("Slackインストール情報の削除に失敗しました: %v", err)
+		return
+	}
+	log.Printf("Slack ワークスペースのインストール情報を削除しました: %s", teamID)
+}
+
+// ランダムな state を生成する
+func generateState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,103 @@
+package slack
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// 再接続時の待機時間
+const (
+	socketModeInitialBackoff = 1 * time.Second
+	socketModeMaxBackoff     = 1 * time.Minute
+)
+
+// IsSocketMode は Slack との接続方式に Socket Mode が選択されているかを返します。
+// 環境変数 SLACK_CONNECTION_MODE に "socket" を設定すると、公開エンドポイント /slack/events の代わりに
+// アプリレベルトークン（SLACK_APP_TOKEN）を使った WebSocket 接続でイベントを受信します。
+func IsSocketMode() bool {
+	return os.Getenv("SLACK_CONNECTION_MODE") == "socket"
+}
+
+// RunSocketMode は Socket Mode でSlackに接続し、受信したイベントをDBへ保存します。
+// 接続が切れた場合や接続に失敗した場合は、待機時間を延ばしながら自動で再接続します。
+func (h *SlackHandler) RunSocketMode() {
+	appToken := os.Getenv("SLACK_APP_TOKEN")
+	if appToken == "" {
+		log.Println("SLACK_APP_TOKEN が設定されていないため Socket Mode を開始できません")
+		return
+	}
+
+	// 接続にはアプリレベルトークンのみを使用する（全ワークスペースのイベントを受信）
+	api := slack.New("", slack.OptionAppLevelToken(appToken))
+
+	backoff := socketModeInitialBackoff
+	for {
+		client := socketmode.New(api)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// イベントの処理
+		connected := make(chan struct{}, 1)
+		go h.handleSocketModeEvents(ctx, client, connected)
+
+		err := client.RunContext(ctx)
+		cancel()
+
+		select {
+		case <-connected:
+			// 一度でも接続できていれば待機時間をリセット
+			backoff = socketModeInitialBackoff
+		default:
+		}
+
+		log.Printf("Slack Socket Mode の接続が終了しました: %v (%v後に再接続します)", err, backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > socketModeMaxBackoff {
+			backoff = socketModeMaxBackoff
+		}
+	}
+}
+
+// Socket Mode のイベントを受け取り、Events API と同じ経路で処理する
+func (h *SlackHandler) handleSocketModeEvents(ctx context.Context, client *socketmode.Client, connected chan<- struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt, ok := <-client.Events:
+			if !ok {
+				return
+			}
+
+			switch evt.Type {
+			case socketmode.EventTypeConnecting:
+				log.Println("Slack Socket Mode に接続中...")
+			case socketmode.EventTypeConnected:
+				log.Println("🔍 Slack Socket Mode に接続しました")
+				select {
+				case connected <- struct{}{}:
+				default:
+				}
+			case socketmode.EventTypeConnectionError:
+				log.Printf("Slack Socket Mode の接続エラー: %v", evt.Data)
+			case socketmode.EventTypeInvalidAuth:
+				log.Println("Slack Socket Mode の認証に失敗しました。SLACK_APP_TOKEN を確認してください")
+			case socketmode.EventTypeEventsAPI:
+				if evt.Request == nil {
+					continue
+				}
+				// 受信したことを先に通知しないと Slack から再送される
+				client.Ack(*evt.Request)
+
+				// ペイロードは Events API の event_callback と同じ形式
+				h.handleSlackEvent(evt.Request.Payload)
+			}
+		}
+	}
+}
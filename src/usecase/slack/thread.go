@@ -0,0 +1,58 @@
+package slack
+
+import (
+	"context"
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"log"
+	"time"
+
+	"github.com/slack-go/slack"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// スレッドタイトルとして使用する親メッセージの最大文字数
+const maxThreadTitleLength = 40
+
+// slackThread は対応関係に記録されたチャンネル内のスレッド（親メッセージの ts）を返します。
+// まだスレッドがない場合は、タイトルを親メッセージとして投稿してスレッドを作成します。
+// 作成に失敗した場合は空文字を返し、メッセージはチャンネルへ直接投稿されます。
+func slackThread(api *slack.Client, link model.ThreadLink, channelID string) string {
+	if ref, ok := thread.Counterpart(link, model.PlatformSlack, channelID); ok {
+		return ref.ThreadID
+	}
+
+	_, ts, err := api.PostMessage(channelID,
+		slack.MsgOptionText("🧵 *"+thread.DisplayTitle(link)+"*", false),
+		slack.MsgOptionUsername("LinkGate"),
+	)
+	if err != nil {
+		log.Printf("Slackスレッドの作成に失敗しました (channel: %s): %v", channelID, err)
+		return ""
+	}
+
+	ref := model.ThreadRef{Platform: model.PlatformSlack, ChannelID: channelID, ThreadID: ts}
+	if err := thread.AddThread(link.ID, ref); err != nil {
+		log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+	}
+	log.Printf("Slackスレッドを作成しました (channel: %s, ts: %s)", channelID, ts)
+	return ts
+}
+
+// threadTitle は保存済みの親メッセージの本文からスレッドのタイトルを作成します。
+func (h *SlackHandler) threadTitle(channelID, threadTS string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var parent model.Message
+	filter := bson.M{"user.platform": model.PlatformSlack, "channelId": channelID, "externalId": threadTS}
+	if err := h.collection.FindOne(ctx, filter).Decode(&parent); err != nil {
+		return ""
+	}
+
+	runes := []rune(parent.Content.Text)
+	if len(runes) > maxThreadTitleLength {
+		return string(runes[:maxThreadTitleLength]) + "…"
+	}
+	return string(runes)
+}
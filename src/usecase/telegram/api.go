@@ -0,0 +1,168 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Bot API への認証に使用する Bot トークン。
+// 環境変数 TELEGRAM_BOT_TOKEN に設定してください。
+var botToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+
+// Bot API のベースURL。テスト用のスタブサーバーを使う場合は TELEGRAM_API_URL で上書きできます。
+var apiURL = defaultString(os.Getenv("TELEGRAM_API_URL"), "https://api.telegram.org")
+
+// getUpdates のロングポーリングに合わせて長めのタイムアウトを設定する
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// Update は Bot API の Update オブジェクトです
+type Update struct {
+	UpdateID int      `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+// Message は Bot API の Message オブジェクトです
+type Message struct {
+	MessageID       int         `json:"message_id"`
+	MessageThreadID int         `json:"message_thread_id,omitempty"`
+	From            *User       `json:"from,omitempty"`
+	Chat            Chat        `json:"chat"`
+	Date            int64       `json:"date"`
+	Text            string      `json:"text,omitempty"`
+	Caption         string      `json:"caption,omitempty"`
+	Photo           []PhotoSize `json:"photo,omitempty"`
+	ReplyToMessage  *Message    `json:"reply_to_message,omitempty"`
+}
+
+// User は Bot API の User オブジェクトです
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// Chat は Bot API の Chat オブジェクトです
+type Chat struct {
+	ID    int64  `json:"id"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
+
+// PhotoSize は Bot API の PhotoSize オブジェクトです
+type PhotoSize struct {
+	FileID   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int    `json:"file_size,omitempty"`
+}
+
+// File は Bot API の File オブジェクトです
+type File struct {
+	FileID   string `json:"file_id"`
+	FilePath string `json:"file_path"`
+}
+
+// UserProfilePhotos は Bot API の UserProfilePhotos オブジェクトです
+type UserProfilePhotos struct {
+	TotalCount int           `json:"total_count"`
+	Photos     [][]PhotoSize `json:"photos"`
+}
+
+// Bot API のレスポンス
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+}
+
+// call は Bot API のメソッドを呼び出し、result を out にデコードします。
+func call(ctx context.Context, method string, params interface{}, out interface{}) error {
+	if botToken == "" {
+		return errors.New("TELEGRAM_BOT_TOKEN が設定されていません")
+	}
+
+	body, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/%s", strings.TrimRight(apiURL, "/"), botToken, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Telegram API の呼び出しに失敗しました (%s): %w", method, transportError(err))
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("Telegram API のレスポンスの解析に失敗しました (status: %d): %w", resp.StatusCode, err)
+	}
+	if !result.OK {
+		return fmt.Errorf("Telegram API からエラーが返されました (%s): %d %s", method, result.ErrorCode, result.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(result.Result, out)
+	}
+	return nil
+}
+
+// downloadFile は Bot API のファイルをダウンロードします。
+// ファイルのURLには Bot トークンが含まれるため、外部には公開せず LinkGate 経由で配信します。
+func downloadFile(ctx context.Context, fileID string) (io.ReadCloser, string, error) {
+	var file File
+	if err := call(ctx, "getFile", map[string]string{"file_id": fileID}, &file); err != nil {
+		return nil, "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/file/bot%s/%s", strings.TrimRight(apiURL, "/"), botToken, file.FilePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", transportError(err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Telegram ファイルの取得に失敗しました: %w", transportError(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("Telegram ファイルの取得に失敗しました: status %d", resp.StatusCode)
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+// 通信エラーから Bot API のURLを取り除く（URLに Bot トークンが含まれるため、ログやレスポンスに残さない）
+func transportError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	if botToken == "" {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), botToken, "<redacted>"))
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
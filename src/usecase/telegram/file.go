@@ -0,0 +1,47 @@
+package telegram
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/usecase/signedurl"
+	"github.com/gin-gonic/gin"
+)
+
+// 署名付きURLの種類
+const fileKind = "telegram-file"
+
+// HandleFile は Telegram のファイルを取得してそのまま返します。
+// Bot API のファイルURLには Bot トークンが含まれるため、他のプラットフォームにはこのエンドポイントのURLを渡します。
+// 任意のファイルを取得できないよう、FileURL で発行した署名付きURLのみ受け付けます。
+func HandleFile(c *gin.Context) {
+	fileID := c.Param("fileId")
+	if !signedurl.Verify(fileKind, fileID, c.Query("exp"), c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "URLの署名が無効か、有効期限が切れています"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	body, contentType, err := downloadFile(ctx, fileID)
+	if err != nil {
+		log.Printf("Telegramファイルの取得に失敗しました: %v", err)
+		c.JSON(http.StatusNotFound, gin.H{"error": "ファイルが見つかりません"})
+		return
+	}
+	defer body.Close()
+
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = "image/jpeg"
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", contentType)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		log.Printf("Telegramファイルの送信に失敗しました: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bot API の呼び出し
+type stubCall struct {
+	method string
+	params map[string]interface{}
+}
+
+// Bot API のスタブサーバー
+type botAPIStub struct {
+	mu    sync.Mutex
+	calls []stubCall
+	// getUpdates で返す Update
+	updates []Update
+}
+
+// calls のうち method の呼び出しのパラメータを返す
+func (s *botAPIStub) called(method string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var params []map[string]interface{}
+	for _, c := range s.calls {
+		if c.method == method {
+			params = append(params, c.params)
+		}
+	}
+	return params
+}
+
+// Bot API のスタブサーバーを起動し、apiURL を差し替える
+func newBotAPIStub(t *testing.T) *botAPIStub {
+	t.Helper()
+	const token = "123:test"
+	stub := &botAPIStub{}
+
+	reply := func(w http.ResponseWriter, result interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot"+token+"/", func(w http.ResponseWriter, r *http.Request) {
+		method := strings.TrimPrefix(r.URL.Path, "/bot"+token+"/")
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+
+		stub.mu.Lock()
+		stub.calls = append(stub.calls, stubCall{method: method, params: params})
+		sent := len(stub.calls)
+		stub.mu.Unlock()
+
+		switch method {
+		case "getFile":
+			if params["file_id"] != "photo-1" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error_code": 400, "description": "Bad Request: invalid file_id"})
+				return
+			}
+			reply(w, File{FileID: "photo-1", FilePath: "photos/file_1.jpg"})
+		case "getUpdates":
+			offset, _ := params["offset"].(float64)
+			updates := []Update{}
+			for _, u := range stub.updates {
+				if u.UpdateID >= int(offset) {
+					updates = append(updates, u)
+				}
+			}
+			reply(w, updates)
+		case "getUserProfilePhotos":
+			reply(w, UserProfilePhotos{TotalCount: 1, Photos: [][]PhotoSize{{{FileID: "avatar-1"}}}})
+		case "sendMessage", "sendPhoto":
+			reply(w, Message{MessageID: 1000 + sent})
+		default:
+			reply(w, true)
+		}
+	})
+	mux.HandleFunc("/file/bot"+token+"/photos/file_1.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png-bytes"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	prevURL, prevToken := apiURL, botToken
+	apiURL, botToken = server.URL, token
+	t.Cleanup(func() { apiURL, botToken = prevURL, prevToken })
+	return stub
+}
+
+func TestHandleFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newBotAPIStub(t)
+
+	signed, err := url.Parse(FileURL("photo-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigned, err := url.Parse(FileURL("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "署名付きURL", path: signed.Path + "?" + signed.RawQuery, wantStatus: http.StatusOK, wantBody: "png-bytes"},
+		{name: "署名なし", path: "/telegram/files/photo-1", wantStatus: http.StatusForbidden},
+		{name: "別のファイルの署名", path: "/telegram/files/photo-1?" + otherSigned.RawQuery, wantStatus: http.StatusForbidden},
+		{name: "期限切れ", path: "/telegram/files/photo-1?exp=1&sig=" + signed.Query().Get("sig"), wantStatus: http.StatusForbidden},
+		{name: "存在しないファイル", path: otherSigned.Path + "?" + otherSigned.RawQuery, wantStatus: http.StatusNotFound},
+	}
+
+	r := gin.New()
+	r.GET("/telegram/files/:fileId", HandleFile)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body: %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHandleFileHidesBotToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// 接続できない Bot API を指定して通信エラーを起こす
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	const token = "123:secret-token"
+	prevURL, prevToken := apiURL, botToken
+	apiURL, botToken = server.URL, token
+	t.Cleanup(func() { apiURL, botToken = prevURL, prevToken })
+
+	_, _, err := downloadFile(context.Background(), "photo-1")
+	if err == nil {
+		t.Fatal("downloadFile() error = nil, want error")
+	}
+	if strings.Contains(err.Error(), token) {
+		t.Errorf("error contains bot token: %v", err)
+	}
+
+	signed, err := url.Parse(FileURL("photo-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.GET("/telegram/files/:fileId", HandleFile)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, signed.Path+"?"+signed.RawQuery, nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if strings.Contains(w.Body.String(), token) {
+		t.Errorf("body contains bot token: %s", w.Body.String())
+	}
+}
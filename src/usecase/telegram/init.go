@@ -0,0 +1,428 @@
+package telegram
+
+// このパッケージは Telegram Bot API を使ったメッセージの送受信を担当します。
+// Webhook（/telegram/webhook）または getUpdates のポーリングでグループのメッセージを受信してDBに保存し、
+// MongoDB に登録された新規メッセージを同じブリッジの Telegram グループへ転送します。
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/delivery"
+	"fuagfuga-2025-LinkGate/src/usecase/signedurl"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 受信方式
+const (
+	// /telegram/webhook で Update を受信する
+	ModeWebhook = "webhook"
+	// getUpdates のロングポーリングで Update を受信する
+	ModePolling = "polling"
+)
+
+// getUpdates のロングポーリングの待機秒数
+const pollingTimeout = 30
+
+// 返信元メッセージとして引用する最大文字数
+const maxQuoteLength = 50
+
+// 受信方式。環境変数 TELEGRAM_MODE に "polling" を設定するとポーリングで受信します。
+var mode = defaultString(os.Getenv("TELEGRAM_MODE"), ModeWebhook)
+
+// Webhook の検証に使うシークレット（setWebhook の secret_token）
+var webhookSecret = os.Getenv("TELEGRAM_WEBHOOK_SECRET")
+
+// 添付ファイルを配信する LinkGate の公開URL（例: https://linkgate.example.com）
+var publicURL = strings.TrimRight(os.Getenv("LINKGATE_PUBLIC_URL"), "/")
+
+var mongoCollection *mongo.Collection
+
+// 受信したメッセージの保存先（テストで差し替える）
+var saveMessage = insertMessage
+
+// InitializeTelegramBot は Telegram 連携を開始します。
+// ポーリング方式の場合は Webhook を解除して getUpdates を繰り返し、
+// Webhook 方式で TELEGRAM_WEBHOOK_URL が設定されている場合は setWebhook を呼び出します。
+func InitializeTelegramBot(collection *mongo.Collection) {
+	if botToken == "" {
+		log.Println("TELEGRAM_BOT_TOKEN が設定されていないため Telegram 連携は無効です")
+		return
+	}
+	mongoCollection = collection
+
+	ctx := context.Background()
+	switch mode {
+	case ModePolling:
+		if err := call(ctx, "deleteWebhook", map[string]bool{"drop_pending_updates": false}, nil); err != nil {
+			log.Printf("Telegram Webhook の解除に失敗しました: %v", err)
+		}
+		log.Println("🔍 Telegram bot started (polling)")
+		poll(ctx)
+	default:
+		if webhookSecret == "" {
+			log.Println("TELEGRAM_WEBHOOK_SECRET が設定されていないため Telegram Webhook は無効です")
+			return
+		}
+		webhookURL := os.Getenv("TELEGRAM_WEBHOOK_URL")
+		if webhookURL == "" {
+			log.Println("🔍 Telegram bot started (webhook)")
+			return
+		}
+		params := map[string]interface{}{
+			"url":             webhookURL,
+			"allowed_updates": []string{"message"},
+			"secret_token":    webhookSecret,
+		}
+		if err := call(ctx, "setWebhook", params, nil); err != nil {
+			log.Printf("Telegram Webhook の設定に失敗しました: %v", err)
+			return
+		}
+		log.Printf("🔍 Telegram bot started (webhook: %s)", webhookURL)
+	}
+}
+
+// IsPollingMode はポーリング方式で受信するかを返します。
+func IsPollingMode() bool {
+	return mode == ModePolling
+}
+
+// WebhookEnabled は /telegram/webhook で Update を受け付けるかを返します。
+// シークレットが未設定の場合は誰でも Update を送れてしまうため受け付けません。
+func WebhookEnabled() bool {
+	return !IsPollingMode() && webhookSecret != ""
+}
+
+// VerifyWebhookSecret は Webhook リクエストのシークレットを検証します。
+// シークレットが未設定の場合は常に false を返します。
+func VerifyWebhookSecret(token string) bool {
+	if webhookSecret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(webhookSecret)) == 1
+}
+
+// getUpdates を繰り返して Update を処理する
+func poll(ctx context.Context) {
+	offset := 0
+	for {
+		next, err := pollOnce(ctx, offset)
+		if err != nil {
+			log.Printf("Telegram の Update 取得に失敗しました: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		offset = next
+	}
+}
+
+// getUpdates を1回呼び出して Update を処理し、次回の offset を返す
+func pollOnce(ctx context.Context, offset int) (int, error) {
+	var updates []Update
+	params := map[string]interface{}{
+		"offset":          offset,
+		"timeout":         pollingTimeout,
+		"allowed_updates": []string{"message"},
+	}
+	if err := call(ctx, "getUpdates", params, &updates); err != nil {
+		return offset, err
+	}
+
+	for _, update := range updates {
+		HandleUpdate(update)
+		offset = update.UpdateID + 1
+	}
+	return offset, nil
+}
+
+// HandleUpdate は受信した Update を処理します。
+func HandleUpdate(update Update) {
+	msg := update.Message
+	if msg == nil || msg.From == nil || msg.From.IsBot {
+		return
+	}
+
+	// グループのメッセージのみを対象とする
+	if msg.Chat.Type != "group" && msg.Chat.Type != "supergroup" {
+		return
+	}
+
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+	b, ok := bridge.Find(model.PlatformTelegram, chatID)
+	if !ok {
+		return
+	}
+
+	SaveTelegramMessageToMongoDB(msg, b.ID)
+}
+
+// SaveTelegramMessageToMongoDB は Telegram のメッセージを model.Message として保存します。
+func SaveTelegramMessageToMongoDB(msg *Message, bridgeID string) {
+	saveMessage(newMessage(msg, bridgeID))
+}
+
+// Telegram のメッセージを model.Message に変換する
+func newMessage(msg *Message, bridgeID string) model.Message {
+	chatID := strconv.FormatInt(msg.Chat.ID, 10)
+	userID := strconv.FormatInt(msg.From.ID, 10)
+
+	text := msg.Text
+	if text == "" {
+		text = msg.Caption
+	}
+	// 返信の場合は返信元を引用して表示する
+	if msg.ReplyToMessage != nil {
+		text = createQuote(msg.ReplyToMessage) + "\n" + text
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = userID
+	message.User.Platform = model.PlatformTelegram
+	message.User.Name = displayName(msg.From)
+	message.User.IconUrl = profilePhotoURL(msg.From.ID)
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = text
+	message.Content.Attachments = photoAttachments(msg.Photo)
+	message.BridgeID = bridgeID
+	message.ChannelID = chatID
+	message.ExternalID = strconv.Itoa(msg.MessageID)
+	message.CreatedAt = time.Unix(msg.Date, 0)
+	return message
+}
+
+// メッセージを MongoDB に保存する
+func insertMessage(message model.Message) {
+	if mongoCollection == nil {
+		log.Println("MongoDB collection is not initialized")
+		return
+	}
+
+	// MongoDB にドキュメントを挿入（Webhook の再送で二重登録しないよう upsert する）
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	filter := bson.M{"user.platform": model.PlatformTelegram, "channelId": message.ChannelID, "externalId": message.ExternalID}
+	if _, err := mongoCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": message}, options.Update().SetUpsert(true)); err != nil {
+		log.Printf("Telegramメッセージのデータ登録に失敗しました: %v", err)
+		return
+	}
+
+	log.Printf("Telegram message saved: %s from %s", message.Content.Text, message.User.Name)
+}
+
+// CreateTelegramMessage はMongoDBに新規追加されたメッセージを、同じブリッジのTelegramグループへ転送します。
+func CreateTelegramMessage(msg model.Message) {
+	if botToken == "" {
+		return
+	}
+
+	channels := bridge.Destinations(msg, model.PlatformTelegram)
+	if len(channels) == 0 {
+		return
+	}
+
+	header := createHeader(msg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, ch := range channels {
+		// 画像は写真として送信し、それ以外はリンクとして本文に追加する
+		for _, attachment := range msg.Content.Attachments {
+			if attachment.Type != "image" {
+				continue
+			}
+			params := map[string]interface{}{
+				"chat_id":    ch.ChannelID,
+				"photo":      attachment.URL,
+				"caption":    header,
+				"parse_mode": "HTML",
+			}
+			var sent Message
+			if err := call(ctx, "sendPhoto", params, &sent); err != nil {
+				log.Printf("Telegramへの画像送信に失敗しました (chat: %s): %v", ch.ChannelID, err)
+				continue
+			}
+			delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformTelegram, ChannelID: ch.ChannelID, ExternalID: strconv.Itoa(sent.MessageID), Attachment: true})
+		}
+
+		text, ok := createTelegramText(msg)
+		// 画像のみのメッセージは本文を送信しない
+		if !ok {
+			continue
+		}
+
+		params := map[string]interface{}{
+			"chat_id":                  ch.ChannelID,
+			"text":                     text,
+			"parse_mode":               "HTML",
+			"disable_web_page_preview": true,
+		}
+		var sent Message
+		if err := call(ctx, "sendMessage", params, &sent); err != nil {
+			log.Printf("Telegramへのメッセージ送信に失敗しました (chat: %s): %v", ch.ChannelID, err)
+			continue
+		}
+		log.Printf("Telegram送信成功 (chat: %s)", ch.ChannelID)
+		delivery.Record(msg.ID, model.Delivery{Platform: model.PlatformTelegram, ChannelID: ch.ChannelID, ExternalID: strconv.Itoa(sent.MessageID)})
+	}
+}
+
+// EditTelegramMessage は編集されたメッセージの本文を、転送先の Telegram メッセージに反映します。
+func EditTelegramMessage(msg model.Message) {
+	if botToken == "" {
+		return
+	}
+
+	text, ok := createTelegramText(msg)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformTelegram) {
+		if d.Attachment {
+			continue
+		}
+		params := map[string]interface{}{
+			"chat_id":                  d.ChannelID,
+			"message_id":               d.ExternalID,
+			"text":                     text,
+			"parse_mode":               "HTML",
+			"disable_web_page_preview": true,
+		}
+		if err := call(ctx, "editMessageText", params, nil); err != nil {
+			log.Printf("Telegramメッセージの編集に失敗しました (chat: %s, message: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Telegramメッセージを編集しました (chat: %s, message: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// DeleteTelegramMessage は削除されたメッセージを、転送先の Telegram から削除します。
+// Bot が送信したメッセージは送信から48時間以内のみ削除できます。
+func DeleteTelegramMessage(msg model.Message) {
+	if botToken == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, d := range delivery.ForMessage(msg.ID, model.PlatformTelegram) {
+		params := map[string]interface{}{"chat_id": d.ChannelID, "message_id": d.ExternalID}
+		if err := call(ctx, "deleteMessage", params, nil); err != nil {
+			log.Printf("Telegramメッセージの削除に失敗しました (chat: %s, message: %s): %v", d.ChannelID, d.ExternalID, err)
+			continue
+		}
+		log.Printf("Telegramメッセージを削除しました (chat: %s, message: %s)", d.ChannelID, d.ExternalID)
+	}
+}
+
+// 送信者の名前とプラットフォーム
+func createHeader(msg model.Message) string {
+	return fmt.Sprintf("<b>%s</b> (%s)", html.EscapeString(msg.User.Name), html.EscapeString(string(msg.User.Platform)))
+}
+
+// 送信者・本文・画像以外の添付ファイルのリンクを HTML 形式の本文にまとめる。
+// 画像のみのメッセージは本文を送信しないため false を返す
+func createTelegramText(msg model.Message) (string, bool) {
+	lines := []string{createHeader(msg)}
+	if msg.Content.Text != "" {
+		lines = append(lines, html.EscapeString(msg.Content.Text))
+	}
+	for _, attachment := range msg.Content.Attachments {
+		if attachment.Type == "image" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(`<a href="%s">📎 %s</a>`, html.EscapeString(attachment.URL), html.EscapeString(attachment.Type)))
+	}
+	if len(lines) == 1 && len(msg.Content.Attachments) > 0 {
+		return "", false
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// 表示名: 姓名 > ユーザー名
+func displayName(user *User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name == "" {
+		name = user.Username
+	}
+	return name
+}
+
+// 返信元メッセージの引用
+func createQuote(reply *Message) string {
+	text := reply.Text
+	if text == "" {
+		text = reply.Caption
+	}
+	runes := []rune(text)
+	if len(runes) > maxQuoteLength {
+		text = string(runes[:maxQuoteLength]) + "…"
+	}
+
+	name := "unknown"
+	if reply.From != nil {
+		name = displayName(reply.From)
+	}
+	return fmt.Sprintf("↪ %s: %s", name, text)
+}
+
+// 写真を添付ファイルに変換する（最も大きいサイズのみ）
+func photoAttachments(photos []PhotoSize) []model.Attachment {
+	if len(photos) == 0 {
+		return nil
+	}
+	if publicURL == "" {
+		log.Println("LINKGATE_PUBLIC_URL が設定されていないため Telegram の画像は転送されません")
+		return nil
+	}
+	largest := photos[len(photos)-1]
+	return []model.Attachment{{Type: "image", URL: FileURL(largest.FileID)}}
+}
+
+// ユーザーのプロフィール画像のURLを返す（取得できない場合は空文字）
+func profilePhotoURL(userID int64) string {
+	if publicURL == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var photos UserProfilePhotos
+	params := map[string]interface{}{"user_id": userID, "limit": 1}
+	if err := call(ctx, "getUserProfilePhotos", params, &photos); err != nil || len(photos.Photos) == 0 {
+		return ""
+	}
+	sizes := photos.Photos[0]
+	if len(sizes) == 0 {
+		return ""
+	}
+	return FileURL(sizes[0].FileID)
+}
+
+// FileURL は Telegram のファイルを LinkGate 経由で配信する署名付きURLを返します。
+func FileURL(fileID string) string {
+	return fmt.Sprintf("%s/telegram/files/%s?%s", publicURL, url.PathEscape(fileID), signedurl.Query(fileKind, fileID))
+}
@@ -0,0 +1,320 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+)
+
+// テスト用のブリッジ設定を読み込ませる
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "telegram")
+	if err != nil {
+		panic(err)
+	}
+	path := filepath.Join(dir, "bridges.json")
+	config := `[
+		{"id": "general", "channels": [
+			{"platform": "Telegram", "channelId": "-1001"},
+			{"platform": "Telegram", "channelId": "-1002"},
+			{"platform": "Discord", "channelId": "d-general"}
+		]}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		panic(err)
+	}
+	os.Setenv("BRIDGE_CONFIG_PATH", path)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 受信したメッセージを保存せずに記録する
+func captureSaved(t *testing.T) *[]model.Message {
+	t.Helper()
+	saved := &[]model.Message{}
+	prev := saveMessage
+	saveMessage = func(message model.Message) { *saved = append(*saved, message) }
+	t.Cleanup(func() { saveMessage = prev })
+	return saved
+}
+
+// 添付ファイル・アイコンの公開URLを設定する
+func setPublicURL(t *testing.T, url string) {
+	t.Helper()
+	prev := publicURL
+	publicURL = url
+	t.Cleanup(func() { publicURL = prev })
+}
+
+func TestVerifyWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		want   bool
+	}{
+		{name: "一致", secret: "s3cret", token: "s3cret", want: true},
+		{name: "不一致", secret: "s3cret", token: "other", want: false},
+		{name: "トークンなし", secret: "s3cret", token: "", want: false},
+		{name: "前方一致のみ", secret: "s3cret", token: "s3c", want: false},
+		{name: "シークレット未設定", secret: "", token: "", want: false},
+		{name: "シークレット未設定で任意のトークン", secret: "", token: "anything", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(prev string) { webhookSecret = prev }(webhookSecret)
+			webhookSecret = tt.secret
+
+			if got := VerifyWebhookSecret(tt.token); got != tt.want {
+				t.Errorf("VerifyWebhookSecret(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		secret string
+		want   bool
+	}{
+		{name: "webhook 方式でシークレットあり", mode: ModeWebhook, secret: "s3cret", want: true},
+		{name: "webhook 方式でシークレットなし", mode: ModeWebhook, secret: "", want: false},
+		{name: "polling 方式", mode: ModePolling, secret: "s3cret", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(prevMode, prevSecret string) { mode, webhookSecret = prevMode, prevSecret }(mode, webhookSecret)
+			mode, webhookSecret = tt.mode, tt.secret
+
+			if got := WebhookEnabled(); got != tt.want {
+				t.Errorf("WebhookEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateWebhook(t *testing.T) {
+	newBotAPIStub(t)
+	setPublicURL(t, "https://linkgate.example.com")
+
+	tests := []struct {
+		name string
+		// Webhook で受信する Update の JSON
+		body string
+		// 保存されるメッセージ（nil の場合は保存されない）
+		want *model.Message
+		// 添付ファイルのURLの接頭辞
+		wantAttachment string
+	}{
+		{
+			name: "グループのテキスト",
+			body: `{"update_id": 1, "message": {"message_id": 10, "date": 1735689600,
+				"from": {"id": 42, "is_bot": false, "first_name": "Alice", "last_name": "Smith"},
+				"chat": {"id": -1001, "type": "supergroup"}, "text": "hello"}}`,
+			want: &model.Message{
+				BridgeID: "general", ChannelID: "-1001", ExternalID: "10",
+				User:    model.User{UserID: "42", Platform: model.PlatformTelegram, Name: "Alice Smith"},
+				Content: model.Content{Text: "hello"},
+			},
+		},
+		{
+			name: "写真とキャプション",
+			body: `{"update_id": 2, "message": {"message_id": 11, "date": 1735689600,
+				"from": {"id": 42, "is_bot": false, "first_name": "", "username": "alice"},
+				"chat": {"id": -1001, "type": "group"}, "caption": "見て",
+				"photo": [{"file_id": "small", "width": 90, "height": 90}, {"file_id": "large", "width": 800, "height": 800}]}}`,
+			want: &model.Message{
+				BridgeID: "general", ChannelID: "-1001", ExternalID: "11",
+				User:    model.User{UserID: "42", Platform: model.PlatformTelegram, Name: "alice"},
+				Content: model.Content{Text: "見て"},
+			},
+			wantAttachment: "https://linkgate.example.com/telegram/files/large?",
+		},
+		{
+			name: "返信",
+			body: `{"update_id": 3, "message": {"message_id": 12, "date": 1735689600,
+				"from": {"id": 43, "is_bot": false, "first_name": "Bob"},
+				"chat": {"id": -1002, "type": "supergroup"}, "text": "了解",
+				"reply_to_message": {"message_id": 10, "date": 1735689500,
+					"from": {"id": 42, "is_bot": false, "first_name": "Alice"},
+					"chat": {"id": -1002, "type": "supergroup"}, "text": "明日は10時集合です"}}}`,
+			want: &model.Message{
+				BridgeID: "general", ChannelID: "-1002", ExternalID: "12",
+				User:    model.User{UserID: "43", Platform: model.PlatformTelegram, Name: "Bob"},
+				Content: model.Content{Text: "↪ Alice: 明日は10時集合です\n了解"},
+			},
+		},
+		{
+			name: "Bot のメッセージは保存しない",
+			body: `{"update_id": 4, "message": {"message_id": 13, "date": 1735689600,
+				"from": {"id": 1, "is_bot": true, "first_name": "LinkGate"},
+				"chat": {"id": -1001, "type": "supergroup"}, "text": "転送"}}`,
+		},
+		{
+			name: "個人チャットは保存しない",
+			body: `{"update_id": 5, "message": {"message_id": 14, "date": 1735689600,
+				"from": {"id": 42, "is_bot": false, "first_name": "Alice"},
+				"chat": {"id": 42, "type": "private"}, "text": "hello"}}`,
+		},
+		{
+			name: "ブリッジに含まれないグループは保存しない",
+			body: `{"update_id": 6, "message": {"message_id": 15, "date": 1735689600,
+				"from": {"id": 42, "is_bot": false, "first_name": "Alice"},
+				"chat": {"id": -9999, "type": "group"}, "text": "hello"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := captureSaved(t)
+
+			var update Update
+			if err := json.Unmarshal([]byte(tt.body), &update); err != nil {
+				t.Fatalf("Update の解析に失敗しました: %v", err)
+			}
+			HandleUpdate(update)
+
+			if tt.want == nil {
+				if len(*saved) != 0 {
+					t.Fatalf("saved = %+v, want none", *saved)
+				}
+				return
+			}
+			if len(*saved) != 1 {
+				t.Fatalf("saved %d messages, want 1", len(*saved))
+			}
+			got := (*saved)[0]
+			if got.BridgeID != tt.want.BridgeID || got.ChannelID != tt.want.ChannelID || got.ExternalID != tt.want.ExternalID {
+				t.Errorf("bridge/channel/external = %s/%s/%s, want %s/%s/%s",
+					got.BridgeID, got.ChannelID, got.ExternalID, tt.want.BridgeID, tt.want.ChannelID, tt.want.ExternalID)
+			}
+			if got.User.UserID != tt.want.User.UserID || got.User.Platform != tt.want.User.Platform || got.User.Name != tt.want.User.Name {
+				t.Errorf("user = %+v, want %+v", got.User, tt.want.User)
+			}
+			if !strings.HasPrefix(got.User.IconUrl, "https://linkgate.example.com/telegram/files/avatar-1?") {
+				t.Errorf("user.iconUrl = %q, want signed avatar URL", got.User.IconUrl)
+			}
+			if got.Content.Text != tt.want.Content.Text {
+				t.Errorf("content.text = %q, want %q", got.Content.Text, tt.want.Content.Text)
+			}
+			if !got.CreatedAt.Equal(time.Unix(1735689600, 0)) {
+				t.Errorf("createdAt = %v, want %v", got.CreatedAt, time.Unix(1735689600, 0))
+			}
+
+			if tt.wantAttachment == "" {
+				if len(got.Content.Attachments) != 0 {
+					t.Errorf("attachments = %+v, want none", got.Content.Attachments)
+				}
+				return
+			}
+			if len(got.Content.Attachments) != 1 || got.Content.Attachments[0].Type != "image" ||
+				!strings.HasPrefix(got.Content.Attachments[0].URL, tt.wantAttachment) {
+				t.Errorf("attachments = %+v, want one image with URL prefix %q", got.Content.Attachments, tt.wantAttachment)
+			}
+		})
+	}
+}
+
+func TestPollOnce(t *testing.T) {
+	stub := newBotAPIStub(t)
+	saved := captureSaved(t)
+
+	from := &User{ID: 42, FirstName: "Alice"}
+	stub.updates = []Update{
+		{UpdateID: 20, Message: &Message{MessageID: 1, From: from, Chat: Chat{ID: -1001, Type: "group"}, Text: "one"}},
+		{UpdateID: 21, Message: &Message{MessageID: 2, From: from, Chat: Chat{ID: -1001, Type: "group"}, Text: "two"}},
+	}
+
+	offset, err := pollOnce(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+	if offset != 22 {
+		t.Errorf("offset = %d, want 22", offset)
+	}
+	if len(*saved) != 2 || (*saved)[0].Content.Text != "one" || (*saved)[1].Content.Text != "two" {
+		t.Errorf("saved = %+v, want messages one, two", *saved)
+	}
+
+	// 処理済みの Update は次回の offset で除かれる
+	offset, err = pollOnce(context.Background(), offset)
+	if err != nil {
+		t.Fatalf("pollOnce() error = %v", err)
+	}
+	if offset != 22 || len(*saved) != 2 {
+		t.Errorf("offset = %d, saved %d messages, want 22, 2", offset, len(*saved))
+	}
+	calls := stub.called("getUpdates")
+	if len(calls) != 2 || calls[1]["offset"] != float64(22) {
+		t.Errorf("getUpdates calls = %v, want second call with offset 22", calls)
+	}
+}
+
+func TestCreateTelegramMessage(t *testing.T) {
+	stub := newBotAPIStub(t)
+
+	msg := model.Message{
+		BridgeID:  "general",
+		ChannelID: "-1001",
+		User:      model.User{Platform: model.PlatformTelegram, Name: "Alice & <Bob>"},
+		Content: model.Content{
+			Text: "hello <world>",
+			Attachments: []model.Attachment{
+				{Type: "image", URL: "https://example.com/a.png"},
+				{Type: "file", URL: "https://example.com/doc.pdf"},
+			},
+		},
+	}
+	CreateTelegramMessage(msg)
+
+	header := "<b>Alice &amp; &lt;Bob&gt;</b> (Telegram)"
+	photos := stub.called("sendPhoto")
+	if len(photos) != 1 {
+		t.Fatalf("sendPhoto calls = %v, want 1 (投稿元のグループには送信しない)", photos)
+	}
+	if photos[0]["chat_id"] != "-1002" || photos[0]["photo"] != "https://example.com/a.png" ||
+		photos[0]["caption"] != header || photos[0]["parse_mode"] != "HTML" {
+		t.Errorf("sendPhoto params = %v", photos[0])
+	}
+
+	messages := stub.called("sendMessage")
+	if len(messages) != 1 {
+		t.Fatalf("sendMessage calls = %v, want 1", messages)
+	}
+	wantText := header + "\nhello &lt;world&gt;\n" + `<a href="https://example.com/doc.pdf">📎 file</a>`
+	if messages[0]["chat_id"] != "-1002" || messages[0]["text"] != wantText || messages[0]["parse_mode"] != "HTML" {
+		t.Errorf("sendMessage params = %v, want text %q", messages[0], wantText)
+	}
+}
+
+func TestCreateTelegramMessageImageOnly(t *testing.T) {
+	stub := newBotAPIStub(t)
+
+	msg := model.Message{
+		BridgeID:  "general",
+		ChannelID: "d-general",
+		User:      model.User{Platform: model.PlatformDiscord, Name: "Carol"},
+		Content:   model.Content{Attachments: []model.Attachment{{Type: "image", URL: "https://example.com/a.png"}}},
+	}
+	CreateTelegramMessage(msg)
+
+	if photos := stub.called("sendPhoto"); len(photos) != 2 {
+		t.Errorf("sendPhoto calls = %v, want 2", photos)
+	}
+	// 画像のみのメッセージは本文を送信しない
+	if messages := stub.called("sendMessage"); len(messages) != 0 {
+		t.Errorf("sendMessage calls = %v, want none", messages)
+	}
+}
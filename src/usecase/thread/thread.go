@@ -0,0 +1,124 @@
+package thread
+
+// このパッケージはプラットフォームをまたいだスレッドの対応関係を管理します。
+// Discord のスレッドや Slack のスレッドに最初のメッセージが投稿されたときに対応関係を作成し、
+// 転送先で作成したスレッドを記録することで、以降の返信を同じスレッドへ転送できるようにします。
+
+import (
+	"context"
+	"errors"
+	"fuagfuga-2025-LinkGate/src/model"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// スレッドの対応関係の保存先
+var collection *mongo.Collection
+
+// Init はスレッドの対応関係の保存先を設定し、検索用のインデックスを作成します。
+func Init(coll *mongo.Collection) error {
+	collection = coll
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "threads.platform", Value: 1},
+			{Key: "threads.channelId", Value: 1},
+			{Key: "threads.threadId", Value: 1},
+		},
+	})
+	return err
+}
+
+// 対応するスレッドを検索する条件
+func refFilter(ref model.ThreadRef) bson.M {
+	return bson.M{"threads": bson.M{"$elemMatch": bson.M{
+		"platform":  ref.Platform,
+		"channelId": ref.ChannelID,
+		"threadId":  ref.ThreadID,
+	}}}
+}
+
+// Find はスレッドが属する対応関係を返します。
+func Find(ref model.ThreadRef) (model.ThreadLink, bool) {
+	if collection == nil {
+		return model.ThreadLink{}, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var link model.ThreadLink
+	if err := collection.FindOne(ctx, refFilter(ref)).Decode(&link); err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("スレッドの対応関係の取得に失敗しました: %v", err)
+		}
+		return model.ThreadLink{}, false
+	}
+	return link, true
+}
+
+// ForMessage はスレッド内に投稿されたメッセージの対応関係を返します。
+func ForMessage(msg model.Message) (model.ThreadLink, bool) {
+	if msg.ThreadID == "" {
+		return model.ThreadLink{}, false
+	}
+	return Find(model.ThreadRef{Platform: msg.User.Platform, ChannelID: msg.ChannelID, ThreadID: msg.ThreadID})
+}
+
+// Ensure はスレッドの対応関係を返します。まだ存在しない場合は作成します。
+func Ensure(bridgeID, title string, ref model.ThreadRef) (model.ThreadLink, error) {
+	if collection == nil {
+		return model.ThreadLink{}, errors.New("スレッドの保存先が初期化されていません")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	link := model.ThreadLink{
+		ID:        primitive.NewObjectID(),
+		BridgeID:  bridgeID,
+		Title:     title,
+		Threads:   []model.ThreadRef{ref},
+		CreatedAt: time.Now(),
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, refFilter(ref), bson.M{"$setOnInsert": link}, opts).Decode(&link)
+	return link, err
+}
+
+// AddThread は転送先で作成したスレッドを対応関係に追加します。
+func AddThread(linkID primitive.ObjectID, ref model.ThreadRef) error {
+	if collection == nil {
+		return errors.New("スレッドの保存先が初期化されていません")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": linkID}, bson.M{"$addToSet": bson.M{"threads": ref}})
+	return err
+}
+
+// Counterpart は対応関係の中から、指定したチャンネルのスレッドを返します。
+func Counterpart(link model.ThreadLink, platform model.Platform, channelID string) (model.ThreadRef, bool) {
+	for _, ref := range link.Threads {
+		if ref.Platform == platform && ref.ChannelID == channelID {
+			return ref, true
+		}
+	}
+	return model.ThreadRef{}, false
+}
+
+// DisplayTitle はスレッドのタイトルを返します。未設定の場合は既定のタイトルを返します。
+func DisplayTitle(link model.ThreadLink) string {
+	if link.Title != "" {
+		return link.Title
+	}
+	return "スレッド"
+}
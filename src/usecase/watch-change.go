@@ -2,14 +2,25 @@ package usecase
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"fuagfuga-2025-LinkGate/src/model"
 	"fuagfuga-2025-LinkGate/src/usecase/discord"
+	"fuagfuga-2025-LinkGate/src/usecase/email"
+	"fuagfuga-2025-LinkGate/src/usecase/irc"
 	"fuagfuga-2025-LinkGate/src/usecase/line"
+	"fuagfuga-2025-LinkGate/src/usecase/matrix"
+	"fuagfuga-2025-LinkGate/src/usecase/mattermost"
+	"fuagfuga-2025-LinkGate/src/usecase/realtime"
+	"fuagfuga-2025-LinkGate/src/usecase/search"
 	"fuagfuga-2025-LinkGate/src/usecase/slack"
+	"fuagfuga-2025-LinkGate/src/usecase/telegram"
+	"fuagfuga-2025-LinkGate/src/usecase/webhook"
 	"log"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // dbの変更を監視するための関数
@@ -19,7 +30,10 @@ func WatchChanges(coll *mongo.Collection) {
 	// 監視パイプライン（ここでは全部の変更を監視）
 	pipeline := mongo.Pipeline{}
 
-	stream, err := coll.Watch(ctx, pipeline)
+	// 更新時も編集・削除を転送できるよう、更新後のドキュメントを受け取る
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+
+	stream, err := coll.Watch(ctx, pipeline, opts)
 	if err != nil {
 		log.Fatal("Change Stream エラー:", err)
 	}
@@ -29,8 +43,12 @@ func WatchChanges(coll *mongo.Collection) {
 
 	for stream.Next(ctx) {
 		var event struct {
-			OperationType string        `bson:"operationType"`
-			FullDocument  model.Message `bson:"fullDocument"`
+			ID                bson.Raw      `bson:"_id"`
+			OperationType     string        `bson:"operationType"`
+			FullDocument      model.Message `bson:"fullDocument"`
+			UpdateDescription struct {
+				UpdatedFields bson.M `bson:"updatedFields"`
+			} `bson:"updateDescription"`
 		}
 		if err := stream.Decode(&event); err != nil {
 			log.Println("Decode error:", err)
@@ -39,20 +57,63 @@ func WatchChanges(coll *mongo.Collection) {
 
 		fullDoc := event.FullDocument
 		platform := fullDoc.User.Platform
+		// 再開トークンを SSE・WebSocket のイベントIDにする
+		eventID := base64.RawURLEncoding.EncodeToString(event.ID)
 
-		// 新規メッセージ挿入時に各プラットフォームへ転送します。
+		// 全文検索用のトークンを作成します（本文が編集された場合は作り直します）。
 		if event.OperationType == "insert" {
+			search.Index(fullDoc)
+		} else if _, ok := event.UpdateDescription.UpdatedFields["content.text"]; ok && event.OperationType == "update" {
+			search.Index(fullDoc)
+		}
+
+		// 新規メッセージ挿入時に各プラットフォームへ転送します。
+		// 履歴の取り込みで登録されたメッセージは転送しません。
+		if event.OperationType == "insert" && !fullDoc.Imported {
 			// 元プラットフォームがLINEでなければLINEへ送信
 			if platform != model.PlatformLINE {
 				line.CreateLINEMessage(fullDoc)
 			}
-			// 元プラットフォームがDiscordでなければDiscordへ送信
-			if platform != model.PlatformDiscord {
-				discord.CreateDiscordMessage(fullDoc)
-			}
-			// 元プラットフォームがSlackでなければSlackへ送信
-			if platform != model.PlatformSlack {
-				slack.CreateSlackMessage(fullDoc)
+			// 同じブリッジの他のDiscordチャンネルへ送信（投稿元チャンネルは除外されます）
+			discord.CreateDiscordMessage(fullDoc)
+			// 同じブリッジの他のSlackチャンネル・ワークスペースへ送信（投稿元チャンネルは除外されます）
+			slack.CreateSlackMessage(fullDoc)
+			// 同じブリッジの他のTelegramグループへ送信（投稿元グループは除外されます）
+			telegram.CreateTelegramMessage(fullDoc)
+			// 同じブリッジの他のMatrixルームへ送信者の仮想ユーザーとして送信
+			matrix.CreateMatrixMessage(fullDoc)
+			// 同じブリッジの他のIRCチャンネルへ送信
+			irc.CreateIRCMessage(fullDoc)
+			// 同じブリッジの他のMattermostチャンネルへ送信
+			mattermost.CreateMattermostMessage(fullDoc)
+			// 同じブリッジの他のメーリングリストへ送信
+			email.CreateEmailMessage(fullDoc)
+			// 同じブリッジの他の連携へ署名付きで送信
+			webhook.CreateWebhookMessage(fullDoc)
+			// SSE・WebSocket で接続しているクライアントへ配信
+			realtime.Publish(eventID, realtime.EventMessageCreated, fullDoc)
+		}
+
+		// Web API での編集・削除を転送先のメッセージに反映します。
+		// LINE・IRC・メールは送信済みのメッセージを変更できないため反映しません。
+		if event.OperationType == "update" && !fullDoc.Imported {
+			updated := event.UpdateDescription.UpdatedFields
+			if _, ok := updated["deletedAt"]; ok && fullDoc.DeletedAt != nil {
+				discord.DeleteDiscordMessage(fullDoc)
+				slack.DeleteSlackMessage(fullDoc)
+				telegram.DeleteTelegramMessage(fullDoc)
+				matrix.DeleteMatrixMessage(fullDoc)
+				mattermost.DeleteMattermostMessage(fullDoc)
+				webhook.DeleteWebhookMessage(fullDoc)
+				realtime.Publish(eventID, realtime.EventMessageDeleted, fullDoc)
+			} else if _, ok := updated["content.text"]; ok && fullDoc.DeletedAt == nil {
+				discord.EditDiscordMessage(fullDoc)
+				slack.EditSlackMessage(fullDoc)
+				telegram.EditTelegramMessage(fullDoc)
+				matrix.EditMatrixMessage(fullDoc)
+				mattermost.EditMattermostMessage(fullDoc)
+				webhook.EditWebhookMessage(fullDoc)
+				realtime.Publish(eventID, realtime.EventMessageUpdated, fullDoc)
 			}
 		}
 
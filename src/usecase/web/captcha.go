@@ -0,0 +1,104 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// キャプチャの有効期間
+const captchaDuration = 5 * time.Minute
+
+// キャプチャの署名に使用するシークレット。未設定の場合は起動ごとにランダムに生成します
+// （複数台で動かす場合は同じ値を設定してください）。
+var captchaSecret = captchaSecretFromEnv()
+
+// 使用済みのキャプチャ（同じキャプチャで複数のセッションを作成できないようにする）
+var (
+	usedCaptchas   = map[string]time.Time{}
+	usedCaptchasMu sync.Mutex
+)
+
+// newCaptcha は簡単な足し算の問題と、回答の検証に使用するIDを返します。
+// ID には有効期限と署名を含めるため、サーバー側に問題を保存する必要はありません。
+func newCaptcha() (id, question string, err error) {
+	a, err := rand.Int(rand.Reader, big.NewInt(9))
+	if err != nil {
+		return "", "", err
+	}
+	b, err := rand.Int(rand.Reader, big.NewInt(9))
+	if err != nil {
+		return "", "", err
+	}
+	x, y := a.Int64()+1, b.Int64()+1
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", err
+	}
+	payload := fmt.Sprintf("%d.%s", time.Now().Add(captchaDuration).Unix(), hex.EncodeToString(nonce))
+	id = base64.RawURLEncoding.EncodeToString([]byte(payload + "." + signCaptcha(payload, x+y)))
+	return id, fmt.Sprintf("%d + %d は？", x, y), nil
+}
+
+// verifyCaptcha はキャプチャの回答を検証します。一度検証に成功したキャプチャは使用できません。
+func verifyCaptcha(id, answer string) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(answer), 10, 64)
+	if err != nil {
+		return false
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signCaptcha(payload, n))) {
+		return false
+	}
+
+	usedCaptchasMu.Lock()
+	defer usedCaptchasMu.Unlock()
+	now := time.Now()
+	for key, expiresAt := range usedCaptchas {
+		if now.After(expiresAt) {
+			delete(usedCaptchas, key)
+		}
+	}
+	if _, used := usedCaptchas[parts[1]]; used {
+		return false
+	}
+	usedCaptchas[parts[1]] = time.Unix(expires, 0)
+	return true
+}
+
+func signCaptcha(payload string, answer int64) string {
+	mac := hmac.New(sha256.New, captchaSecret)
+	fmt.Fprintf(mac, "%s.%d", payload, answer)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func captchaSecretFromEnv() []byte {
+	if secret := os.Getenv("WEBCHAT_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}
@@ -0,0 +1,271 @@
+package web
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/realtime"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// チャット画面の静的ファイル
+//
+//go:embed static
+var staticFiles embed.FS
+
+// 履歴の1回あたりの取得件数
+const historyLimit = 50
+
+// 履歴の1回の取得で確認するメッセージの最大件数（転送先の指定で除かれるメッセージがあるため、取得件数より多く確認する）
+const maxHistoryScan = 1000
+
+// コンテキストに保存するキー
+const (
+	bridgeKey  = "webchat.bridge"
+	sessionKey = "webchat.session"
+)
+
+var (
+	// セッションの作成（IPアドレスごと）
+	sessionLimiter = newLimiter(sessionsPerIP, 10*time.Minute, sessionsPerIP)
+	// メッセージの投稿（セッションごと）
+	messageLimiter = newLimiter(messagesPerMinute, time.Minute, 5)
+)
+
+// セッションの作成リクエスト
+type sessionRequest struct {
+	// ニックネーム
+	Nickname string `json:"nickname" binding:"required"`
+	// キャプチャのID
+	CaptchaID string `json:"captchaId" binding:"required"`
+	// キャプチャの回答
+	CaptchaAnswer string `json:"captchaAnswer" binding:"required"`
+}
+
+// メッセージの投稿リクエスト
+type postRequest struct {
+	// 本文
+	Text string `json:"text" binding:"required"`
+}
+
+// RegisterRoutes はチャット画面と、ゲストが使用するエンドポイントを登録します。
+// チャット画面は /chat/?channel=<チャンネルID>、埋め込み用のスクリプトは /chat/embed.js で配信します。
+func RegisterRoutes(r *gin.Engine) {
+	static, _ := fs.Sub(staticFiles, "static")
+	r.StaticFS("/chat", http.FS(static))
+
+	g := r.Group("/webchat/:channelId", resolveChannel)
+	g.GET("/captcha", HandleCaptcha)
+	g.POST("/session", HandleSession)
+	g.GET("/messages", authenticateGuest, HandleHistory)
+	g.POST("/messages", authenticateGuest, HandlePost)
+	g.GET("/stream", authenticateGuest, HandleStream)
+}
+
+// HandleCaptcha はセッションの作成に必要なキャプチャを発行します。
+func HandleCaptcha(c *gin.Context) {
+	id, question, err := newCaptcha()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "キャプチャの作成に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"captchaId": id, "question": question})
+}
+
+// HandleSession はキャプチャの回答を確認し、ゲストのセッションを作成します。
+func HandleSession(c *gin.Context) {
+	var req sessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	if !sessionLimiter.allow(c.ClientIP()) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "しばらく時間をおいてから参加してください"})
+		return
+	}
+	if !verifyCaptcha(req.CaptchaID, req.CaptchaAnswer) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "キャプチャの回答が正しくないか、有効期限が切れています"})
+		return
+	}
+
+	session, token, err := createSession(c.Param("channelId"), req.Nickname, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, ErrInvalidName) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "セッションの作成に失敗しました", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"session": session, "token": token})
+}
+
+// HandleHistory はチャンネルに表示されるメッセージを新しい順に返します。?before=<メッセージID> でそれより前を取得できます。
+// 転送先の指定（targets）で Web チャットが除かれたメッセージは含めず、他のブリッジから転送先に指定されたメッセージは含めます。
+func HandleHistory(c *gin.Context) {
+	b := c.MustGet(bridgeKey).(model.Bridge)
+	ch := model.Channel{Platform: model.PlatformWeb, ChannelID: c.Param("channelId")}
+
+	// 転送先の指定のブリッジIDは、デフォルトブリッジの場合 "default" で指定されている場合がある
+	targetBridgeIDs := bson.A{b.ID}
+	if def, ok := bridge.Get(""); ok && def.ID == b.ID && b.ID != bridge.DefaultBridgeID {
+		targetBridgeIDs = append(targetBridgeIDs, bridge.DefaultBridgeID)
+	}
+	conditions := bson.A{
+		bson.M{"deletedAt": bson.M{"$exists": false}},
+		bson.M{"$or": bson.A{
+			bson.M{"bridgeId": bridge.MessageFilter(b.ID)},
+			bson.M{"targets.bridgeId": bson.M{"$in": targetBridgeIDs}},
+		}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if before := c.Query("before"); before != "" {
+		id, err := primitive.ObjectIDFromHex(before)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before はメッセージIDで指定してください"})
+			return
+		}
+		var last model.Message
+		if err := messageCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&last); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "before のメッセージが見つかりません", "details": err.Error()})
+			return
+		}
+		// 作成日時が同じメッセージはIDで順序を決める
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"createdAt": bson.M{"$lt": last.CreatedAt}},
+			bson.M{"createdAt": last.CreatedAt, "_id": bson.M{"$lt": id}},
+		}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetBatchSize(historyLimit * 2)
+	cur, err := messageCollection.Find(ctx, bson.M{"$and": conditions}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+	defer cur.Close(ctx)
+
+	// 転送先の指定はブリッジの設定と照合する必要があるため、取得後に絞り込む
+	messages := []model.Message{}
+	scanned := 0
+	for len(messages) <= historyLimit && scanned < maxHistoryScan && cur.Next(ctx) {
+		var message model.Message
+		if err := cur.Decode(&message); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "データのパースに失敗しました", "details": err.Error()})
+			return
+		}
+		scanned++
+		if bridge.Reaches(message, ch) {
+			messages = append(messages, message)
+		}
+	}
+	if err := cur.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ取得に失敗しました", "details": err.Error()})
+		return
+	}
+
+	// 1件多く見つかった場合、または確認する件数の上限に達した場合は続きがある
+	hasMore := len(messages) > historyLimit || scanned >= maxHistoryScan
+	if len(messages) > historyLimit {
+		messages = messages[:historyLimit]
+	}
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "hasMore": hasMore})
+}
+
+// HandlePost はゲストのメッセージを Web プラットフォームの投稿として保存します。
+func HandlePost(c *gin.Context) {
+	b := c.MustGet(bridgeKey).(model.Bridge)
+	session := c.MustGet(sessionKey).(model.GuestSession)
+
+	var req postRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無効なリクエストです", "details": err.Error()})
+		return
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" || utf8.RuneCountInString(text) > maxTextLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "本文は1〜2000文字で入力してください"})
+		return
+	}
+	if !messageLimiter.allow(session.ID) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "投稿が多すぎます。しばらく時間をおいてから投稿してください"})
+		return
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = session.ID
+	message.User.Platform = model.PlatformWeb
+	message.User.Name = session.Name
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = text
+	message.BridgeID = b.ID
+	message.ChannelID = session.ChannelID
+	message.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := messageCollection.InsertOne(ctx, message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "データ登録に失敗しました", "details": err.Error()})
+		return
+	}
+	log.Printf("Web message saved: %s from %s", message.Content.Text, message.User.Name)
+	c.JSON(http.StatusCreated, message)
+}
+
+// HandleStream はチャンネルに表示されるメッセージの追加・編集・削除を Server-Sent Events で配信します。
+func HandleStream(c *gin.Context) {
+	realtime.ServeChannelSSE(c, model.Channel{Platform: model.PlatformWeb, ChannelID: c.Param("channelId")})
+}
+
+// Web チャットが有効なブリッジを取得する
+func resolveChannel(c *gin.Context) {
+	b, ok := bridge.Find(model.PlatformWeb, c.Param("channelId"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "このチャンネルでは Web チャットが有効になっていません"})
+		return
+	}
+	if messageCollection == nil {
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": ErrNotInitialized.Error()})
+		return
+	}
+	c.Set(bridgeKey, b)
+	c.Next()
+}
+
+// ゲストをセッショントークンで認証する（EventSource はヘッダーを送れないため access_token クエリも受け付ける）
+func authenticateGuest(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Query("access_token")
+	}
+	session, err := authenticate(c.Param("channelId"), token)
+	if err != nil {
+		status := http.StatusUnauthorized
+		if !errors.Is(err, ErrInvalidSession) {
+			status = http.StatusInternalServerError
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": "認証に失敗しました", "details": err.Error()})
+		return
+	}
+	c.Set(sessionKey, session)
+	c.Next()
+}
@@ -0,0 +1,66 @@
+package web
+
+// このパッケージはブラウザから参加できる Web チャット（Web プラットフォーム）を担当します。
+// チャット画面の静的ファイルはバイナリに埋め込んで配信し、ゲストはニックネームと簡単な計算問題（キャプチャ）で
+// セッションを作成して、ブリッジの履歴の取得・リアルタイム受信・投稿を行います。
+//
+// Web チャットはブリッジに Web プラットフォームのチャンネルとして参加させることで有効になります。
+// チャンネルIDは任意の名前で、チャット画面の URL（/chat/?channel=<チャンネルID>）に使用します。
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// セッションの有効期間
+const sessionDuration = 24 * time.Hour
+
+// ニックネーム・本文の最大文字数
+const (
+	maxNameLength = 20
+	maxTextLength = 2000
+)
+
+// ゲスト1人あたりの1分間の投稿数の上限
+var messagesPerMinute = envInt("WEBCHAT_RATE_PER_MINUTE", 20)
+
+// 同じIPアドレスから10分間に作成できるセッション数の上限
+var sessionsPerIP = envInt("WEBCHAT_SESSIONS_PER_IP", 5)
+
+var ErrNotInitialized = errors.New("Webチャットの保存先が初期化されていません")
+
+var (
+	// ゲストのセッションの保存先
+	sessionCollection *mongo.Collection
+	// メッセージの保存先
+	messageCollection *mongo.Collection
+)
+
+// Init はセッションとメッセージの保存先を設定し、セッションのインデックスを作成します。
+func Init(sessions, messages *mongo.Collection) error {
+	sessionCollection = sessions
+	messageCollection = messages
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := sessionCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// 有効期限を過ぎたセッションを自動で削除する
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	return err
+}
+
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
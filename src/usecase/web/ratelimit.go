@@ -0,0 +1,59 @@
+package web
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter はキーごとのトークンバケットによるレート制限です
+type limiter struct {
+	// 1秒あたりに補充するトークン数
+	rate float64
+	// バケットの容量（連続して許可する回数）
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(perInterval int, interval time.Duration, burst int) *limiter {
+	return &limiter{
+		rate:    float64(perInterval) / interval.Seconds(),
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+	}
+}
+
+// allow はキーのリクエストを許可するかを返します。
+func (l *limiter) allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	// 満タンに戻ったバケットは削除してメモリを解放する
+	if len(l.buckets) > 10000 {
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
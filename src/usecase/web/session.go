@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidName    = errors.New("ニックネームは1〜20文字で、制御文字を含めないでください")
+	ErrInvalidSession = errors.New("セッションが無効か、有効期限が切れています")
+)
+
+// createSession はゲストのセッションを作成し、セッショントークンを返します。
+func createSession(channelID, name, remoteAddr string) (model.GuestSession, string, error) {
+	if sessionCollection == nil {
+		return model.GuestSession{}, "", ErrNotInitialized
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return model.GuestSession{}, "", ErrInvalidName
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return model.GuestSession{}, "", err
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now()
+	session := model.GuestSession{
+		ID:         primitive.NewObjectID().Hex(),
+		TokenHash:  hashToken(token),
+		Name:       name,
+		ChannelID:  channelID,
+		RemoteAddr: remoteAddr,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(sessionDuration),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := sessionCollection.InsertOne(ctx, session); err != nil {
+		return model.GuestSession{}, "", err
+	}
+	return session, token, nil
+}
+
+// authenticate はセッショントークンに対応する有効なセッションを返します。
+func authenticate(channelID, token string) (model.GuestSession, error) {
+	if sessionCollection == nil {
+		return model.GuestSession{}, ErrNotInitialized
+	}
+	if token == "" {
+		return model.GuestSession{}, ErrInvalidSession
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var session model.GuestSession
+	filter := bson.M{"tokenHash": hashToken(token), "channelId": channelID, "expiresAt": bson.M{"$gt": time.Now()}}
+	err := sessionCollection.FindOne(ctx, filter).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return model.GuestSession{}, ErrInvalidSession
+	}
+	return session, err
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
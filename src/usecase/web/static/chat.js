@@ -0,0 +1,220 @@
+// LinkGate Web チャット
+(function () {
+  "use strict";
+
+  var channel = new URLSearchParams(location.search).get("channel");
+  var api = new URL("../webchat/" + encodeURIComponent(channel || "") + "/", location.href).href;
+  var storageKey = "linkgate.webchat." + channel;
+
+  var $ = function (id) { return document.getElementById(id); };
+  var session = null;
+  var captchaId = "";
+  var source = null;
+  // 表示中のメッセージ（ID → 要素）
+  var rendered = {};
+  var oldestId = "";
+
+  function request(method, path, body) {
+    var headers = { "Content-Type": "application/json" };
+    if (session) headers.Authorization = "Bearer " + session.token;
+    return fetch(api + path, { method: method, headers: headers, body: body ? JSON.stringify(body) : undefined })
+      .then(function (res) {
+        return res.json().catch(function () { return {}; }).then(function (data) {
+          if (!res.ok) {
+            var err = new Error(data.error || "エラーが発生しました (" + res.status + ")");
+            err.status = res.status;
+            throw err;
+          }
+          return data;
+        });
+      });
+  }
+
+  // === 参加 ===
+  function showJoin() {
+    $("chat").hidden = true;
+    $("join").hidden = false;
+    loadCaptcha();
+  }
+
+  function loadCaptcha() {
+    $("question").textContent = "読み込み中…";
+    request("GET", "captcha").then(function (data) {
+      captchaId = data.captchaId;
+      $("question").textContent = data.question;
+      $("answer").value = "";
+    }).catch(function (err) {
+      $("join-error").textContent = err.message;
+    });
+  }
+
+  $("join").addEventListener("submit", function (e) {
+    e.preventDefault();
+    $("join-error").textContent = "";
+    request("POST", "session", {
+      nickname: $("nickname").value,
+      captchaId: captchaId,
+      captchaAnswer: $("answer").value
+    }).then(function (data) {
+      session = { token: data.token, id: data.session.id, expiresAt: data.session.expiresAt };
+      localStorage.setItem(storageKey, JSON.stringify(session));
+      startChat();
+    }).catch(function (err) {
+      $("join-error").textContent = err.message;
+      loadCaptcha();
+    });
+  });
+
+  // === チャット ===
+  function startChat() {
+    $("join").hidden = true;
+    $("chat").hidden = false;
+    reload();
+    connect();
+  }
+
+  function reload() {
+    rendered = {};
+    oldestId = "";
+    $("messages").textContent = "";
+    loadHistory(true);
+  }
+
+  function loadHistory(scroll) {
+    var path = "messages" + (oldestId ? "?before=" + oldestId : "");
+    return request("GET", path).then(function (data) {
+      var list = $("messages");
+      var first = list.firstChild;
+      // 新しい順に返されるため、古いものが上になるよう並べ替える
+      data.messages.reverse().forEach(function (msg) {
+        if (!rendered[msg.id]) list.insertBefore(render(msg), first);
+      });
+      if (data.messages.length) oldestId = data.messages[0].id;
+      $("more").hidden = !data.hasMore;
+      if (scroll) list.scrollTop = list.scrollHeight;
+    }).catch(handleError);
+  }
+
+  $("more").addEventListener("click", function () { loadHistory(false); });
+
+  function connect() {
+    if (source) source.close();
+    source = new EventSource(api + "stream?access_token=" + encodeURIComponent(session.token));
+    source.addEventListener("reset", function () {
+      // 受信できなかったイベントがある場合は履歴を取得し直す
+      reload();
+    });
+    source.addEventListener("message.created", function (e) {
+      var msg = JSON.parse(e.data).message;
+      if (rendered[msg.id]) return;
+      var list = $("messages");
+      var atBottom = list.scrollHeight - list.scrollTop - list.clientHeight < 40;
+      list.appendChild(render(msg));
+      if (atBottom || msg.user.userId === session.id) list.scrollTop = list.scrollHeight;
+    });
+    source.addEventListener("message.updated", function (e) {
+      var msg = JSON.parse(e.data).message;
+      var el = rendered[msg.id];
+      if (el) el.parentNode.replaceChild(render(msg), el);
+    });
+    source.addEventListener("message.deleted", function (e) {
+      var msg = JSON.parse(e.data).message;
+      var el = rendered[msg.id];
+      if (el) {
+        el.parentNode.removeChild(el);
+        delete rendered[msg.id];
+      }
+    });
+  }
+
+  function render(msg) {
+    var li = document.createElement("li");
+    if (msg.user.platform === "Web" && msg.user.userId === session.id) li.className = "mine";
+
+    var meta = document.createElement("div");
+    meta.className = "meta";
+    var name = document.createElement("span");
+    name.className = "name";
+    name.textContent = msg.user.name;
+    var platform = document.createElement("span");
+    platform.className = "platform";
+    platform.textContent = msg.user.platform;
+    var time = document.createElement("time");
+    time.textContent = new Date(msg.createdAt).toLocaleString();
+    meta.append(name, platform, time);
+
+    var body = document.createElement("div");
+    body.className = "body";
+    body.textContent = msg.content.text || "";
+    (msg.content.attachments || []).forEach(function (a) {
+      if (!/^https?:/.test(a.url)) return;
+      var link = document.createElement("a");
+      link.href = a.url;
+      link.target = "_blank";
+      link.rel = "noopener noreferrer";
+      link.textContent = "📎 " + a.type;
+      body.append(document.createElement("br"), link);
+    });
+    if (msg.editedAt) {
+      var edited = document.createElement("span");
+      edited.className = "edited";
+      edited.textContent = "(編集済み)";
+      body.append(edited);
+    }
+
+    li.append(meta, body);
+    rendered[msg.id] = li;
+    return li;
+  }
+
+  $("compose").addEventListener("submit", function (e) {
+    e.preventDefault();
+    var text = $("text").value.trim();
+    if (!text) return;
+    var button = $("compose").querySelector("button");
+    button.disabled = true;
+    $("post-error").textContent = "";
+    request("POST", "messages", { text: text }).then(function () {
+      $("text").value = "";
+    }).catch(handleError).then(function () {
+      button.disabled = false;
+    });
+  });
+
+  $("text").addEventListener("keydown", function (e) {
+    if (e.key === "Enter" && !e.shiftKey && !e.isComposing) {
+      e.preventDefault();
+      $("compose").requestSubmit();
+    }
+  });
+
+  function handleError(err) {
+    // セッションの有効期限が切れた場合は参加し直してもらう
+    if (err.status === 401) {
+      if (source) source.close();
+      session = null;
+      localStorage.removeItem(storageKey);
+      showJoin();
+      return;
+    }
+    $("post-error").textContent = err.message;
+  }
+
+  // === 起動 ===
+  if (!channel) {
+    $("join").hidden = false;
+    $("join-error").textContent = "channel パラメータを指定してください";
+    return;
+  }
+  try {
+    session = JSON.parse(localStorage.getItem(storageKey));
+  } catch (e) {
+    session = null;
+  }
+  if (session && new Date(session.expiresAt) > new Date()) {
+    startChat();
+  } else {
+    session = null;
+    showJoin();
+  }
+})();
@@ -0,0 +1,19 @@
+// LinkGate Web チャットの埋め込み用スクリプト
+// <script src="https://<LinkGate>/chat/embed.js" data-channel="<チャンネルID>" async></script>
+// data-width / data-height で大きさを指定できます。
+(function () {
+  var script = document.currentScript;
+  if (!script || !script.dataset.channel) {
+    console.error("LinkGate: data-channel を指定してください");
+    return;
+  }
+  var base = new URL(".", script.src);
+  var frame = document.createElement("iframe");
+  frame.src = new URL("?channel=" + encodeURIComponent(script.dataset.channel), base).href;
+  frame.title = "LinkGate チャット";
+  frame.style.width = script.dataset.width || "360px";
+  frame.style.height = script.dataset.height || "520px";
+  frame.style.border = "1px solid #E5E7EB";
+  frame.style.borderRadius = "8px";
+  script.parentNode.insertBefore(frame, script.nextSibling);
+})();
@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Web API からの投稿で表示名の指定を許可するか
+var allowDisplayName = os.Getenv("API_ALLOW_DISPLAY_NAME") == "true"
+
+// Web API からの投稿で指定できる表示名の最大文字数
+var maxDisplayNameLength = envInt("API_DISPLAY_NAME_MAX_LENGTH", 32)
+
+var (
+	ErrDisplayNameNotAllowed = errors.New("表示名の指定は許可されていません")
+	ErrInvalidDisplayName    = errors.New("表示名が長すぎるか、使用できない文字を含んでいます")
+)
+
+// APIUser は Web API から投稿するメッセージの投稿者を返します。
+// 投稿者は常に連携（API プラットフォーム）になり、他のプラットフォームのユーザーを名乗ることはできません。
+// API_ALLOW_DISPLAY_NAME=true の場合のみ表示名を指定でき、連携名を付けて「表示名 (連携名)」と表示します。
+func APIUser(integration model.Integration, displayName string) (model.User, error) {
+	user := model.User{
+		ID:       primitive.NewObjectID(),
+		UserID:   integration.ID,
+		Name:     integration.Name,
+		Platform: model.PlatformAPI,
+	}
+
+	displayName = strings.TrimSpace(displayName)
+	if displayName == "" || displayName == integration.Name {
+		return user, nil
+	}
+	if !allowDisplayName {
+		return model.User{}, ErrDisplayNameNotAllowed
+	}
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength || strings.IndexFunc(displayName, unicode.IsControl) >= 0 {
+		return model.User{}, ErrInvalidDisplayName
+	}
+	user.Name = displayName + " (" + integration.Name + ")"
+	return user, nil
+}
+
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
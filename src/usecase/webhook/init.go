@@ -0,0 +1,301 @@
+package webhook
+
+// このパッケージは外部サービスとの汎用的な HTTP 連携（Webhook プラットフォーム）を担当します。
+// 連携はブリッジにチャンネルとして参加し、ブリッジのメッセージを署名付きの JSON で受け取り、
+// 署名付きのリクエストで LinkGate にメッセージを投稿できます。
+//
+// 署名は「タイムスタンプ.リクエストボディ」をシークレットで HMAC-SHA256 したもので、
+// X-LinkGate-Timestamp と X-LinkGate-Signature（sha256=<16進数>）ヘッダーで送受信します。
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"fuagfuga-2025-LinkGate/src/usecase/bridge"
+	"fuagfuga-2025-LinkGate/src/usecase/thread"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// 署名に関するヘッダー
+const (
+	HeaderTimestamp = "X-LinkGate-Timestamp"
+	HeaderSignature = "X-LinkGate-Signature"
+	HeaderEvent     = "X-LinkGate-Event"
+	HeaderDelivery  = "X-LinkGate-Delivery"
+)
+
+// 受信時に許容するタイムスタンプのずれ（リプレイ攻撃対策）
+const timestampTolerance = 5 * time.Minute
+
+// 転送の最大試行回数と、再試行までの初回の待ち時間（試行ごとに2倍）
+const (
+	maxAttempts    = 5
+	initialBackoff = 2 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	ErrInvalidSignature = errors.New("署名が正しくありません")
+	ErrExpiredTimestamp = errors.New("タイムスタンプが古すぎるか、未来の日時です")
+)
+
+// Payload は連携に転送するイベントです
+type Payload struct {
+	// イベントの種類（message.created / message.updated / message.deleted）
+	Event string `json:"event"`
+	// 転送ID（再試行でも同じ値。重複の判定に使用できます）
+	DeliveryID string `json:"deliveryId"`
+	// 連携ID
+	IntegrationID string `json:"integrationId"`
+	// ブリッジID
+	BridgeID string `json:"bridgeId"`
+	// スレッド内のメッセージの場合のスレッド
+	Thread *PayloadThread `json:"thread,omitempty"`
+	// メッセージ
+	Message model.Message `json:"message"`
+}
+
+// PayloadThread は転送するメッセージが属するスレッドです
+type PayloadThread struct {
+	// 連携側のスレッドID（返信を投稿するときに threadId に指定します）
+	ID string `json:"id"`
+	// スレッドのタイトル
+	Title string `json:"title"`
+}
+
+// InboundMessage は連携から投稿されるメッセージです
+type InboundMessage struct {
+	// 投稿者
+	User struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		IconURL string `json:"iconUrl"`
+	} `json:"user"`
+	// 本文
+	Text string `json:"text"`
+	// 添付ファイル
+	Attachments []model.Attachment `json:"attachments"`
+	// 連携側のメッセージID（指定した場合は同じIDのメッセージを二重登録しません）
+	ExternalID string `json:"externalId"`
+	// 連携側のスレッドID（転送されたメッセージの thread.id を指定すると、そのスレッドへの返信になります）
+	ThreadID string `json:"threadId"`
+	// 新しいスレッドを作成する場合のタイトル
+	ThreadTitle string `json:"threadTitle"`
+}
+
+// Sign はタイムスタンプとリクエストボディの署名を返します。
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify は受信したリクエストの署名とタイムスタンプを検証します。
+func Verify(secret, timestamp, signature string, body []byte) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > timestampTolerance || d < -timestampTolerance {
+		return ErrExpiredTimestamp
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// イベントの種類
+const (
+	EventMessageCreated = "message.created"
+	EventMessageUpdated = "message.updated"
+	EventMessageDeleted = "message.deleted"
+)
+
+// CreateWebhookMessage はMongoDBに新規追加されたメッセージを、同じブリッジの連携へ転送します。
+// 転送は非同期で行い、失敗した場合は待ち時間を延ばしながら再試行します。
+func CreateWebhookMessage(msg model.Message) {
+	notify(msg, EventMessageCreated)
+}
+
+// EditWebhookMessage は編集されたメッセージを、同じブリッジの連携へ message.updated イベントとして転送します。
+func EditWebhookMessage(msg model.Message) {
+	notify(msg, EventMessageUpdated)
+}
+
+// DeleteWebhookMessage は削除されたメッセージを、同じブリッジの連携へ message.deleted イベントとして転送します。
+func DeleteWebhookMessage(msg model.Message) {
+	notify(msg, EventMessageDeleted)
+}
+
+func notify(msg model.Message, event string) {
+	channels := bridge.Destinations(msg, model.PlatformWebhook)
+	if len(channels) == 0 {
+		return
+	}
+
+	link, inThread := thread.ForMessage(msg)
+
+	for _, ch := range channels {
+		integration, err := Get(ch.ChannelID)
+		if err != nil {
+			log.Printf("連携の取得に失敗しました (integration: %s): %v", ch.ChannelID, err)
+			continue
+		}
+		if integration.Disabled || integration.URL == "" {
+			continue
+		}
+
+		payload := Payload{
+			Event:         event,
+			DeliveryID:    primitive.NewObjectID().Hex(),
+			IntegrationID: integration.ID,
+			BridgeID:      msg.BridgeID,
+			Message:       msg,
+		}
+		if inThread {
+			payload.Thread = &PayloadThread{ID: webhookThread(link, integration.ID), Title: thread.DisplayTitle(link)}
+		}
+
+		go deliver(integration, payload)
+	}
+}
+
+// webhookThread は連携側のスレッドIDを返します。
+// 連携はスレッドを作成できないため、初めて転送するスレッドは対応関係のIDを連携側のスレッドIDとして記録します。
+func webhookThread(link model.ThreadLink, integrationID string) string {
+	if ref, ok := thread.Counterpart(link, model.PlatformWebhook, integrationID); ok {
+		return ref.ThreadID
+	}
+
+	ref := model.ThreadRef{Platform: model.PlatformWebhook, ChannelID: integrationID, ThreadID: link.ID.Hex()}
+	if err := thread.AddThread(link.ID, ref); err != nil {
+		log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+	}
+	return ref.ThreadID
+}
+
+// deliver は連携のURLへイベントを POST します。
+// 通信エラー・429・5xx の場合は再試行し、それ以外の 4xx は再試行しません。
+func deliver(integration model.Integration, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("転送データの作成に失敗しました: %v", err)
+		return
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := post(integration, payload, body)
+		if err == nil {
+			recordDelivery(integration.ID, nil)
+			log.Printf("Webhook送信成功 (integration: %s, delivery: %s)", integration.ID, payload.DeliveryID)
+			return
+		}
+
+		log.Printf("Webhookの送信に失敗しました (integration: %s, attempt: %d/%d): %v", integration.ID, attempt, maxAttempts, err)
+		if !retry || attempt >= maxAttempts {
+			recordDelivery(integration.ID, err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// 1回分の送信。再試行すべきかとエラーを返す
+func post(integration model.Integration, payload Payload, body []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, integration.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	// 再試行のたびにタイムスタンプを更新して署名する
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LinkGate-Webhook/1.0")
+	req.Header.Set(HeaderEvent, payload.Event)
+	req.Header.Set(HeaderDelivery, payload.DeliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(integration.Secret, timestamp, body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("status %d", resp.StatusCode)
+}
+
+// Receive は連携から投稿されたメッセージを model.Message として保存します。
+// 連携はブリッジに参加している必要があります。
+func Receive(integration model.Integration, in InboundMessage) (model.Message, error) {
+	if messageCollection == nil {
+		return model.Message{}, errors.New("MongoDB collection is not initialized")
+	}
+
+	b, ok := bridge.Find(model.PlatformWebhook, integration.ID)
+	if !ok {
+		return model.Message{}, bridge.ErrNotJoined
+	}
+
+	var message model.Message
+
+	// Message構造体に保存内容を格納
+	message.ID = primitive.NewObjectID()
+	message.User.ID = primitive.NewObjectID()
+	message.User.UserID = integration.ID
+	if in.User.ID != "" {
+		message.User.UserID = integration.ID + ":" + in.User.ID
+	}
+	message.User.Platform = model.PlatformWebhook
+	message.User.Name = strings.TrimSpace(in.User.Name)
+	if message.User.Name == "" {
+		message.User.Name = integration.Name
+	}
+	message.User.IconUrl = in.User.IconURL
+	message.Content.ID = primitive.NewObjectID()
+	message.Content.Text = in.Text
+	message.Content.Attachments = in.Attachments
+	message.BridgeID = b.ID
+	message.ChannelID = integration.ID
+	message.ExternalID = in.ExternalID
+	message.CreatedAt = time.Now()
+
+	if in.ThreadID != "" {
+		ref := model.ThreadRef{Platform: model.PlatformWebhook, ChannelID: integration.ID, ThreadID: in.ThreadID}
+		if _, err := thread.Ensure(b.ID, in.ThreadTitle, ref); err != nil {
+			log.Printf("スレッドの対応関係の保存に失敗しました: %v", err)
+		}
+		message.ThreadID = in.ThreadID
+	}
+
+	if err := saveMessage(message); err != nil {
+		return model.Message{}, err
+	}
+	log.Printf("Webhook message saved: %s from %s", message.Content.Text, message.User.Name)
+	return message, nil
+}
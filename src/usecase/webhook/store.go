@@ -0,0 +1,150 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"fuagfuga-2025-LinkGate/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotInitialized = errors.New("連携の保存先が初期化されていません")
+	ErrNotFound       = errors.New("連携が見つかりません")
+)
+
+// 連携の保存先
+var collection *mongo.Collection
+
+// 連携から投稿されたメッセージの保存先
+var messageCollection *mongo.Collection
+
+// Init は連携とメッセージの保存先を設定します。
+func Init(coll, messages *mongo.Collection) {
+	collection = coll
+	messageCollection = messages
+}
+
+// Create は連携を登録し、署名用のシークレットを発行します。
+func Create(name, url string) (model.Integration, string, error) {
+	if collection == nil {
+		return model.Integration{}, "", ErrNotInitialized
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return model.Integration{}, "", err
+	}
+	integration := model.Integration{
+		ID:        primitive.NewObjectID().Hex(),
+		Name:      name,
+		URL:       url,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := collection.InsertOne(ctx, integration); err != nil {
+		return model.Integration{}, "", err
+	}
+	return integration, secret, nil
+}
+
+// Get は連携を返します。
+func Get(id string) (model.Integration, error) {
+	if collection == nil {
+		return model.Integration{}, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var integration model.Integration
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&integration)
+	if err == mongo.ErrNoDocuments {
+		return model.Integration{}, ErrNotFound
+	}
+	return integration, err
+}
+
+// List は登録されている連携を作成日時の順に返します。
+func List() ([]model.Integration, error) {
+	if collection == nil {
+		return nil, ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cur, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	integrations := []model.Integration{}
+	err = cur.All(ctx, &integrations)
+	return integrations, err
+}
+
+// Delete は連携を削除します。
+func Delete(id string) error {
+	if collection == nil {
+		return ErrNotInitialized
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// メッセージを保存する（連携側のメッセージIDがある場合は二重登録しないよう upsert する）
+func saveMessage(message model.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if message.ExternalID == "" {
+		_, err := messageCollection.InsertOne(ctx, message)
+		return err
+	}
+	filter := bson.M{"user.platform": model.PlatformWebhook, "channelId": message.ChannelID, "externalId": message.ExternalID}
+	_, err := messageCollection.UpdateOne(ctx, filter, bson.M{"$setOnInsert": message}, options.Update().SetUpsert(true))
+	return err
+}
+
+// 転送の結果を記録する
+func recordDelivery(id string, deliveryErr error) {
+	if collection == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{"lastDeliveryAt": time.Now(), "lastError": ""}
+	if deliveryErr != nil {
+		set["lastError"] = deliveryErr.Error()
+	}
+	collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}